@@ -7,6 +7,7 @@ import (
 	"time"
 	"yadro-go/internal/adapter/secondary/repository"
 	"yadro-go/internal/adapter/secondary/xkcd"
+	"yadro-go/internal/core/domain"
 	"yadro-go/internal/core/service"
 	"yadro-go/internal/core/service/stemming"
 	logutil "yadro-go/pkg/logger"
@@ -40,33 +41,34 @@ func init() {
 	stemmer := stemming.New()
 
 	client := xkcd.NewHttpClient(log, "https://xkcd.com", time.Minute)
-	updater := service.NewUpdater(log, stemmer, comicsRepo, keywordsRepo, client, 2000, 200)
+	runsRepo := repository.NewUpdateRunRepository(log, db)
+	updater := service.NewUpdater(log, stemmer, comicsRepo, keywordsRepo, runsRepo, client, 2000, 200)
 
 	if _, err = updater.Update(context.Background()); err != nil {
 		panic(err)
 	}
 
-	scanner = service.NewScanner(log, stemmer, comicsRepo, keywordsRepo)
+	scanner = service.NewScanner(log, stemmer, comicsRepo, keywordsRepo, nil, nil, 2)
 }
 
 func BenchmarkScanNoIndex(b *testing.B) {
 	b.Run("query_small", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
-			if _, err := scanner.Scan(context.Background(), querySmall, false); err != nil {
+			if _, err := scanner.Scan(context.Background(), querySmall, domain.ScanOptions{}); err != nil {
 				b.Error(err)
 			}
 		}
 	})
 	b.Run("query_medium", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
-			if _, err := scanner.Scan(context.Background(), queryMedium, false); err != nil {
+			if _, err := scanner.Scan(context.Background(), queryMedium, domain.ScanOptions{}); err != nil {
 				b.Error(err)
 			}
 		}
 	})
 	b.Run("query_large", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
-			if _, err := scanner.Scan(context.Background(), queryLarge, false); err != nil {
+			if _, err := scanner.Scan(context.Background(), queryLarge, domain.ScanOptions{}); err != nil {
 				b.Error(err)
 			}
 		}
@@ -76,21 +78,21 @@ func BenchmarkScanNoIndex(b *testing.B) {
 func BenchmarkScanIndex(b *testing.B) {
 	b.Run("query_small", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
-			if _, err := scanner.Scan(context.Background(), querySmall, true); err != nil {
+			if _, err := scanner.Scan(context.Background(), querySmall, domain.ScanOptions{UseIndex: true}); err != nil {
 				b.Error(err)
 			}
 		}
 	})
 	b.Run("query_medium", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
-			if _, err := scanner.Scan(context.Background(), queryMedium, true); err != nil {
+			if _, err := scanner.Scan(context.Background(), queryMedium, domain.ScanOptions{UseIndex: true}); err != nil {
 				b.Error(err)
 			}
 		}
 	})
 	b.Run("query_large", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
-			if _, err := scanner.Scan(context.Background(), queryLarge, true); err != nil {
+			if _, err := scanner.Scan(context.Background(), queryLarge, domain.ScanOptions{UseIndex: true}); err != nil {
 				b.Error(err)
 			}
 		}
@@ -79,6 +79,8 @@ type comicStub struct {
 }
 type keywordStub struct {
 }
+type runStub struct {
+}
 
 func (d *comicStub) Comics(_ context.Context, _ []int) ([]*domain.Comic, error) {
 	return make([]*domain.Comic, 0), nil
@@ -92,18 +94,66 @@ func (d *comicStub) Save(_ context.Context, _ []*domain.Comic) error {
 	return nil
 }
 
+func (d *comicStub) Missing(_ context.Context) ([]int, error) {
+	return make([]int, 0), nil
+}
+
+func (d *comicStub) SaveMissing(_ context.Context, _ []int) error {
+	return nil
+}
+
 func (d *keywordStub) Keywords(_ context.Context, _ []string) ([]*domain.ComicKeyword, error) {
 	return make([]*domain.ComicKeyword, 0), nil
 }
 
-func (d *keywordStub) Save(_ context.Context, _ []*domain.ComicKeyword) error {
+func (d *keywordStub) Lengths(_ context.Context, _ []int) (map[int]int, error) {
+	return make(map[int]int), nil
+}
+
+func (d *keywordStub) Generations(_ context.Context, _ []int) (map[int]int64, error) {
+	return make(map[int]int64), nil
+}
+
+func (d *keywordStub) Stats(_ context.Context) (*domain.IndexStats, error) {
+	return &domain.IndexStats{}, nil
+}
+
+func (d *keywordStub) Vocabulary(_ context.Context) ([]*domain.VocabularyWord, error) {
+	return make([]*domain.VocabularyWord, 0), nil
+}
+
+func (d *keywordStub) Save(_ context.Context, _ []*domain.ComicKeyword, _ map[int]int) error {
 	return nil
 }
 
+func (d *keywordStub) Reindex(_ context.Context, _ []*domain.ComicKeyword, _ map[int]int) error {
+	return nil
+}
+
+func (d *runStub) Start(_ context.Context, _ *domain.UpdateRun) (int64, error) {
+	return 1, nil
+}
+
+func (d *runStub) Finish(_ context.Context, _ *domain.UpdateRun) error {
+	return nil
+}
+
+func (d *runStub) Runs(_ context.Context, _ int, _ int64) ([]*domain.UpdateRun, error) {
+	return make([]*domain.UpdateRun, 0), nil
+}
+
+func (d *runStub) Interrupt(_ context.Context, _ string) error {
+	return nil
+}
+
+func (d *runStub) LastSuccess(_ context.Context) (time.Time, error) {
+	return time.Time{}, nil
+}
+
 func newService(parallel int) *service.Updater {
 	log := slog.New(logger.EmptyHandler{})
 	stemmer := stemming.New()
 	client := xkcd.NewHttpClient(log, "https://xkcd.com", time.Minute)
 
-	return service.NewUpdater(log, stemmer, &comicStub{}, &keywordStub{}, client, 99999, parallel)
+	return service.NewUpdater(log, stemmer, &comicStub{}, &keywordStub{}, &runStub{}, client, 99999, parallel)
 }
@@ -0,0 +1,74 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"strconv"
+	"yadro-go/internal/core/domain"
+)
+
+var errCursorMismatch = errors.New("cursor belongs to another query")
+
+// pageCursor is a json form of domain.PageCursor bound to the query it was made for.
+type pageCursor struct {
+	Docs       int     `json:"d"`
+	AvgLength  float64 `json:"l"`
+	Generation int64   `json:"g"`
+	Score      float64 `json:"s"`
+	Num        int     `json:"n"`
+	Query      uint64  `json:"q"`
+}
+
+func encodeCursor(cursor *domain.PageCursor, fingerprint uint64) (string, error) {
+	data, err := json.Marshal(&pageCursor{
+		Docs:       cursor.Docs,
+		AvgLength:  cursor.AvgLength,
+		Generation: cursor.Generation,
+		Score:      cursor.Score,
+		Num:        cursor.Num,
+		Query:      fingerprint,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(encoded string, fingerprint uint64) (*domain.PageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor pageCursor
+	if err = json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.Query != fingerprint {
+		return nil, errCursorMismatch
+	}
+
+	return &domain.PageCursor{
+		Docs:       cursor.Docs,
+		AvgLength:  cursor.AvgLength,
+		Generation: cursor.Generation,
+		Score:      cursor.Score,
+		Num:        cursor.Num,
+	}, nil
+}
+
+// queryFingerprint hashes search params affecting ranking, page size is not included.
+func queryFingerprint(search string, opts domain.ScanOptions) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(search))
+	for _, field := range opts.Fields {
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(field))
+	}
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(strconv.FormatBool(opts.Fuzzy)))
+	return h.Sum64()
+}
@@ -17,3 +17,9 @@ func ScanLimit(limit int) Option {
 		r.scanLimit = limit
 	}
 }
+
+func ScanMaxLimit(limit int) Option {
+	return func(r *router) {
+		r.scanMaxLimit = limit
+	}
+}
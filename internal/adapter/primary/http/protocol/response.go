@@ -2,11 +2,101 @@ package protocol
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"time"
 )
 
 type UpdateResponse struct {
-	Total int `json:"total"`
+	Total   int                     `json:"total"`
+	New     int                     `json:"new,omitempty"`
+	Updated int                     `json:"updated,omitempty"`
+	Failed  []*FetchFailureResponse `json:"failed,omitempty"`
+}
+
+type FetchFailureResponse struct {
+	Num   int    `json:"num"`
+	Error string `json:"error"`
+}
+
+type UpdateJobResponse struct {
+	Id         int64           `json:"id"`
+	Trigger    string          `json:"trigger"`
+	Mode       string          `json:"mode"`
+	Username   string          `json:"username,omitempty"`
+	State      string          `json:"state"`
+	Total      int             `json:"total"`
+	Fetched    int             `json:"fetched"`
+	Failed     int             `json:"failed"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+	Errors     []string        `json:"errors,omitempty"`
+	Result     *UpdateResponse `json:"result,omitempty"`
+}
+
+type UpdateRunResponse struct {
+	Id         int64      `json:"id"`
+	Trigger    string     `json:"trigger"`
+	Mode       string     `json:"mode"`
+	Username   string     `json:"username,omitempty"`
+	State      string     `json:"state"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	New        int        `json:"new"`
+	Updated    int        `json:"updated"`
+	Failed     int        `json:"failed"`
+	Error      string     `json:"error,omitempty"`
+}
+
+type UpdateRunsResponse struct {
+	Runs       []*UpdateRunResponse `json:"runs"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+type UpdateScheduleResponse struct {
+	Active   bool                    `json:"active"`
+	Timezone string                  `json:"timezone"`
+	Jitter   string                  `json:"jitter,omitempty"`
+	NextRun  *time.Time              `json:"next_run,omitempty"`
+	Crons    []*CronScheduleResponse `json:"crons"`
+}
+
+type CronScheduleResponse struct {
+	Expr    string     `json:"expr"`
+	Mode    string     `json:"mode"`
+	NextRun *time.Time `json:"next_run,omitempty"`
+}
+
+type UpdateEventResponse struct {
+	Job   int64     `json:"job"`
+	Time  time.Time `json:"time"`
+	Num   int       `json:"num,omitempty"`
+	Count int       `json:"count,omitempty"`
+	State string    `json:"state,omitempty"`
+	Error string    `json:"error,omitempty"`
+}
+
+type PicsResponse struct {
+	Comics      []string `json:"comics"`
+	Suggestions []string `json:"suggestions,omitempty"`
+	NextCursor  string   `json:"next_cursor,omitempty"`
+}
+
+type ComicResponse struct {
+	Num          int      `json:"num"`
+	Title        string   `json:"title"`
+	Img          string   `json:"img"`
+	Alt          string   `json:"alt"`
+	Score        float64  `json:"score"`
+	MatchedTerms []string `json:"matched_terms"`
+	Snippet      string   `json:"snippet,omitempty"`
+}
+
+type PicsV2Response struct {
+	Comics      []*ComicResponse `json:"comics"`
+	Suggestions []string         `json:"suggestions,omitempty"`
+	NextCursor  string           `json:"next_cursor,omitempty"`
 }
 
 type LoginResponse struct {
@@ -18,17 +108,33 @@ type errResp struct {
 }
 
 func ResponseError(w http.ResponseWriter, code int, msg string) {
-	w.WriteHeader(code)
-	_ = ResponseJson(w, errResp{Error: msg})
+	_ = ResponseJsonStatus(w, code, errResp{Error: msg})
+}
+
+// ResponseEvent writes a server-sent event with JSON data.
+func ResponseEvent(w io.Writer, id string, event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", id, event, data)
+	return err
 }
 
 func ResponseJson(w http.ResponseWriter, v any) error {
+	return ResponseJsonStatus(w, http.StatusOK, v)
+}
+
+// ResponseJsonStatus writes v as JSON with the status code, headers are set before the status is written.
+func ResponseJsonStatus(w http.ResponseWriter, code int, v any) error {
 	res, err := json.Marshal(v)
 	if err != nil {
 		return err
 	}
 
 	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(code)
 	_, err = w.Write(res)
 	return err
 }
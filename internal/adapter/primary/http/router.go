@@ -4,9 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"expvar"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 	"yadro-go/internal/adapter/primary"
 	"yadro-go/internal/adapter/primary/http/middleware"
@@ -14,14 +18,30 @@ import (
 	"yadro-go/internal/adapter/primary/http/ratelimiter"
 	"yadro-go/internal/core/domain"
 	"yadro-go/internal/core/service"
+	"yadro-go/internal/core/service/query"
 	"yadro-go/pkg/logger"
 )
 
 const (
-	formSearch = "search"
+	formSearch  = "search"
+	formFields  = "fields"
+	formFuzzy   = "fuzzy"
+	formSuggest = "suggest"
+	formLimit   = "limit"
+	formCursor  = "cursor"
+	formMode    = "mode"
 
-	defaultScanTimeout = 1 * time.Minute
-	defaultScanLimit   = 10
+	headerNextCursor  = "X-Next-Cursor"
+	headerLastEventId = "Last-Event-ID"
+
+	eventsHeartbeat = 15 * time.Second
+
+	defaultScanTimeout  = 1 * time.Minute
+	defaultScanLimit    = 10
+	defaultScanMaxLimit = 100
+
+	defaultRunsLimit = 20
+	maxRunsLimit     = 100
 )
 
 type router struct {
@@ -30,8 +50,9 @@ type router struct {
 	updater primary.Updater
 	auth    primary.Auth
 
-	scanTimeout time.Duration
-	scanLimit   int
+	scanTimeout  time.Duration
+	scanLimit    int
+	scanMaxLimit int
 }
 
 func ApplyRouter(
@@ -45,12 +66,13 @@ func ApplyRouter(
 	opts ...Option,
 ) {
 	r := &router{
-		log:         log,
-		scanner:     scanner,
-		updater:     updater,
-		auth:        auth,
-		scanTimeout: defaultScanTimeout,
-		scanLimit:   defaultScanLimit,
+		log:          log,
+		scanner:      scanner,
+		updater:      updater,
+		auth:         auth,
+		scanTimeout:  defaultScanTimeout,
+		scanLimit:    defaultScanLimit,
+		scanMaxLimit: defaultScanMaxLimit,
 	}
 
 	for _, opt := range opts {
@@ -63,75 +85,535 @@ func ApplyRouter(
 
 	handler.HandleFunc("POST /login", r.Login)
 	handler.HandleFunc("POST /update", authMiddleware.WithAuth(domain.ROLE_ADMIN, r.Update))
+	handler.HandleFunc("GET /update/events", authMiddleware.WithAuth(domain.ROLE_ADMIN, r.UpdateEvents))
+	handler.HandleFunc("GET /update/runs", authMiddleware.WithAuth(domain.ROLE_ADMIN, r.UpdateRuns))
+	handler.HandleFunc("GET /update/schedule", authMiddleware.WithAuth(domain.ROLE_ADMIN, r.UpdateSchedule))
+	handler.HandleFunc("GET /update/{id}", authMiddleware.WithAuth(domain.ROLE_ADMIN, r.UpdateJob))
+	handler.HandleFunc("DELETE /update/{id}", authMiddleware.WithAuth(domain.ROLE_ADMIN, r.CancelUpdate))
+	handler.HandleFunc("POST /reindex", authMiddleware.WithAuth(domain.ROLE_ADMIN, r.Reindex))
+	handler.HandleFunc("GET /metrics", authMiddleware.WithAuth(domain.ROLE_ADMIN, r.Metrics))
 	handler.HandleFunc("GET /pics", concurrencyMiddleware.WithConcurrencyLimit(
 		authMiddleware.WithAuth(
 			domain.ROLE_USER,
 			rpsMiddleware.WithRpsLimit(r.Pics))),
 	)
+	handler.HandleFunc("GET /v2/pics", concurrencyMiddleware.WithConcurrencyLimit(
+		authMiddleware.WithAuth(
+			domain.ROLE_USER,
+			rpsMiddleware.WithRpsLimit(r.PicsV2))),
+	)
 }
 
+// Update starts an update job, its progress is available by the returned id.
+// The refresh mode fetches all stored comics again to find the edited ones.
 func (r *router) Update(w http.ResponseWriter, req *http.Request, user *domain.User) {
 	const op = "router.Update"
 	log := r.log.With(slog.String("op", op), slog.String("uname", user.Username))
 
 	log.Debug("handle update")
 
-	total, err := r.updater.Update(req.Context())
+	mode := domain.UpdateModeNew
+	if req.URL.Query().Has(formMode) {
+		mode = domain.UpdateMode(req.URL.Query().Get(formMode))
+		if mode != domain.UpdateModeNew && mode != domain.UpdateModeRefresh {
+			protocol.ResponseError(w, http.StatusBadRequest, "mode param must be new or refresh")
+			return
+		}
+	}
+
+	// the job outlives the request, it is cancelled on shutdown
+	job, err := r.updater.StartUpdate(req.Context(), domain.UpdateTriggerManual, mode, user.Username)
 	if err != nil {
 		if errors.Is(err, service.ErrUpdateInProgress) {
-			protocol.ResponseError(w, http.StatusAccepted, "update in progress")
+			protocol.ResponseError(w, http.StatusConflict, "update in progress")
 			return
 		}
 
-		log.Error("error updating", logger.Err(err))
+		log.Error("error starting update", logger.Err(err))
 		protocol.ResponseError(w, http.StatusInternalServerError, "update failed")
 		return
 	}
 
+	log.Info(fmt.Sprintf("update job %d started", job.Id))
+
+	if err = protocol.ResponseJsonStatus(w, http.StatusAccepted, updateJobResponse(job)); err != nil {
+		log.Error("failed to response", logger.Err(err))
+	}
+}
+
+// UpdateJob reports progress of an update job.
+func (r *router) UpdateJob(w http.ResponseWriter, req *http.Request, user *domain.User) {
+	const op = "router.UpdateJob"
+	log := r.log.With(slog.String("op", op), slog.String("uname", user.Username))
+
+	id, err := strconv.ParseInt(req.PathValue("id"), 10, 64)
+	if err != nil {
+		protocol.ResponseError(w, http.StatusBadRequest, "bad job id")
+		return
+	}
+
+	job, err := r.updater.UpdateJob(id)
+	if err != nil {
+		r.responseJobError(w, log, err)
+		return
+	}
+
+	if err = protocol.ResponseJson(w, updateJobResponse(job)); err != nil {
+		log.Error("failed to response", logger.Err(err))
+	}
+}
+
+// CancelUpdate stops an update job, comics saved before are kept.
+func (r *router) CancelUpdate(w http.ResponseWriter, req *http.Request, user *domain.User) {
+	const op = "router.CancelUpdate"
+	log := r.log.With(slog.String("op", op), slog.String("uname", user.Username))
+
+	id, err := strconv.ParseInt(req.PathValue("id"), 10, 64)
+	if err != nil {
+		protocol.ResponseError(w, http.StatusBadRequest, "bad job id")
+		return
+	}
+
+	log.Debug(fmt.Sprintf("handle cancel of update job %d", id))
+
+	job, err := r.updater.CancelUpdate(id)
+	if err != nil {
+		r.responseJobError(w, log, err)
+		return
+	}
+
+	if err = protocol.ResponseJson(w, updateJobResponse(job)); err != nil {
+		log.Error("failed to response", logger.Err(err))
+	}
+}
+
+// UpdateRuns lists recorded update runs, latest first. The next page starts after next_cursor.
+func (r *router) UpdateRuns(w http.ResponseWriter, req *http.Request, user *domain.User) {
+	const op = "router.UpdateRuns"
+	log := r.log.With(slog.String("op", op), slog.String("uname", user.Username))
+
+	limit := defaultRunsLimit
+	if req.URL.Query().Has(formLimit) {
+		l, err := strconv.Atoi(req.URL.Query().Get(formLimit))
+		if err != nil || l <= 0 {
+			protocol.ResponseError(w, http.StatusBadRequest, "limit param must be positive integer")
+			return
+		}
+		limit = min(l, maxRunsLimit)
+	}
+
+	var before int64
+	if req.URL.Query().Has(formCursor) {
+		cursor, err := strconv.ParseInt(req.URL.Query().Get(formCursor), 10, 64)
+		if err != nil || cursor <= 0 {
+			protocol.ResponseError(w, http.StatusBadRequest, "bad cursor")
+			return
+		}
+		before = cursor
+	}
+
+	runs, next, err := r.updater.UpdateRuns(req.Context(), limit, before)
+	if err != nil {
+		log.Error("error getting update runs", logger.Err(err))
+		protocol.ResponseError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	resp := &protocol.UpdateRunsResponse{Runs: make([]*protocol.UpdateRunResponse, 0, len(runs))}
+	for _, run := range runs {
+		runResp := &protocol.UpdateRunResponse{
+			Id:        run.Id,
+			Trigger:   string(run.Trigger),
+			Mode:      string(run.Mode),
+			Username:  run.Username,
+			State:     string(run.State),
+			StartedAt: run.Started,
+			New:       run.New,
+			Updated:   run.Updated,
+			Failed:    run.Failed,
+			Error:     run.Error,
+		}
+		if !run.Finished.IsZero() {
+			runResp.FinishedAt = &run.Finished
+		}
+		resp.Runs = append(resp.Runs, runResp)
+	}
+	if next > 0 {
+		resp.NextCursor = strconv.FormatInt(next, 10)
+	}
+
+	if err = protocol.ResponseJson(w, resp); err != nil {
+		log.Error("failed to response", logger.Err(err))
+	}
+}
+
+// UpdateSchedule reports the next times of scheduled updates.
+func (r *router) UpdateSchedule(w http.ResponseWriter, req *http.Request, user *domain.User) {
+	const op = "router.UpdateSchedule"
+	log := r.log.With(slog.String("op", op), slog.String("uname", user.Username))
+
+	schedule, err := r.updater.Schedule()
+	if err != nil {
+		if errors.Is(err, service.ErrNoScheduler) {
+			protocol.ResponseError(w, http.StatusServiceUnavailable, "scheduler is not running")
+			return
+		}
+		log.Error("error getting update schedule", logger.Err(err))
+		protocol.ResponseError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	resp := &protocol.UpdateScheduleResponse{
+		Active:   schedule.Active,
+		Timezone: schedule.Location,
+		Crons:    make([]*protocol.CronScheduleResponse, 0, len(schedule.Crons)),
+	}
+	if schedule.Jitter > 0 {
+		resp.Jitter = schedule.Jitter.String()
+	}
+	if !schedule.Next.IsZero() {
+		resp.NextRun = &schedule.Next
+	}
+	for _, c := range schedule.Crons {
+		cronResp := &protocol.CronScheduleResponse{Expr: c.Expr, Mode: string(c.Mode)}
+		if !c.Next.IsZero() {
+			cronResp.NextRun = &c.Next
+		}
+		resp.Crons = append(resp.Crons, cronResp)
+	}
+
+	if err = protocol.ResponseJson(w, resp); err != nil {
+		log.Error("failed to response", logger.Err(err))
+	}
+}
+
+// UpdateEvents streams progress events of update jobs as server-sent events. A reconnected client
+// receives the kept events it has missed after the one in Last-Event-ID.
+func (r *router) UpdateEvents(w http.ResponseWriter, req *http.Request, user *domain.User) {
+	const op = "router.UpdateEvents"
+	log := r.log.With(slog.String("op", op), slog.String("uname", user.Username))
+
+	var lastEventId int64
+	if header := req.Header.Get(headerLastEventId); header != "" {
+		id, err := strconv.ParseInt(header, 10, 64)
+		if err != nil {
+			protocol.ResponseError(w, http.StatusBadRequest, "bad last event id")
+			return
+		}
+		lastEventId = id
+	}
+
+	// the stream lasts longer than the server write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		log.Error("failed to reset write deadline", logger.Err(err))
+		protocol.ResponseError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	missed, events, unsubscribe := r.updater.SubscribeEvents(lastEventId)
+	defer unsubscribe()
+
+	log.Debug(fmt.Sprintf("streaming update events after %d", lastEventId))
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, event := range missed {
+		if err := writeUpdateEvent(w, event); err != nil {
+			log.Debug("client has gone", logger.Err(err))
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				// the client fell behind, it reconnects and gets the missed events
+				log.Warn("update events subscriber dropped")
+				return
+			}
+			if err := writeUpdateEvent(w, event); err != nil {
+				log.Debug("client has gone", logger.Err(err))
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case <-req.Context().Done():
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (r *router) responseJobError(w http.ResponseWriter, log *slog.Logger, err error) {
+	if errors.Is(err, service.ErrJobNotFound) {
+		protocol.ResponseError(w, http.StatusNotFound, "update job not found")
+		return
+	}
+
+	log.Error("error getting update job", logger.Err(err))
+	protocol.ResponseError(w, http.StatusInternalServerError, "internal error")
+}
+
+func updateJobResponse(job *domain.UpdateJob) *protocol.UpdateJobResponse {
+	resp := &protocol.UpdateJobResponse{
+		Id:        job.Id,
+		Trigger:   string(job.Trigger),
+		Mode:      string(job.Mode),
+		Username:  job.Username,
+		State:     string(job.State),
+		Total:     job.Total,
+		Fetched:   job.Fetched,
+		Failed:    job.Failed,
+		StartedAt: job.Started,
+		Errors:    job.Errors,
+	}
+	if !job.Finished.IsZero() {
+		resp.FinishedAt = &job.Finished
+	}
+	if job.Result != nil {
+		resp.Result = updateResponse(job.Result)
+	}
+	return resp
+}
+
+func writeUpdateEvent(w io.Writer, event *domain.UpdateEvent) error {
+	return protocol.ResponseEvent(w, strconv.FormatInt(event.Id, 10), string(event.Type), &protocol.UpdateEventResponse{
+		Job:   event.JobId,
+		Time:  event.Time,
+		Num:   event.Num,
+		Count: event.Count,
+		State: string(event.State),
+		Error: event.Error,
+	})
+}
+
+func updateResponse(result *domain.UpdateResult) *protocol.UpdateResponse {
+	resp := &protocol.UpdateResponse{Total: result.Total, New: result.New, Updated: result.Updated}
+	for _, failure := range result.Failures {
+		resp.Failed = append(resp.Failed, &protocol.FetchFailureResponse{Num: failure.Num, Error: failure.Error})
+	}
+	return resp
+}
+
+// Reindex rebuilds keywords of all stored comics and responds when it is done.
+func (r *router) Reindex(w http.ResponseWriter, req *http.Request, user *domain.User) {
+	const op = "router.Reindex"
+	log := r.log.With(slog.String("op", op), slog.String("uname", user.Username))
+
+	log.Debug("handle reindex")
+
+	// the reindex is synchronous and may last longer than the server write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Warn("failed to reset write deadline", logger.Err(err))
+	}
+
+	total, err := r.updater.Reindex(req.Context())
+	if err != nil {
+		if errors.Is(err, service.ErrUpdateInProgress) {
+			protocol.ResponseError(w, http.StatusConflict, "update in progress")
+			return
+		}
+
+		log.Error("error reindexing", logger.Err(err))
+		protocol.ResponseError(w, http.StatusInternalServerError, "reindex failed")
+		return
+	}
+
 	if err = protocol.ResponseJson(w, &protocol.UpdateResponse{Total: total}); err != nil {
 		log.Error("failed to response", logger.Err(err))
 	}
 }
 
+// Metrics responds with expvar variables, including index memory use and build time.
+func (r *router) Metrics(w http.ResponseWriter, req *http.Request, _ *domain.User) {
+	expvar.Handler().ServeHTTP(w, req)
+}
+
 func (r *router) Pics(w http.ResponseWriter, req *http.Request, user *domain.User) {
 	const op = "router.Pics"
 	log := r.log.With(slog.String("op", op), slog.String("uname", user.Username))
 
 	log.Debug("handle search")
 
+	found, ok := r.scan(w, req, log, domain.ScanOptions{UseIndex: true})
+	if !ok {
+		return
+	}
+
+	urls := make([]string, len(found.result.Comics))
+	for i, scored := range found.result.Comics {
+		urls[i] = scored.Comic.Img
+	}
+
+	// plain list of urls is kept for clients not asking for suggestions, next page cursor is sent in a header
+	var resp any = urls
+	if found.opts.Suggest {
+		resp = &protocol.PicsResponse{Comics: urls, Suggestions: found.result.Suggestions, NextCursor: found.next}
+	}
+	if len(found.next) > 0 {
+		w.Header().Set(headerNextCursor, found.next)
+	}
+
+	if err := protocol.ResponseJson(w, resp); err != nil {
+		log.Error("failed to response", logger.Err(err))
+	}
+}
+
+func (r *router) PicsV2(w http.ResponseWriter, req *http.Request, user *domain.User) {
+	const op = "router.PicsV2"
+	log := r.log.With(slog.String("op", op), slog.String("uname", user.Username))
+
+	log.Debug("handle search")
+
+	found, ok := r.scan(w, req, log, domain.ScanOptions{UseIndex: true, Suggest: true, Snippets: true})
+	if !ok {
+		return
+	}
+
+	resp := &protocol.PicsV2Response{
+		Comics:      make([]*protocol.ComicResponse, len(found.result.Comics)),
+		Suggestions: found.result.Suggestions,
+		NextCursor:  found.next,
+	}
+	for i, scored := range found.result.Comics {
+		resp.Comics[i] = &protocol.ComicResponse{
+			Num:          scored.Comic.Num,
+			Title:        scored.Comic.Title,
+			Img:          scored.Comic.Img,
+			Alt:          scored.Comic.Alt,
+			Score:        scored.Score,
+			MatchedTerms: scored.Terms,
+			Snippet:      scored.Snippet,
+		}
+	}
+
+	if err := protocol.ResponseJson(w, resp); err != nil {
+		log.Error("failed to response", logger.Err(err))
+	}
+}
+
+type scanned struct {
+	opts   domain.ScanOptions
+	result *domain.ScanResult
+	// next is an encoded cursor of the next page, empty for the last page
+	next string
+}
+
+// scan runs the search described by request params on top of default options and responds with an error on failure.
+func (r *router) scan(
+	w http.ResponseWriter,
+	req *http.Request,
+	log *slog.Logger,
+	opts domain.ScanOptions,
+) (*scanned, bool) {
 	if err := req.ParseForm(); err != nil {
 		log.Error("failed to parse form", logger.Err(err))
 		protocol.ResponseError(w, http.StatusBadRequest, "bad request")
-		return
+		return nil, false
 	}
 	if !req.Form.Has(formSearch) {
 		protocol.ResponseError(w, http.StatusBadRequest, "search param required")
-		return
+		return nil, false
+	}
+
+	if req.Form.Has(formFields) {
+		for _, name := range strings.Split(req.FormValue(formFields), ",") {
+			field, ok := domain.ParseField(strings.TrimSpace(name))
+			if !ok {
+				protocol.ResponseError(w, http.StatusBadRequest, "unknown field: "+name)
+				return nil, false
+			}
+			opts.Fields = append(opts.Fields, field)
+		}
+	}
+
+	if req.Form.Has(formFuzzy) {
+		fuzzy, err := strconv.ParseBool(req.FormValue(formFuzzy))
+		if err != nil {
+			protocol.ResponseError(w, http.StatusBadRequest, "fuzzy param must be boolean")
+			return nil, false
+		}
+		opts.Fuzzy = fuzzy
+	}
+
+	if req.Form.Has(formSuggest) {
+		suggest, err := strconv.ParseBool(req.FormValue(formSuggest))
+		if err != nil {
+			protocol.ResponseError(w, http.StatusBadRequest, "suggest param must be boolean")
+			return nil, false
+		}
+		opts.Suggest = suggest
+	}
+
+	opts.Limit = min(r.scanLimit, r.scanMaxLimit)
+	if req.Form.Has(formLimit) {
+		limit, err := strconv.Atoi(req.FormValue(formLimit))
+		if err != nil || limit <= 0 {
+			protocol.ResponseError(w, http.StatusBadRequest, "limit param must be positive integer")
+			return nil, false
+		}
+		opts.Limit = min(limit, r.scanMaxLimit)
 	}
 
 	search := req.FormValue(formSearch)
+	fingerprint := queryFingerprint(search, opts)
+
+	if req.Form.Has(formCursor) {
+		cursor, err := decodeCursor(req.FormValue(formCursor), fingerprint)
+		if err != nil {
+			log.Debug("bad cursor", logger.Err(err))
+			protocol.ResponseError(w, http.StatusBadRequest, "bad cursor")
+			return nil, false
+		}
+		opts.Cursor = cursor
+	}
+
 	ctx, cancel := context.WithTimeout(req.Context(), r.scanTimeout)
 	defer cancel()
-	res, err := r.scanner.Scan(ctx, search, true)
+	res, err := r.scanner.Scan(ctx, search, opts)
 	if err != nil {
-		log.Error("scan error")
+		var queryErr *query.Error
+		if errors.As(err, &queryErr) {
+			protocol.ResponseError(w, http.StatusBadRequest, "bad query: "+queryErr.Error())
+			return nil, false
+		}
+
+		log.Error("scan error", logger.Err(err))
 		protocol.ResponseError(w, http.StatusInternalServerError, "internal error")
-		return
+		return nil, false
 	}
 
 	if ctx.Err() != nil {
 		log.Error("scan timeout exceeded")
 		protocol.ResponseError(w, http.StatusGatewayTimeout, "scan timeout exceeded")
-		return
+		return nil, false
 	}
 
-	if len(res) > r.scanLimit {
-		res = res[:r.scanLimit]
+	found := &scanned{opts: opts, result: res}
+	if res.Next != nil {
+		if found.next, err = encodeCursor(res.Next, fingerprint); err != nil {
+			log.Error("failed to encode cursor", logger.Err(err))
+			protocol.ResponseError(w, http.StatusInternalServerError, "internal error")
+			return nil, false
+		}
 	}
 
-	if err = protocol.ResponseJson(w, res); err != nil {
-		log.Error("failed to response", logger.Err(err))
-	}
+	return found, true
 }
 
 func (r *router) Login(w http.ResponseWriter, req *http.Request) {
@@ -0,0 +1,74 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"yadro-go/internal/adapter/primary"
+	"yadro-go/internal/adapter/primary/http/protocol"
+	"yadro-go/internal/core/domain"
+	"yadro-go/internal/core/service"
+	"yadro-go/test/logger"
+)
+
+// reindexUpdater reindexes for the given time, other methods are not used.
+type reindexUpdater struct {
+	primary.Updater
+	duration time.Duration
+	err      error
+}
+
+func (u *reindexUpdater) Reindex(ctx context.Context) (int, error) {
+	time.Sleep(u.duration)
+	return 42, u.err
+}
+
+func TestRouter_Reindex(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		// the reindex outlasts the write timeout, the response is written when it is done
+		{name: "Synchronous", expectedStatus: http.StatusOK},
+		{name: "UpdateInProgress", err: service.ErrUpdateInProgress, expectedStatus: http.StatusConflict},
+		{name: "Failed", err: service.ErrInternal, expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &router{
+				log:     slog.New(logger.EmptyHandler{}),
+				updater: &reindexUpdater{duration: 100 * time.Millisecond, err: testCase.err},
+			}
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				r.Reindex(w, req, &domain.User{Username: "admin"})
+			}))
+			srv.Config.WriteTimeout = 20 * time.Millisecond
+			srv.Start()
+			defer srv.Close()
+
+			resp, err := http.Post(srv.URL+"/reindex", "", nil)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, testCase.expectedStatus, resp.StatusCode)
+			assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+			if testCase.err == nil {
+				var body protocol.UpdateResponse
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.Equal(t, 42, body.Total)
+			}
+		})
+	}
+}
@@ -6,11 +6,23 @@ import (
 )
 
 type QueryScanner interface {
-	Scan(ctx context.Context, query string, useIndex bool) ([]string, error)
+	Scan(ctx context.Context, query string, opts domain.ScanOptions) (*domain.ScanResult, error)
 }
 
 type Updater interface {
-	Update(ctx context.Context) (int, error)
+	Update(ctx context.Context) (*domain.UpdateResult, error)
+	StartUpdate(
+		ctx context.Context,
+		trigger domain.UpdateTrigger,
+		mode domain.UpdateMode,
+		username string,
+	) (*domain.UpdateJob, error)
+	UpdateJob(id int64) (*domain.UpdateJob, error)
+	CancelUpdate(id int64) (*domain.UpdateJob, error)
+	UpdateRuns(ctx context.Context, limit int, before int64) ([]*domain.UpdateRun, int64, error)
+	Schedule() (*domain.UpdateSchedule, error)
+	SubscribeEvents(lastEventId int64) ([]*domain.UpdateEvent, <-chan *domain.UpdateEvent, func())
+	Reindex(ctx context.Context) (int, error)
 }
 
 type Auth interface {
@@ -0,0 +1,123 @@
+package index
+
+import (
+	"encoding/binary"
+	"errors"
+	"slices"
+	"yadro-go/internal/core/domain"
+)
+
+var errCorruptPostings = errors.New("corrupt posting list")
+
+// encodePostings packs postings sorted by comic number and field into a byte slice:
+// count, then for every posting comic number delta, field code, frequency, number of positions
+// and position deltas, all varint-encoded.
+func encodePostings(postings []domain.Posting) []byte {
+	postings = slices.Clone(postings)
+	slices.SortFunc(postings, comparePostings)
+
+	data := binary.AppendUvarint(nil, uint64(len(postings)))
+	prevNum := 0
+	for _, posting := range postings {
+		data = binary.AppendUvarint(data, uint64(posting.Num-prevNum))
+		data = binary.AppendUvarint(data, uint64(fieldCode(posting.Field)))
+		data = binary.AppendUvarint(data, uint64(posting.Freq))
+		data = binary.AppendUvarint(data, uint64(len(posting.Positions)))
+		prevPos := 0
+		for _, pos := range posting.Positions {
+			data = binary.AppendVarint(data, int64(pos-prevPos))
+			prevPos = pos
+		}
+		prevNum = posting.Num
+	}
+
+	return data
+}
+
+func decodePostings(data []byte) ([]domain.Posting, error) {
+	r := &reader{data: data}
+
+	count := r.uvarint()
+	if r.err != nil || count > uint64(len(data)) {
+		return nil, errCorruptPostings
+	}
+
+	postings := make([]domain.Posting, count)
+	num := 0
+	for i := range postings {
+		num += int(r.uvarint())
+		field := fieldByCode(int(r.uvarint()))
+		freq := int(r.uvarint())
+		positionsCount := r.uvarint()
+		if r.err != nil || positionsCount > uint64(len(data)) {
+			return nil, errCorruptPostings
+		}
+
+		var positions []int
+		if positionsCount > 0 {
+			positions = make([]int, positionsCount)
+			pos := 0
+			for j := range positions {
+				pos += int(r.varint())
+				positions[j] = pos
+			}
+		}
+
+		postings[i] = domain.Posting{Num: num, Field: field, Freq: freq, Positions: positions}
+	}
+
+	if r.err != nil || len(r.data) > 0 {
+		return nil, errCorruptPostings
+	}
+	return postings, nil
+}
+
+func comparePostings(a domain.Posting, b domain.Posting) int {
+	if a.Num != b.Num {
+		return a.Num - b.Num
+	}
+	return fieldCode(a.Field) - fieldCode(b.Field)
+}
+
+// fieldCode is 0 for a posting without field, otherwise it is the field index in domain.Fields plus one.
+func fieldCode(field domain.Field) int {
+	return slices.Index(domain.Fields, field) + 1
+}
+
+func fieldByCode(code int) domain.Field {
+	if code <= 0 || code > len(domain.Fields) {
+		return ""
+	}
+	return domain.Fields[code-1]
+}
+
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = errCorruptPostings
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *reader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.err = errCorruptPostings
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
@@ -0,0 +1,68 @@
+package index
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"yadro-go/internal/core/domain"
+)
+
+func TestCodec(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name     string
+		input    []domain.Posting
+		expected []domain.Posting
+	}{
+		{name: "Empty", input: []domain.Posting{}, expected: []domain.Posting{}},
+		{
+			name: "Sorted",
+			input: []domain.Posting{
+				{Num: 1000, Field: domain.FieldTranscript, Freq: 2, Positions: []int{3, 150}},
+				{Num: 5, Field: domain.FieldAlt, Freq: 1, Positions: []int{0}},
+				{Num: 1000, Field: domain.FieldTitle, Freq: 1, Positions: []int{1}},
+				{Num: 7, Freq: 3},
+			},
+			expected: []domain.Posting{
+				{Num: 5, Field: domain.FieldAlt, Freq: 1, Positions: []int{0}},
+				{Num: 7, Freq: 3},
+				{Num: 1000, Field: domain.FieldTitle, Freq: 1, Positions: []int{1}},
+				{Num: 1000, Field: domain.FieldTranscript, Freq: 2, Positions: []int{3, 150}},
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			decoded, err := decodePostings(encodePostings(testCase.input))
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, decoded)
+		})
+	}
+}
+
+func TestCodec_Compressed(t *testing.T) {
+	t.Parallel()
+
+	postings := make([]domain.Posting, 0)
+	for num := 1; num <= 3000; num += 3 {
+		postings = append(postings, domain.Posting{Num: num, Field: domain.FieldTranscript, Freq: 1, Positions: []int{num % 100}})
+	}
+
+	// at least 4 times smaller than number, field, frequency and position as machine words
+	assert.Less(t, len(encodePostings(postings)), 8*len(postings))
+}
+
+func TestCodec_Corrupt(t *testing.T) {
+	t.Parallel()
+
+	data := encodePostings([]domain.Posting{{Num: 300, Field: domain.FieldAlt, Freq: 2, Positions: []int{1, 200}}})
+
+	for _, corrupt := range [][]byte{data[:len(data)-1], append(data, 0), {0xff}, {0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}} {
+		_, err := decodePostings(corrupt)
+		assert.ErrorIs(t, err, errCorruptPostings)
+	}
+}
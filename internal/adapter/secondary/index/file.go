@@ -0,0 +1,207 @@
+package index
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+const (
+	snapshotMagic   = "XKCDIDX"
+	snapshotVersion = 3
+	// header is magic, version, body length and body checksum
+	snapshotHeaderSize = len(snapshotMagic) + 4 + 8 + 4
+)
+
+var (
+	errSnapshotCorrupt  = errors.New("snapshot is corrupt")
+	errSnapshotVersion  = errors.New("snapshot version mismatch")
+	errSnapshotMismatch = errors.New("snapshot does not match repository")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// writeSnapshot stores the snapshot into a temporary file and renames it, so that the file is always complete.
+func writeSnapshot(path string, s *snapshot) error {
+	body := encodeSnapshot(s)
+
+	header := make([]byte, 0, snapshotHeaderSize)
+	header = append(header, snapshotMagic...)
+	header = binary.LittleEndian.AppendUint32(header, snapshotVersion)
+	header = binary.LittleEndian.AppendUint64(header, uint64(len(body)))
+	header = binary.LittleEndian.AppendUint32(header, crc32.Checksum(body, crcTable))
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(append(header, body...)); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func readSnapshot(path string) (*snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < snapshotHeaderSize || !bytes.HasPrefix(data, []byte(snapshotMagic)) {
+		return nil, errSnapshotCorrupt
+	}
+
+	header := data[len(snapshotMagic):snapshotHeaderSize]
+	if version := binary.LittleEndian.Uint32(header); version != snapshotVersion {
+		return nil, fmt.Errorf("%w: %d", errSnapshotVersion, version)
+	}
+
+	body := data[snapshotHeaderSize:]
+	if binary.LittleEndian.Uint64(header[4:]) != uint64(len(body)) ||
+		binary.LittleEndian.Uint32(header[12:]) != crc32.Checksum(body, crcTable) {
+		return nil, errSnapshotCorrupt
+	}
+
+	return decodeSnapshot(body)
+}
+
+// encodeSnapshot writes the generation, comic lengths and generations, posting lists and surface words
+// sorted by key, all varint-encoded.
+func encodeSnapshot(s *snapshot) []byte {
+	data := binary.AppendVarint(nil, s.generation)
+
+	nums := sortedKeys(s.lengths, cmp.Compare[int])
+	data = binary.AppendUvarint(data, uint64(len(nums)))
+	prevNum := 0
+	for _, num := range nums {
+		data = binary.AppendUvarint(data, uint64(num-prevNum))
+		data = binary.AppendUvarint(data, uint64(s.lengths[num]))
+		data = binary.AppendVarint(data, s.generations[num])
+		prevNum = num
+	}
+
+	words := sortedKeys(s.postings, cmp.Compare[string])
+	data = binary.AppendUvarint(data, uint64(len(words)))
+	for _, word := range words {
+		list := s.postings[word]
+		data = appendString(data, word)
+		data = binary.AppendUvarint(data, uint64(list.docs))
+		data = binary.AppendUvarint(data, uint64(list.raw))
+		data = binary.AppendUvarint(data, uint64(len(list.data)))
+		data = append(data, list.data...)
+	}
+
+	surfaceWords := sortedKeys(s.surfaces, cmp.Compare[string])
+	data = binary.AppendUvarint(data, uint64(len(surfaceWords)))
+	for _, word := range surfaceWords {
+		surfaces := s.surfaces[word]
+		data = appendString(data, word)
+		data = binary.AppendUvarint(data, uint64(len(surfaces)))
+		for _, surface := range sortedKeys(surfaces, cmp.Compare[string]) {
+			data = appendString(data, surface)
+			data = binary.AppendUvarint(data, uint64(surfaces[surface]))
+		}
+	}
+
+	return data
+}
+
+func decodeSnapshot(data []byte) (*snapshot, error) {
+	r := &reader{data: data}
+	s := &snapshot{
+		postings:    make(map[string]*postingList),
+		surfaces:    make(map[string]map[string]int),
+		lengths:     make(map[int]int),
+		generations: make(map[int]int64),
+	}
+
+	s.generation = r.varint()
+
+	num := 0
+	for n := r.count(); n > 0; n-- {
+		num += int(r.uvarint())
+		s.lengths[num] = int(r.uvarint())
+		s.generations[num] = r.varint()
+	}
+
+	for n := r.count(); n > 0; n-- {
+		word := r.string()
+		list := &postingList{docs: int(r.uvarint()), raw: int(r.uvarint())}
+		list.data = r.bytes()
+		s.postings[word] = list
+		s.bytes += len(list.data)
+		s.rawBytes += list.raw
+	}
+
+	for n := r.count(); n > 0; n-- {
+		word := r.string()
+		surfaces := make(map[string]int)
+		for m := r.count(); m > 0; m-- {
+			surface := r.string()
+			surfaces[surface] = int(r.uvarint())
+		}
+		s.surfaces[word] = surfaces
+	}
+
+	if r.err != nil || len(r.data) > 0 {
+		return nil, errSnapshotCorrupt
+	}
+
+	s.updateStats()
+	return s, nil
+}
+
+func appendString(data []byte, str string) []byte {
+	data = binary.AppendUvarint(data, uint64(len(str)))
+	return append(data, str...)
+}
+
+// count reads a number of following items, it can not exceed the number of remaining bytes.
+func (r *reader) count() int {
+	n := r.uvarint()
+	if n > uint64(len(r.data)) {
+		r.err = errCorruptPostings
+		return 0
+	}
+	return int(n)
+}
+
+func (r *reader) bytes() []byte {
+	n := r.count()
+	if r.err != nil {
+		return nil
+	}
+	res := r.data[:n:n]
+	r.data = r.data[n:]
+	return res
+}
+
+func (r *reader) string() string {
+	return string(r.bytes())
+}
+
+func sortedKeys[K comparable, V any](m map[K]V, compare func(a, b K) int) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, compare)
+	return keys
+}
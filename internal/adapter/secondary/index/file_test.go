@@ -0,0 +1,60 @@
+package index
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"yadro-go/internal/core/domain"
+)
+
+func TestSnapshotFile(t *testing.T) {
+	t.Parallel()
+
+	snap := newSnapshot([]*domain.ComicKeyword{
+		{
+			Word: "python",
+			Postings: []domain.Posting{
+				{Num: 1, Field: domain.FieldTitle, Freq: 1, Positions: []int{0}},
+				{Num: 20, Field: domain.FieldAlt, Freq: 2, Positions: []int{1, 4}},
+			},
+			Surfaces: map[string]int{"python": 2, "pythons": 1},
+		},
+		{Word: "code", Postings: []domain.Posting{{Num: 20, Freq: 1}}},
+	}, map[int]int{1: 4, 20: 6}, map[int]int64{1: 1, 20: 3})
+	snap.setGeneration(3)
+
+	path := filepath.Join(t.TempDir(), "index.snapshot")
+	require.NoError(t, writeSnapshot(path, snap))
+
+	read, err := readSnapshot(path)
+	require.NoError(t, err)
+	assert.Equal(t, snap, read)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	corrupt := func(change func(data []byte)) {
+		changed := append([]byte(nil), data...)
+		change(changed)
+		require.NoError(t, os.WriteFile(path, changed, 0o600))
+	}
+
+	corrupt(func(data []byte) { data[len(data)-1]++ })
+	_, err = readSnapshot(path)
+	assert.ErrorIs(t, err, errSnapshotCorrupt)
+
+	corrupt(func(data []byte) { data[0] = 'Y' })
+	_, err = readSnapshot(path)
+	assert.ErrorIs(t, err, errSnapshotCorrupt)
+
+	corrupt(func(data []byte) { binary.LittleEndian.PutUint32(data[len(snapshotMagic):], snapshotVersion+1) })
+	_, err = readSnapshot(path)
+	assert.ErrorIs(t, err, errSnapshotVersion)
+
+	require.NoError(t, os.WriteFile(path, data[:len(data)-1], 0o600))
+	_, err = readSnapshot(path)
+	assert.ErrorIs(t, err, errSnapshotCorrupt)
+}
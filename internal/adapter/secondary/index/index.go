@@ -0,0 +1,575 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+	"yadro-go/internal/adapter/secondary"
+	"yadro-go/internal/core/domain"
+	"yadro-go/pkg/logger"
+)
+
+// metrics of the current index, published with expvar
+var metrics = expvar.NewMap("index")
+
+// Repository is the source of truth the index is loaded from and written through to.
+type Repository interface {
+	AllKeywords(ctx context.Context) ([]*domain.ComicKeyword, error)
+	AllLengths(ctx context.Context) (map[int]int, error)
+	AllGenerations(ctx context.Context) (map[int]int64, error)
+	// Surfaces returns surface counts of the words summed over all comics
+	Surfaces(ctx context.Context, words []string) (map[string]map[string]int, error)
+	Stats(ctx context.Context) (*domain.IndexStats, error)
+	// Generation changes with every Save and Reindex
+	Generation(ctx context.Context) (int64, error)
+	Save(ctx context.Context, keywords []*domain.ComicKeyword, lengths map[int]int) error
+	Reindex(ctx context.Context, keywords []*domain.ComicKeyword, lengths map[int]int) error
+}
+
+// Index serves keyword lookups from memory. Readers use an immutable snapshot,
+// writes go to the repository first and then a new snapshot is swapped in.
+type Index struct {
+	log          *slog.Logger
+	repo         Repository
+	snapshotPath string
+	writeMu      *sync.Mutex
+	current      atomic.Pointer[snapshot]
+}
+
+type Option func(*Index)
+
+// SnapshotPath enables the on-disk snapshot, it is written after every change and read by Load.
+func SnapshotPath(path string) Option {
+	return func(i *Index) {
+		i.snapshotPath = path
+	}
+}
+
+func New(log *slog.Logger, repo Repository, opts ...Option) *Index {
+	i := &Index{
+		log:     log,
+		repo:    repo,
+		writeMu: &sync.Mutex{},
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	i.current.Store(newSnapshot(nil, nil, nil))
+	return i
+}
+
+// Load reads the index from the snapshot file. A missing, corrupt or outdated snapshot
+// is rebuilt from the repository and written again.
+func (i *Index) Load(ctx context.Context) error {
+	const op = "index.Load"
+	log := i.log.With(slog.String("op", op))
+
+	i.writeMu.Lock()
+	defer i.writeMu.Unlock()
+
+	start := time.Now()
+
+	if i.snapshotPath != "" {
+		snap, err := i.loadSnapshot(ctx)
+		if err == nil {
+			log.Info("index loaded from snapshot " + i.snapshotPath)
+			i.swap(log, snap, start)
+			return nil
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			log.Info("index snapshot not found, building from repository")
+		} else {
+			log.Warn("index snapshot is invalid, rebuilding from repository", logger.Err(err))
+		}
+	}
+
+	snap, err := i.build(ctx, log)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	i.swap(log, snap, start)
+	i.persist(log, snap)
+	return nil
+}
+
+// build makes a snapshot of the whole repository.
+func (i *Index) build(ctx context.Context, log *slog.Logger) (*snapshot, error) {
+	// the generation is read first, a write made while loading makes the index outdated, not wrongly current
+	generation, err := i.repo.Generation(ctx)
+	if err != nil {
+		log.Error("failed to load index generation", logger.Err(err))
+		return nil, err
+	}
+
+	keywords, err := i.repo.AllKeywords(ctx)
+	if err != nil {
+		log.Error("failed to load keywords", logger.Err(err))
+		return nil, err
+	}
+
+	lengths, err := i.repo.AllLengths(ctx)
+	if err != nil {
+		log.Error("failed to load lengths", logger.Err(err))
+		return nil, err
+	}
+
+	generations, err := i.repo.AllGenerations(ctx)
+	if err != nil {
+		log.Error("failed to load generations", logger.Err(err))
+		return nil, err
+	}
+
+	snap := newSnapshot(keywords, lengths, generations)
+	snap.setGeneration(generation)
+	return snap, nil
+}
+
+// Reload builds the index from the repository again when the repository has been written
+// since the index was loaded, e.g. by another instance.
+func (i *Index) Reload(ctx context.Context) error {
+	const op = "index.Reload"
+	log := i.log.With(slog.String("op", op))
+
+	i.writeMu.Lock()
+	defer i.writeMu.Unlock()
+
+	generation, err := i.repo.Generation(ctx)
+	if err != nil {
+		log.Error("failed to load index generation", logger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	current := i.current.Load()
+	if generation == current.generation {
+		return nil
+	}
+
+	log.Info(fmt.Sprintf("index is outdated: generation %d, %d in repository, reloading", current.generation, generation))
+
+	start := time.Now()
+	snap, err := i.build(ctx, log)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	i.swap(log, snap, start)
+	i.persist(log, snap)
+	return nil
+}
+
+// loadSnapshot reads the snapshot file and checks it was written at the current generation of the repository.
+func (i *Index) loadSnapshot(ctx context.Context) (*snapshot, error) {
+	snap, err := readSnapshot(i.snapshotPath)
+	if err != nil {
+		return nil, err
+	}
+
+	generation, err := i.repo.Generation(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if generation != snap.generation {
+		return nil, fmt.Errorf("%w: generation %d in snapshot, %d in repository",
+			errSnapshotMismatch, snap.generation, generation)
+	}
+
+	return snap, nil
+}
+
+func (i *Index) Keywords(ctx context.Context, words []string) ([]*domain.ComicKeyword, error) {
+	const op = "index.Keywords"
+
+	snap := i.current.Load()
+
+	res := make([]*domain.ComicKeyword, 0, len(words))
+	for _, word := range words {
+		list, ok := snap.postings[word]
+		if !ok {
+			continue
+		}
+
+		postings, err := decodePostings(list.data)
+		if err != nil {
+			i.log.Error("failed to decode postings", slog.String("op", op), slog.String("word", word), logger.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+		}
+		res = append(res, &domain.ComicKeyword{Word: word, Postings: postings})
+	}
+
+	return res, ctx.Err()
+}
+
+func (i *Index) Lengths(_ context.Context, nums []int) (map[int]int, error) {
+	snap := i.current.Load()
+
+	res := make(map[int]int, len(nums))
+	for _, num := range nums {
+		if length, ok := snap.lengths[num]; ok {
+			res[num] = length
+		}
+	}
+	return res, nil
+}
+
+// Generations returns generations the comics were first indexed at.
+func (i *Index) Generations(_ context.Context, nums []int) (map[int]int64, error) {
+	snap := i.current.Load()
+
+	res := make(map[int]int64, len(nums))
+	for _, num := range nums {
+		if generation, ok := snap.generations[num]; ok {
+			res[num] = generation
+		}
+	}
+	return res, nil
+}
+
+func (i *Index) Stats(_ context.Context) (*domain.IndexStats, error) {
+	stats := i.current.Load().stats
+	return &stats, nil
+}
+
+func (i *Index) Vocabulary(_ context.Context) ([]*domain.VocabularyWord, error) {
+	snap := i.current.Load()
+
+	res := make([]*domain.VocabularyWord, 0, len(snap.postings))
+	for word, list := range snap.postings {
+		res = append(res, &domain.VocabularyWord{Word: word, DocFreq: list.docs, Surface: snap.surface(word)})
+	}
+	return res, nil
+}
+
+// Save writes keywords to the repository and swaps in a snapshot with them applied. Surface counts
+// of the changed words are read back from the repository, which has replaced counts of the saved comics.
+func (i *Index) Save(ctx context.Context, keywords []*domain.ComicKeyword, lengths map[int]int) error {
+	const op = "index.Save"
+	log := i.log.With(slog.String("op", op))
+
+	i.writeMu.Lock()
+	defer i.writeMu.Unlock()
+
+	if err := i.repo.Save(ctx, keywords, lengths); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	snap, err := i.apply(ctx, log, func(generation int64) (*snapshot, error) {
+		snap, words := i.current.Load().merge(keywords, lengths, generation)
+		surfaces, err := i.repo.Surfaces(ctx, words)
+		if err != nil {
+			return nil, err
+		}
+		snap.setSurfaces(words, surfaces)
+		return snap, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	i.swap(log, snap, start)
+	i.persist(log, snap)
+	return nil
+}
+
+// Reindex replaces the whole index in the repository and swaps in a snapshot built from the keywords.
+func (i *Index) Reindex(ctx context.Context, keywords []*domain.ComicKeyword, lengths map[int]int) error {
+	const op = "index.Reindex"
+	log := i.log.With(slog.String("op", op))
+
+	i.writeMu.Lock()
+	defer i.writeMu.Unlock()
+
+	if err := i.repo.Reindex(ctx, keywords, lengths); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	snap, err := i.apply(ctx, log, func(generation int64) (*snapshot, error) {
+		current := i.current.Load()
+		generations := make(map[int]int64, len(lengths))
+		for num := range lengths {
+			if old, ok := current.generations[num]; ok {
+				generations[num] = old
+			} else {
+				generations[num] = generation
+			}
+		}
+		return newSnapshot(keywords, lengths, generations), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	i.swap(log, snap, start)
+	i.persist(log, snap)
+	return nil
+}
+
+// apply makes a snapshot with a write applied at the repository generation read after it. Writes are
+// serialized by writeMu and, between instances, by the update lease, so it is the generation of this write.
+// When the write can not be applied, the snapshot is built from the repository instead.
+func (i *Index) apply(
+	ctx context.Context,
+	log *slog.Logger,
+	update func(generation int64) (*snapshot, error),
+) (*snapshot, error) {
+	generation, err := i.repo.Generation(ctx)
+	if err != nil {
+		log.Warn("failed to read index generation, building from repository", logger.Err(err))
+		return i.build(ctx, log)
+	}
+
+	snap, err := update(generation)
+	if err != nil {
+		log.Warn("failed to apply write, building from repository", logger.Err(err))
+		return i.build(ctx, log)
+	}
+
+	snap.setGeneration(generation)
+	return snap, nil
+}
+
+// persist writes the snapshot file. A failed write is only logged, the repository stays
+// the source of truth and the file is rebuilt on the next Load.
+func (i *Index) persist(log *slog.Logger, snap *snapshot) {
+	if i.snapshotPath == "" {
+		return
+	}
+
+	if err := writeSnapshot(i.snapshotPath, snap); err != nil {
+		log.Error("failed to write index snapshot", logger.Err(err))
+	}
+}
+
+func (i *Index) swap(log *slog.Logger, snap *snapshot, start time.Time) {
+	i.current.Store(snap)
+	elapsed := time.Since(start)
+
+	metrics.Set("words", intVar(len(snap.postings)))
+	metrics.Set("docs", intVar(snap.stats.Docs))
+	metrics.Set("postings_bytes", intVar(snap.bytes))
+	metrics.Set("build_ms", intVar(int(elapsed.Milliseconds())))
+	metrics.Add("builds", 1)
+
+	log.Info(fmt.Sprintf("index built in %v: %d words, %d comics, postings take %d bytes (%d uncompressed)",
+		elapsed, len(snap.postings), snap.stats.Docs, snap.bytes, snap.rawBytes))
+}
+
+func intVar(v int) *expvar.Int {
+	res := new(expvar.Int)
+	res.Set(int64(v))
+	return res
+}
+
+type postingList struct {
+	// docs is the number of distinct comics in the list
+	docs int
+	raw  int
+	data []byte
+}
+
+// snapshot is never modified after creation, updates make a new one sharing unchanged posting lists.
+type snapshot struct {
+	postings map[string]*postingList
+	surfaces map[string]map[string]int
+	lengths  map[int]int
+	// generations holds generations the comics were first indexed at
+	generations map[int]int64
+	stats       domain.IndexStats
+	// generation of the repository the snapshot was built at
+	generation int64
+	bytes      int
+	rawBytes   int
+}
+
+func newSnapshot(keywords []*domain.ComicKeyword, lengths map[int]int, generations map[int]int64) *snapshot {
+	s := &snapshot{
+		postings:    make(map[string]*postingList, len(keywords)),
+		surfaces:    make(map[string]map[string]int, len(keywords)),
+		lengths:     make(map[int]int, len(lengths)),
+		generations: make(map[int]int64, len(generations)),
+	}
+
+	for _, keyword := range keywords {
+		s.setPostings(keyword.Word, keyword.Postings)
+		if len(keyword.Surfaces) > 0 {
+			s.surfaces[keyword.Word] = maps.Clone(keyword.Surfaces)
+		}
+	}
+	maps.Copy(s.lengths, lengths)
+	maps.Copy(s.generations, generations)
+	s.updateStats()
+
+	return s
+}
+
+// merge returns a snapshot with keywords applied and the words which postings have changed.
+// Postings of comics in lengths are replaced by the keywords ones, postings of other comics are replaced
+// by the same comic and field. Comics indexed for the first time get the generation. Surface counts
+// of the changed words are left as they were, they have to be set from the repository.
+func (s *snapshot) merge(keywords []*domain.ComicKeyword, lengths map[int]int, generation int64) (*snapshot, []string) {
+	res := &snapshot{
+		postings:    maps.Clone(s.postings),
+		surfaces:    maps.Clone(s.surfaces),
+		lengths:     maps.Clone(s.lengths),
+		generations: maps.Clone(s.generations),
+		bytes:       s.bytes,
+		rawBytes:    s.rawBytes,
+	}
+
+	// reindexed comics, which may have postings of words they no longer contain
+	reindexed := make(map[int]struct{})
+	for num := range lengths {
+		if _, ok := s.lengths[num]; ok {
+			reindexed[num] = struct{}{}
+		}
+	}
+
+	updated := make(map[string]struct{}, len(keywords))
+	words := make([]string, 0, len(keywords))
+	for _, keyword := range keywords {
+		updated[keyword.Word] = struct{}{}
+		words = append(words, keyword.Word)
+
+		postings := keyword.Postings
+		if list, ok := s.postings[keyword.Word]; ok {
+			// snapshot lists are always valid, they are encoded from postings
+			existing, _ := decodePostings(list.data)
+			postings = mergePostings(existing, postings, reindexed)
+		}
+		res.setPostings(keyword.Word, postings)
+	}
+
+	if len(reindexed) > 0 {
+		for word, list := range s.postings {
+			if _, ok := updated[word]; ok {
+				continue
+			}
+
+			existing, _ := decodePostings(list.data)
+			postings := mergePostings(existing, nil, reindexed)
+			switch {
+			case len(postings) == len(existing):
+				continue
+			case len(postings) == 0:
+				res.deletePostings(word)
+			default:
+				res.setPostings(word, postings)
+			}
+			words = append(words, word)
+		}
+	}
+
+	maps.Copy(res.lengths, lengths)
+	for num := range lengths {
+		if _, ok := res.generations[num]; !ok {
+			res.generations[num] = generation
+		}
+	}
+	res.updateStats()
+
+	return res, words
+}
+
+// setSurfaces replaces surface counts of the words, words without counts have none.
+func (s *snapshot) setSurfaces(words []string, surfaces map[string]map[string]int) {
+	for _, word := range words {
+		if len(surfaces[word]) > 0 {
+			s.surfaces[word] = surfaces[word]
+		} else {
+			delete(s.surfaces, word)
+		}
+	}
+}
+
+func (s *snapshot) setPostings(word string, postings []domain.Posting) {
+	if old, ok := s.postings[word]; ok {
+		s.bytes -= len(old.data)
+		s.rawBytes -= old.raw
+	}
+
+	nums := make(map[int]struct{}, len(postings))
+	raw := 0
+	for _, posting := range postings {
+		nums[posting.Num] = struct{}{}
+		// comic number, field, frequency and positions as machine words
+		raw += 8 * (3 + len(posting.Positions))
+	}
+
+	list := &postingList{docs: len(nums), raw: raw, data: encodePostings(postings)}
+	s.postings[word] = list
+	s.bytes += len(list.data)
+	s.rawBytes += list.raw
+}
+
+func (s *snapshot) deletePostings(word string) {
+	if old, ok := s.postings[word]; ok {
+		s.bytes -= len(old.data)
+		s.rawBytes -= old.raw
+		delete(s.postings, word)
+		delete(s.surfaces, word)
+	}
+}
+
+func (s *snapshot) setGeneration(generation int64) {
+	s.generation = generation
+	s.stats.Generation = generation
+}
+
+func (s *snapshot) updateStats() {
+	s.stats = domain.IndexStats{Docs: len(s.lengths), Generation: s.generation}
+	total := 0
+	for num, length := range s.lengths {
+		total += length
+		s.stats.MaxNum = max(s.stats.MaxNum, num)
+	}
+	if len(s.lengths) > 0 {
+		s.stats.AvgLength = float64(total) / float64(len(s.lengths))
+	}
+}
+
+// surface returns the most common original word of the stem, the stem itself if unknown.
+func (s *snapshot) surface(word string) string {
+	best, bestCount := word, 0
+	for surface, count := range s.surfaces[word] {
+		if count > bestCount || count == bestCount && surface < best {
+			best, bestCount = surface, count
+		}
+	}
+	return best
+}
+
+// mergePostings replaces existing postings by updated ones of the same comic and field,
+// existing postings of reindexed comics are dropped.
+func mergePostings(existing []domain.Posting, updated []domain.Posting, reindexed map[int]struct{}) []domain.Posting {
+	type key struct {
+		num   int
+		field domain.Field
+	}
+
+	res := make([]domain.Posting, 0, len(existing)+len(updated))
+	replaced := make(map[key]struct{}, len(updated))
+	for _, posting := range updated {
+		replaced[key{num: posting.Num, field: posting.Field}] = struct{}{}
+		res = append(res, posting)
+	}
+	for _, posting := range existing {
+		if _, ok := reindexed[posting.Num]; ok {
+			continue
+		}
+		if _, ok := replaced[key{num: posting.Num, field: posting.Field}]; !ok {
+			res = append(res, posting)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,294 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+	"yadro-go/internal/core/domain"
+	"yadro-go/test/logger"
+)
+
+type repositoryStub struct {
+	keywords    []*domain.ComicKeyword
+	lengths     map[int]int
+	generation  int64
+	generations map[int]int64
+	// surfaces are counts summed over all comics, as the repository has them after a save
+	surfaces map[string]map[string]int
+	saveErr  error
+	saved    int
+	loaded   int
+}
+
+func (r *repositoryStub) AllKeywords(context.Context) ([]*domain.ComicKeyword, error) {
+	r.loaded++
+	return r.keywords, nil
+}
+
+func (r *repositoryStub) Stats(context.Context) (*domain.IndexStats, error) {
+	snap := newSnapshot(nil, r.lengths, nil)
+	snap.setGeneration(r.generation)
+	return &snap.stats, nil
+}
+
+func (r *repositoryStub) Generation(context.Context) (int64, error) {
+	return r.generation, nil
+}
+
+func (r *repositoryStub) AllLengths(context.Context) (map[int]int, error) {
+	return r.lengths, nil
+}
+
+func (r *repositoryStub) AllGenerations(context.Context) (map[int]int64, error) {
+	return r.generations, nil
+}
+
+func (r *repositoryStub) Surfaces(_ context.Context, words []string) (map[string]map[string]int, error) {
+	res := make(map[string]map[string]int)
+	for _, word := range words {
+		if surfaces, ok := r.surfaces[word]; ok {
+			res[word] = surfaces
+		}
+	}
+	return res, nil
+}
+
+func (r *repositoryStub) Save(_ context.Context, _ []*domain.ComicKeyword, lengths map[int]int) error {
+	r.saved++
+	if r.saveErr != nil {
+		return r.saveErr
+	}
+
+	r.generation++
+	if r.generations == nil {
+		r.generations = make(map[int]int64)
+	}
+	for num := range lengths {
+		if _, ok := r.generations[num]; !ok {
+			r.generations[num] = r.generation
+		}
+	}
+	return nil
+}
+
+func (r *repositoryStub) Reindex(ctx context.Context, keywords []*domain.ComicKeyword, lengths map[int]int) error {
+	if err := r.Save(ctx, keywords, lengths); err != nil {
+		return err
+	}
+	maps.DeleteFunc(r.generations, func(num int, _ int64) bool {
+		_, ok := lengths[num]
+		return !ok
+	})
+	return nil
+}
+
+func TestIndex(t *testing.T) {
+	t.Parallel()
+
+	repo := &repositoryStub{
+		keywords: []*domain.ComicKeyword{
+			{
+				Word: "python",
+				Postings: []domain.Posting{
+					{Num: 1, Field: domain.FieldTitle, Freq: 1, Positions: []int{0}},
+					{Num: 2, Field: domain.FieldAlt, Freq: 2, Positions: []int{1, 4}},
+				},
+				Surfaces: map[string]int{"python": 2, "pythons": 1},
+			},
+			{Word: "code", Postings: []domain.Posting{{Num: 2, Field: domain.FieldAlt, Freq: 1, Positions: []int{2}}}},
+		},
+		lengths:     map[int]int{1: 4, 2: 6},
+		generations: map[int]int64{1: 0, 2: 0},
+	}
+
+	idx := New(slog.New(logger.EmptyHandler{}), repo)
+	require.NoError(t, idx.Load(context.Background()))
+
+	keywords, err := idx.Keywords(context.Background(), []string{"python", "unknown"})
+	require.NoError(t, err)
+	assert.Equal(t, repo.keywords[0].Postings, keywords[0].Postings)
+	assert.Len(t, keywords, 1)
+
+	stats, err := idx.Stats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &domain.IndexStats{Docs: 2, AvgLength: 5, MaxNum: 2}, stats)
+
+	vocabulary, err := idx.Vocabulary(context.Background())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []*domain.VocabularyWord{
+		{Word: "python", DocFreq: 2, Surface: "python"},
+		{Word: "code", DocFreq: 1, Surface: "code"},
+	}, vocabulary)
+
+	// comic 2 is reindexed without "code" and comic 3 is added, the repository has replaced surface counts
+	// of comic 2, which are not added to the old ones
+	repo.surfaces = map[string]map[string]int{"python": {"python": 3, "pythons": 2}}
+	err = idx.Save(context.Background(), []*domain.ComicKeyword{
+		{Word: "python", Postings: []domain.Posting{
+			{Num: 2, Field: domain.FieldAlt, Freq: 1, Positions: []int{0}},
+			{Num: 3, Field: domain.FieldTitle, Freq: 1, Positions: []int{0}},
+		}, Surfaces: map[string]int{"pythons": 5}},
+	}, map[int]int{2: 2, 3: 3})
+	require.NoError(t, err)
+	assert.Equal(t, 1, repo.saved)
+
+	keywords, err = idx.Keywords(context.Background(), []string{"python"})
+	require.NoError(t, err)
+	assert.Equal(t, []domain.Posting{
+		{Num: 1, Field: domain.FieldTitle, Freq: 1, Positions: []int{0}},
+		{Num: 2, Field: domain.FieldAlt, Freq: 1, Positions: []int{0}},
+		{Num: 3, Field: domain.FieldTitle, Freq: 1, Positions: []int{0}},
+	}, keywords[0].Postings)
+
+	lengths, err := idx.Lengths(context.Background(), []int{1, 3, 4})
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int{1: 4, 3: 3}, lengths)
+
+	stats, err = idx.Stats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &domain.IndexStats{Docs: 3, AvgLength: 3, MaxNum: 3, Generation: 1}, stats)
+
+	// reindexed comic 2 keeps its generation, new comic 3 gets the one of the write
+	generations, err := idx.Generations(context.Background(), []int{1, 2, 3, 4})
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int64{1: 0, 2: 0, 3: 1}, generations)
+
+	keywords, err = idx.Keywords(context.Background(), []string{"code"})
+	require.NoError(t, err)
+	assert.Empty(t, keywords)
+
+	vocabulary, err = idx.Vocabulary(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []*domain.VocabularyWord{{Word: "python", DocFreq: 3, Surface: "python"}}, vocabulary)
+}
+
+func TestIndex_SaveFailed(t *testing.T) {
+	t.Parallel()
+
+	repo := &repositoryStub{saveErr: errors.New("save failed")}
+	idx := New(slog.New(logger.EmptyHandler{}), repo)
+
+	err := idx.Save(context.Background(), []*domain.ComicKeyword{
+		{Word: "python", Postings: []domain.Posting{{Num: 1, Freq: 1}}},
+	}, map[int]int{1: 1})
+	require.Error(t, err)
+
+	keywords, err := idx.Keywords(context.Background(), []string{"python"})
+	require.NoError(t, err)
+	assert.Empty(t, keywords)
+}
+
+func TestIndex_Snapshot(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "index.snapshot")
+	repo := &repositoryStub{
+		keywords: []*domain.ComicKeyword{
+			{Word: "python", Postings: []domain.Posting{{Num: 1, Field: domain.FieldTitle, Freq: 1, Positions: []int{0}}}},
+		},
+		lengths: map[int]int{1: 4},
+	}
+
+	// no snapshot yet, the index is built from the repository and written
+	idx := New(slog.New(logger.EmptyHandler{}), repo, SnapshotPath(path))
+	require.NoError(t, idx.Load(context.Background()))
+	assert.Equal(t, 1, repo.loaded)
+	require.FileExists(t, path)
+
+	err := idx.Save(context.Background(), []*domain.ComicKeyword{
+		{Word: "code", Postings: []domain.Posting{{Num: 2, Field: domain.FieldAlt, Freq: 1, Positions: []int{3}}}},
+	}, map[int]int{2: 6})
+	require.NoError(t, err)
+	repo.lengths[2] = 6
+
+	// snapshot matches the repository and is used as is
+	idx = New(slog.New(logger.EmptyHandler{}), repo, SnapshotPath(path))
+	require.NoError(t, idx.Load(context.Background()))
+	assert.Equal(t, 1, repo.loaded)
+
+	keywords, err := idx.Keywords(context.Background(), []string{"code"})
+	require.NoError(t, err)
+	require.Len(t, keywords, 1)
+	assert.Equal(t, []domain.Posting{{Num: 2, Field: domain.FieldAlt, Freq: 1, Positions: []int{3}}}, keywords[0].Postings)
+
+	// another instance has reindexed comic 2 with the same length, only the generation tells the snapshot is outdated
+	repo.keywords = append(repo.keywords, &domain.ComicKeyword{
+		Word: "rust", Postings: []domain.Posting{{Num: 2, Field: domain.FieldAlt, Freq: 1, Positions: []int{3}}},
+	})
+	repo.generation++
+	require.NoError(t, New(slog.New(logger.EmptyHandler{}), repo, SnapshotPath(path)).Load(context.Background()))
+	assert.Equal(t, 2, repo.loaded)
+
+	// rewritten snapshot matches again
+	require.NoError(t, New(slog.New(logger.EmptyHandler{}), repo, SnapshotPath(path)).Load(context.Background()))
+	assert.Equal(t, 2, repo.loaded)
+
+	require.NoError(t, os.WriteFile(path, []byte("garbage"), 0o600))
+	require.NoError(t, New(slog.New(logger.EmptyHandler{}), repo, SnapshotPath(path)).Load(context.Background()))
+	assert.Equal(t, 3, repo.loaded)
+}
+
+func TestIndex_Reload(t *testing.T) {
+	t.Parallel()
+
+	repo := &repositoryStub{
+		keywords: []*domain.ComicKeyword{{Word: "python", Postings: []domain.Posting{{Num: 1, Freq: 1}}}},
+		lengths:  map[int]int{1: 1},
+	}
+	idx := New(slog.New(logger.EmptyHandler{}), repo)
+	require.NoError(t, idx.Load(context.Background()))
+
+	// the repository hasn't been written since the load
+	require.NoError(t, idx.Reload(context.Background()))
+	assert.Equal(t, 1, repo.loaded)
+
+	// another instance has indexed comic 2
+	repo.keywords = append(repo.keywords, &domain.ComicKeyword{Word: "code", Postings: []domain.Posting{{Num: 2, Freq: 1}}})
+	repo.lengths[2] = 1
+	repo.generation++
+	require.NoError(t, idx.Reload(context.Background()))
+	assert.Equal(t, 2, repo.loaded)
+
+	keywords, err := idx.Keywords(context.Background(), []string{"code"})
+	require.NoError(t, err)
+	assert.Len(t, keywords, 1)
+
+	stats, err := idx.Stats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &domain.IndexStats{Docs: 2, AvgLength: 1, MaxNum: 2, Generation: 1}, stats)
+}
+
+func TestIndex_Reindex(t *testing.T) {
+	t.Parallel()
+
+	repo := &repositoryStub{
+		keywords: []*domain.ComicKeyword{{Word: "python", Postings: []domain.Posting{{Num: 1, Freq: 1}}}},
+		lengths:  map[int]int{1: 1},
+	}
+	idx := New(slog.New(logger.EmptyHandler{}), repo)
+	require.NoError(t, idx.Load(context.Background()))
+
+	err := idx.Reindex(context.Background(), []*domain.ComicKeyword{
+		{Word: "code", Postings: []domain.Posting{{Num: 2, Freq: 1}}},
+	}, map[int]int{2: 4})
+	require.NoError(t, err)
+	assert.Equal(t, 1, repo.saved)
+
+	keywords, err := idx.Keywords(context.Background(), []string{"python", "code"})
+	require.NoError(t, err)
+	assert.Equal(t, []*domain.ComicKeyword{{Word: "code", Postings: []domain.Posting{{Num: 2, Freq: 1}}}}, keywords)
+
+	stats, err := idx.Stats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &domain.IndexStats{Docs: 1, AvgLength: 4, MaxNum: 2, Generation: 1}, stats)
+
+	generations, err := idx.Generations(context.Background(), []int{1, 2})
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int64{2: 1}, generations)
+}
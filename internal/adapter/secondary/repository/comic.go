@@ -12,9 +12,16 @@ import (
 )
 
 const (
-	querySelectAllComics          = "SELECT * FROM comics"
-	statementInsertOrReplaceComic = "INSERT OR REPLACE INTO comics(num, title, transcript, alt, img) VALUES (?, ?, ?, ?, ?)"
-	formatStatementSelectComics   = "SELECT * FROM comics WHERE num IN (%s)"
+	querySelectAllComics = "SELECT * FROM comics"
+	// unlike REPLACE, an upsert fires update triggers, which keep comics_fts in sync
+	statementUpsertComic = `
+		INSERT INTO comics(num, title, transcript, alt, img) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(num) DO UPDATE SET
+			title = excluded.title, transcript = excluded.transcript, alt = excluded.alt, img = excluded.img`
+	formatStatementSelectComics  = "SELECT * FROM comics WHERE num IN (%s)"
+	querySelectMissing           = "SELECT num FROM missing_comics"
+	statementInsertMissingComics = "INSERT OR IGNORE INTO missing_comics(num) VALUES (?)"
+	statementDeleteMissingComic  = "DELETE FROM missing_comics WHERE num = ?"
 )
 
 type ComicRepository struct {
@@ -118,13 +125,22 @@ func (r *ComicRepository) Save(ctx context.Context, comics []*domain.Comic) erro
 		return fmt.Errorf("%s: %w", op, secondary.ErrInternal)
 	}
 
-	stmt, err := tx.PrepareContext(ctx, statementInsertOrReplaceComic)
+	stmt, err := tx.PrepareContext(ctx, statementUpsertComic)
 	if err != nil {
 		log.Error("failed to prepare statement", logger.Err(err))
 		return fmt.Errorf("%s: %w", op, secondary.ErrInternal)
 	}
 	defer stmt.Close()
 
+	// a comic recorded as missing may have been published since
+	deleteMissingStmt, err := tx.PrepareContext(ctx, statementDeleteMissingComic)
+	if err != nil {
+		log.Error("failed to prepare statement", logger.Err(err))
+		rollback(log, tx)
+		return fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+	defer deleteMissingStmt.Close()
+
 	for _, comic := range comics {
 		_, err = stmt.ExecContext(ctx, comic.Num, comic.Title, comic.Transcript, comic.Alt, comic.Img)
 		if err != nil {
@@ -134,6 +150,12 @@ func (r *ComicRepository) Save(ctx context.Context, comics []*domain.Comic) erro
 			}
 			return fmt.Errorf("%s: %w", op, secondary.ErrInternal)
 		}
+
+		if _, err = deleteMissingStmt.ExecContext(ctx, comic.Num); err != nil {
+			log.Error("failed to execute statement", logger.Err(err))
+			rollback(log, tx)
+			return fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+		}
 	}
 
 	if err = tx.Commit(); err != nil {
@@ -144,3 +166,73 @@ func (r *ComicRepository) Save(ctx context.Context, comics []*domain.Comic) erro
 	log.Debug("save comics complete")
 	return nil
 }
+
+func (r *ComicRepository) Missing(ctx context.Context) ([]int, error) {
+	const op = "comic.Missing"
+	log := r.log.With(slog.String("op", op))
+
+	log.Debug("fetching missing comics")
+
+	rows, err := r.db.QueryContext(ctx, querySelectMissing)
+	if err != nil {
+		log.Error("failed to query missing comics", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+	defer rows.Close()
+
+	res := make([]int, 0)
+	for rows.Next() {
+		var num int
+		if err = rows.Scan(&num); err != nil {
+			log.Error("failed to decode missing comic", logger.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+		}
+		res = append(res, num)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error("error during rows iteration", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+
+	log.Debug("fetch missing comics complete")
+
+	return res, nil
+}
+
+func (r *ComicRepository) SaveMissing(ctx context.Context, nums []int) error {
+	const op = "comic.SaveMissing"
+	log := r.log.With(slog.String("op", op))
+
+	log.Debug("saving missing comics")
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("failed to start a transaction", logger.Err(err))
+		return fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, statementInsertMissingComics)
+	if err != nil {
+		log.Error("failed to prepare statement", logger.Err(err))
+		rollback(log, tx)
+		return fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+	defer stmt.Close()
+
+	for _, num := range nums {
+		if _, err = stmt.ExecContext(ctx, num); err != nil {
+			log.Error("failed to execute statement", logger.Err(err))
+			rollback(log, tx)
+			return fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("tx commit failed", logger.Err(err))
+		return fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+
+	log.Debug("save missing comics complete")
+	return nil
+}
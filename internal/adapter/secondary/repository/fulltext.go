@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math"
+	"yadro-go/internal/adapter/secondary"
+	"yadro-go/internal/core/domain"
+	"yadro-go/pkg/logger"
+)
+
+// comics_fts columns are title, alt and transcript, bm25() takes their weights in that order.
+// bm25() is lower for better matches, it is negated to keep the greater-is-better score convention.
+const querySelectFullText = `
+	SELECT num, score FROM (
+		SELECT rowid AS num, -bm25(comics_fts, ?, ?, ?) AS score FROM comics_fts WHERE comics_fts MATCH ?
+	)
+	WHERE score < ? OR score = ? AND num > ?
+	ORDER BY score DESC, num
+	LIMIT ?`
+
+// FullTextRepository searches comics with the SQLite FTS5 table kept in sync with comics by triggers.
+// The binary has to be built with the sqlite_fts5 tag.
+type FullTextRepository struct {
+	log     *slog.Logger
+	db      *sql.DB
+	weights []any
+}
+
+func NewFullTextRepository(log *slog.Logger, db *sql.DB, boosts map[domain.Field]float64) *FullTextRepository {
+	weights := make([]any, 0, 3)
+	for _, field := range []domain.Field{domain.FieldTitle, domain.FieldAlt, domain.FieldTranscript} {
+		weight, ok := boosts[field]
+		if !ok {
+			weight = 1
+		}
+		weights = append(weights, weight)
+	}
+
+	return &FullTextRepository{log: log, db: db, weights: weights}
+}
+
+// Search ranks comics following the cursor. Scores depend on the whole collection,
+// so pages may shift when comics are added between requests.
+func (r *FullTextRepository) Search(
+	ctx context.Context,
+	match string,
+	limit int,
+	after *domain.PageCursor,
+) ([]*domain.FullTextMatch, error) {
+	const op = "fulltext.Search"
+	log := r.log.With(slog.String("op", op))
+
+	log.Debug("searching comics")
+
+	afterScore, afterNum := math.Inf(1), 0
+	if after != nil {
+		afterScore, afterNum = after.Score, after.Num
+	}
+	if limit <= 0 {
+		limit = -1
+	}
+
+	args := append(r.weights[:len(r.weights):len(r.weights)], match, afterScore, afterScore, afterNum, limit)
+	rows, err := r.db.QueryContext(ctx, querySelectFullText, args...)
+	if err != nil {
+		log.Error("failed to query comics", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+	defer rows.Close()
+
+	res := make([]*domain.FullTextMatch, 0)
+	for rows.Next() {
+		var m domain.FullTextMatch
+		if err = rows.Scan(&m.Num, &m.Score); err != nil {
+			log.Error("failed to decode match", logger.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+		}
+		res = append(res, &m)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error("error during rows iteration", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+
+	log.Debug("search comics complete")
+
+	return res, nil
+}
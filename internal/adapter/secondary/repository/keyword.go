@@ -4,8 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"golang.org/x/exp/maps"
 	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
 	"yadro-go/internal/adapter/secondary"
 	"yadro-go/internal/core/domain"
 	"yadro-go/pkg/logger"
@@ -13,8 +15,42 @@ import (
 )
 
 const (
-	formantStatementSelectKeywords  = "SELECT * FROM keywords WHERE word IN (%s)"
-	statementInsertOrReplaceKeyword = "INSERT OR REPLACE INTO keywords(word, num) VALUES (?, ?)"
+	formantStatementSelectKeywords = "SELECT word, num, field, tf, positions FROM keywords WHERE word IN (%s)"
+	querySelectAllKeywords         = "SELECT word, num, field, tf, positions FROM keywords"
+	querySelectAllSurfaces         = "SELECT word, surface, SUM(count) FROM stem_surfaces GROUP BY word, surface"
+	querySelectAllLengths          = "SELECT num, length FROM comic_stats"
+	querySelectAllGenerations      = "SELECT num, generation FROM comic_stats"
+	querySelectIndexedNums         = "SELECT num FROM comic_stats"
+	statementDeleteComicKeywords   = "DELETE FROM keywords WHERE num = ?"
+	statementDeleteComicSurfaces   = "DELETE FROM stem_surfaces WHERE num = ?"
+	statementUpsertKeyword         = `
+		INSERT INTO keywords(word, num, field, tf, positions) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(word, num, field) DO UPDATE SET tf = excluded.tf, positions = excluded.positions`
+	formatStatementSelectLengths     = "SELECT num, length FROM comic_stats WHERE num IN (%s)"
+	formatStatementSelectGenerations = "SELECT num, generation FROM comic_stats WHERE num IN (%s)"
+	formatStatementSelectSurfaces    = "SELECT word, surface, SUM(count) FROM stem_surfaces WHERE word IN (%s) GROUP BY word, surface"
+	querySelectIndexStats            = `
+		SELECT COUNT(*), COALESCE(AVG(length), 0), COALESCE(MAX(num), 0), (SELECT generation FROM index_generation)
+		FROM comic_stats`
+	querySelectGeneration   = "SELECT generation FROM index_generation"
+	statementBumpGeneration = "UPDATE index_generation SET generation = generation + 1"
+	// comics keep the generation they were first indexed at, also when they are saved again or reindexed
+	statementUpsertLength = `
+		INSERT INTO comic_stats(num, length, generation) VALUES (?, ?, (SELECT generation FROM index_generation))
+		ON CONFLICT(num) DO UPDATE SET length = excluded.length`
+	statementDeleteLength = "DELETE FROM comic_stats WHERE num = ?"
+	querySelectVocabulary = `
+		SELECT k.word, k.docs, COALESCE(s.surface, k.word)
+		FROM (SELECT word, COUNT(DISTINCT num) AS docs FROM keywords GROUP BY word) k
+		LEFT JOIN (
+			SELECT word, surface, ROW_NUMBER() OVER (PARTITION BY word ORDER BY SUM(count) DESC, surface) AS rank
+			FROM stem_surfaces GROUP BY word, surface
+		) s ON s.word = k.word AND s.rank = 1`
+	statementUpsertSurface = `
+		INSERT INTO stem_surfaces(word, num, surface, count) VALUES (?, ?, ?, ?)
+		ON CONFLICT(word, num, surface) DO UPDATE SET count = excluded.count`
+	statementDeleteAllKeywords = "DELETE FROM keywords"
+	statementDeleteAllSurfaces = "DELETE FROM stem_surfaces"
 )
 
 type KeywordRepository struct {
@@ -46,25 +82,117 @@ func (r *KeywordRepository) Keywords(ctx context.Context, keywords []string) ([]
 	}
 	defer rows.Close()
 
-	keywordsMap := make(map[string]*domain.ComicKeyword)
+	res, err := readKeywords(rows)
+	if err != nil {
+		log.Error("failed to read keywords", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
 
-	for rows.Next() {
-		var word string
-		var num int
+	log.Debug("fetch keywords complete")
 
-		err = rows.Scan(&word, &num)
-		if err != nil {
-			log.Error("failed to decode keyword", logger.Err(err))
+	return res, nil
+}
+
+// AllKeywords returns every indexed keyword with its surface words.
+func (r *KeywordRepository) AllKeywords(ctx context.Context) ([]*domain.ComicKeyword, error) {
+	const op = "keyword.AllKeywords"
+	log := r.log.With(slog.String("op", op))
+
+	log.Debug("fetching all keywords")
+
+	rows, err := r.db.QueryContext(ctx, querySelectAllKeywords)
+	if err != nil {
+		log.Error("failed to query keywords", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+	defer rows.Close()
+
+	res, err := readKeywords(rows)
+	if err != nil {
+		log.Error("failed to read keywords", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+
+	keywordMap := make(map[string]*domain.ComicKeyword, len(res))
+	for _, keyword := range res {
+		keywordMap[keyword.Word] = keyword
+	}
+
+	surfaceRows, err := r.db.QueryContext(ctx, querySelectAllSurfaces)
+	if err != nil {
+		log.Error("failed to query surfaces", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+	defer surfaceRows.Close()
+
+	for surfaceRows.Next() {
+		var word, surface string
+		var count int
+
+		if err = surfaceRows.Scan(&word, &surface, &count); err != nil {
+			log.Error("failed to decode surface", logger.Err(err))
 			return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
 		}
 
-		keyword, ok := keywordsMap[word]
+		keyword, ok := keywordMap[word]
 		if !ok {
-			keyword = &domain.ComicKeyword{Word: word}
-			keywordsMap[word] = keyword
+			continue
+		}
+		if keyword.Surfaces == nil {
+			keyword.Surfaces = make(map[string]int)
+		}
+		keyword.Surfaces[surface] = count
+	}
+
+	if err = surfaceRows.Err(); err != nil {
+		log.Error("error during rows iteration", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+
+	log.Debug("fetch all keywords complete")
+
+	return res, nil
+}
+
+// Surfaces returns surface counts of the words summed over all comics.
+func (r *KeywordRepository) Surfaces(ctx context.Context, words []string) (map[string]map[string]int, error) {
+	const op = "keyword.Surfaces"
+	log := r.log.With(slog.String("op", op))
+
+	res := make(map[string]map[string]int)
+	if len(words) == 0 {
+		return res, nil
+	}
+
+	log.Debug("fetching surfaces")
+
+	stmt, err := r.db.PrepareContext(ctx, fmt.Sprintf(formatStatementSelectSurfaces, util.GeneratePlaceholders(len(words))))
+	if err != nil {
+		log.Error("failed to prepare statement", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, util.SliceToAny(words)...)
+	if err != nil {
+		log.Error("failed to query surfaces", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var word, surface string
+		var count int
+
+		if err = rows.Scan(&word, &surface, &count); err != nil {
+			log.Error("failed to decode surface", logger.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
 		}
 
-		keyword.Nums = append(keyword.Nums, num)
+		if res[word] == nil {
+			res[word] = make(map[string]int)
+		}
+		res[word][surface] = count
 	}
 
 	if err = rows.Err(); err != nil {
@@ -72,48 +200,496 @@ func (r *KeywordRepository) Keywords(ctx context.Context, keywords []string) ([]
 		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
 	}
 
-	log.Debug("fetch keywords complete")
+	log.Debug("fetch surfaces complete")
+
+	return res, nil
+}
+
+func (r *KeywordRepository) Lengths(ctx context.Context, nums []int) (map[int]int, error) {
+	const op = "keyword.Lengths"
+	log := r.log.With(slog.String("op", op))
+
+	log.Debug("fetching lengths")
+
+	stmt, err := r.db.PrepareContext(ctx, fmt.Sprintf(formatStatementSelectLengths, util.GeneratePlaceholders(len(nums))))
+	if err != nil {
+		log.Error("failed to prepare statement", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, util.SliceToAny(nums)...)
+	if err != nil {
+		log.Error("failed to query lengths", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+	defer rows.Close()
+
+	lengths, err := readLengths(rows)
+	if err != nil {
+		log.Error("failed to read lengths", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+
+	log.Debug("fetch lengths complete")
+
+	return lengths, nil
+}
+
+// AllLengths returns lengths of every indexed comic.
+func (r *KeywordRepository) AllLengths(ctx context.Context) (map[int]int, error) {
+	const op = "keyword.AllLengths"
+	log := r.log.With(slog.String("op", op))
+
+	log.Debug("fetching all lengths")
+
+	rows, err := r.db.QueryContext(ctx, querySelectAllLengths)
+	if err != nil {
+		log.Error("failed to query lengths", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+	defer rows.Close()
+
+	lengths, err := readLengths(rows)
+	if err != nil {
+		log.Error("failed to read lengths", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+
+	log.Debug("fetch all lengths complete")
+
+	return lengths, nil
+}
+
+func (r *KeywordRepository) Generations(ctx context.Context, nums []int) (map[int]int64, error) {
+	const op = "keyword.Generations"
+	log := r.log.With(slog.String("op", op))
+
+	log.Debug("fetching generations")
+
+	stmt, err := r.db.PrepareContext(ctx, fmt.Sprintf(formatStatementSelectGenerations, util.GeneratePlaceholders(len(nums))))
+	if err != nil {
+		log.Error("failed to prepare statement", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, util.SliceToAny(nums)...)
+	if err != nil {
+		log.Error("failed to query generations", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+	defer rows.Close()
+
+	generations, err := readGenerations(rows)
+	if err != nil {
+		log.Error("failed to read generations", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+
+	log.Debug("fetch generations complete")
+
+	return generations, nil
+}
+
+// AllGenerations returns generations every indexed comic was first indexed at.
+func (r *KeywordRepository) AllGenerations(ctx context.Context) (map[int]int64, error) {
+	const op = "keyword.AllGenerations"
+	log := r.log.With(slog.String("op", op))
+
+	log.Debug("fetching all generations")
+
+	rows, err := r.db.QueryContext(ctx, querySelectAllGenerations)
+	if err != nil {
+		log.Error("failed to query generations", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+	defer rows.Close()
+
+	generations, err := readGenerations(rows)
+	if err != nil {
+		log.Error("failed to read generations", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+
+	log.Debug("fetch all generations complete")
+
+	return generations, nil
+}
+
+func (r *KeywordRepository) Stats(ctx context.Context) (*domain.IndexStats, error) {
+	const op = "keyword.Stats"
+	log := r.log.With(slog.String("op", op))
+
+	log.Debug("fetching index stats")
+
+	var stats domain.IndexStats
+	err := r.db.QueryRowContext(ctx, querySelectIndexStats).Scan(&stats.Docs, &stats.AvgLength, &stats.MaxNum, &stats.Generation)
+	if err != nil {
+		log.Error("failed to query index stats", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+
+	log.Debug("fetch index stats complete")
+
+	return &stats, nil
+}
+
+// Generation returns the number of writes to the index, it changes with every Save and Reindex.
+func (r *KeywordRepository) Generation(ctx context.Context) (int64, error) {
+	const op = "keyword.Generation"
+	log := r.log.With(slog.String("op", op))
+
+	var generation int64
+	if err := r.db.QueryRowContext(ctx, querySelectGeneration).Scan(&generation); err != nil {
+		log.Error("failed to query index generation", logger.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+
+	return generation, nil
+}
+
+func (r *KeywordRepository) Vocabulary(ctx context.Context) ([]*domain.VocabularyWord, error) {
+	const op = "keyword.Vocabulary"
+	log := r.log.With(slog.String("op", op))
+
+	log.Debug("fetching vocabulary")
+
+	rows, err := r.db.QueryContext(ctx, querySelectVocabulary)
+	if err != nil {
+		log.Error("failed to query vocabulary", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+	defer rows.Close()
+
+	res := make([]*domain.VocabularyWord, 0)
+
+	for rows.Next() {
+		var word domain.VocabularyWord
+
+		if err = rows.Scan(&word.Word, &word.DocFreq, &word.Surface); err != nil {
+			log.Error("failed to decode vocabulary word", logger.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+		}
+
+		res = append(res, &word)
+	}
 
-	return maps.Values(keywordsMap), nil
+	if err = rows.Err(); err != nil {
+		log.Error("error during rows iteration", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+
+	log.Debug("fetch vocabulary complete")
+
+	return res, nil
 }
 
-func (r *KeywordRepository) Save(ctx context.Context, keywords []*domain.ComicKeyword) error {
+// Save replaces postings and surface counts of comics in lengths with the keywords ones, so that words removed
+// from a changed comic are no longer found. Postings and surface counts of other comics are upserted.
+func (r *KeywordRepository) Save(ctx context.Context, keywords []*domain.ComicKeyword, lengths map[int]int) error {
 	const op = "keyword.Save"
 	log := r.log.With(slog.String("op", op))
 
 	log.Debug("saving keywords")
 
+	if err := r.save(ctx, log, keywords, lengths, false); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Debug("save keywords complete")
+	return nil
+}
+
+// Reindex replaces all keywords, surfaces and lengths.
+func (r *KeywordRepository) Reindex(ctx context.Context, keywords []*domain.ComicKeyword, lengths map[int]int) error {
+	const op = "keyword.Reindex"
+	log := r.log.With(slog.String("op", op))
+
+	log.Debug("reindexing keywords")
+
+	if err := r.save(ctx, log, keywords, lengths, true); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Debug("reindex keywords complete")
+	return nil
+}
+
+func (r *KeywordRepository) save(
+	ctx context.Context,
+	log *slog.Logger,
+	keywords []*domain.ComicKeyword,
+	lengths map[int]int,
+	reset bool,
+) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		log.Error("failed to start a transaction", logger.Err(err))
-		return fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+		return secondary.ErrInternal
+	}
+
+	// the generation is bumped first, so that comics indexed for the first time get the new one
+	if _, err = tx.ExecContext(ctx, statementBumpGeneration); err != nil {
+		log.Error("failed to bump index generation", logger.Err(err))
+		rollback(log, tx)
+		return secondary.ErrInternal
+	}
+
+	if reset {
+		for _, statement := range []string{statementDeleteAllKeywords, statementDeleteAllSurfaces} {
+			if _, err = tx.ExecContext(ctx, statement); err != nil {
+				log.Error("failed to clear index", logger.Err(err))
+				rollback(log, tx)
+				return secondary.ErrInternal
+			}
+		}
+
+		if err = deleteOtherLengths(ctx, tx, lengths); err != nil {
+			log.Error("failed to clear index", logger.Err(err))
+			rollback(log, tx)
+			return secondary.ErrInternal
+		}
 	}
 
-	stmt, err := tx.PrepareContext(ctx, statementInsertOrReplaceKeyword)
+	for _, statement := range []string{statementDeleteComicKeywords, statementDeleteComicSurfaces} {
+		deleteStmt, err := tx.PrepareContext(ctx, statement)
+		if err != nil {
+			log.Error("failed to prepare statement", logger.Err(err))
+			rollback(log, tx)
+			return secondary.ErrInternal
+		}
+
+		for num := range lengths {
+			if _, err = deleteStmt.ExecContext(ctx, num); err != nil {
+				_ = deleteStmt.Close()
+				log.Error("failed to execute statement", logger.Err(err))
+				rollback(log, tx)
+				return secondary.ErrInternal
+			}
+		}
+		_ = deleteStmt.Close()
+	}
+
+	stmt, err := tx.PrepareContext(ctx, statementUpsertKeyword)
 	if err != nil {
 		log.Error("failed to prepare statement", logger.Err(err))
-		return fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+		rollback(log, tx)
+		return secondary.ErrInternal
 	}
 	defer stmt.Close()
 
 	for _, keyword := range keywords {
-		for _, num := range keyword.Nums {
-			_, err = stmt.ExecContext(ctx, keyword.Word, num)
+		for _, posting := range keyword.Postings {
+			_, err = stmt.ExecContext(ctx, keyword.Word, posting.Num, posting.Field, posting.Freq, encodePositions(posting.Positions))
 			if err != nil {
 				log.Error("failed to execute statement", logger.Err(err))
-				if err = tx.Rollback(); err != nil {
-					log.Error("tx rollback failed", logger.Err(err))
+				rollback(log, tx)
+				return secondary.ErrInternal
+			}
+		}
+	}
+
+	surfaceStmt, err := tx.PrepareContext(ctx, statementUpsertSurface)
+	if err != nil {
+		log.Error("failed to prepare statement", logger.Err(err))
+		rollback(log, tx)
+		return secondary.ErrInternal
+	}
+	defer surfaceStmt.Close()
+
+	for _, keyword := range keywords {
+		for num, surfaces := range comicSurfaces(keyword.Postings) {
+			for surface, count := range surfaces {
+				if _, err = surfaceStmt.ExecContext(ctx, keyword.Word, num, surface, count); err != nil {
+					log.Error("failed to execute statement", logger.Err(err))
+					rollback(log, tx)
+					return secondary.ErrInternal
 				}
-				return fmt.Errorf("%s: %w", op, secondary.ErrInternal)
 			}
 		}
 	}
 
+	lengthStmt, err := tx.PrepareContext(ctx, statementUpsertLength)
+	if err != nil {
+		log.Error("failed to prepare statement", logger.Err(err))
+		rollback(log, tx)
+		return secondary.ErrInternal
+	}
+	defer lengthStmt.Close()
+
+	for num, length := range lengths {
+		if _, err = lengthStmt.ExecContext(ctx, num, length); err != nil {
+			log.Error("failed to execute statement", logger.Err(err))
+			rollback(log, tx)
+			return secondary.ErrInternal
+		}
+	}
+
 	if err = tx.Commit(); err != nil {
 		log.Error("tx commit failed", logger.Err(err))
-		return fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+		return secondary.ErrInternal
 	}
 
-	log.Debug("save keywords complete")
 	return nil
 }
+
+// deleteOtherLengths deletes lengths of comics not in lengths, the others are kept with their generations.
+func deleteOtherLengths(ctx context.Context, tx *sql.Tx, lengths map[int]int) error {
+	rows, err := tx.QueryContext(ctx, querySelectIndexedNums)
+	if err != nil {
+		return err
+	}
+
+	stale := make([]int, 0)
+	for rows.Next() {
+		var num int
+		if err = rows.Scan(&num); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		if _, ok := lengths[num]; !ok {
+			stale = append(stale, num)
+		}
+	}
+	if err = rows.Close(); err != nil {
+		return err
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, num := range stale {
+		if _, err = tx.ExecContext(ctx, statementDeleteLength, num); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// comicSurfaces sums surface counts of the postings by comic, postings of a comic differ by field.
+func comicSurfaces(postings []domain.Posting) map[int]map[string]int {
+	res := make(map[int]map[string]int)
+	for _, posting := range postings {
+		if len(posting.Surfaces) == 0 {
+			continue
+		}
+
+		surfaces, ok := res[posting.Num]
+		if !ok {
+			surfaces = make(map[string]int, len(posting.Surfaces))
+			res[posting.Num] = surfaces
+		}
+		for surface, count := range posting.Surfaces {
+			surfaces[surface] += count
+		}
+	}
+	return res
+}
+
+// readKeywords groups keyword rows by word, postings are sorted by comic number and field.
+func readKeywords(rows *sql.Rows) ([]*domain.ComicKeyword, error) {
+	keywordsMap := make(map[string]*domain.ComicKeyword)
+
+	for rows.Next() {
+		var word, positions string
+		var posting domain.Posting
+
+		err := rows.Scan(&word, &posting.Num, &posting.Field, &posting.Freq, &positions)
+		if err != nil {
+			return nil, err
+		}
+
+		if posting.Positions, err = decodePositions(positions); err != nil {
+			return nil, err
+		}
+
+		keyword, ok := keywordsMap[word]
+		if !ok {
+			keyword = &domain.ComicKeyword{Word: word}
+			keywordsMap[word] = keyword
+		}
+		keyword.Postings = append(keyword.Postings, posting)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	res := make([]*domain.ComicKeyword, 0, len(keywordsMap))
+	for _, keyword := range keywordsMap {
+		slices.SortFunc(keyword.Postings, func(a, b domain.Posting) int {
+			if a.Num != b.Num {
+				return a.Num - b.Num
+			}
+			return strings.Compare(string(a.Field), string(b.Field))
+		})
+		res = append(res, keyword)
+	}
+
+	return res, nil
+}
+
+func readLengths(rows *sql.Rows) (map[int]int, error) {
+	lengths := make(map[int]int)
+
+	for rows.Next() {
+		var num, length int
+
+		if err := rows.Scan(&num, &length); err != nil {
+			return nil, err
+		}
+
+		lengths[num] = length
+	}
+
+	return lengths, rows.Err()
+}
+
+func readGenerations(rows *sql.Rows) (map[int]int64, error) {
+	generations := make(map[int]int64)
+
+	for rows.Next() {
+		var num int
+		var generation int64
+
+		if err := rows.Scan(&num, &generation); err != nil {
+			return nil, err
+		}
+
+		generations[num] = generation
+	}
+
+	return generations, rows.Err()
+}
+
+func rollback(log *slog.Logger, tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil {
+		log.Error("tx rollback failed", logger.Err(err))
+	}
+}
+
+func encodePositions(positions []int) string {
+	encoded := make([]string, len(positions))
+	for i, pos := range positions {
+		encoded[i] = strconv.Itoa(pos)
+	}
+	return strings.Join(encoded, ",")
+}
+
+func decodePositions(s string) ([]int, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+
+	encoded := strings.Split(s, ",")
+	positions := make([]int, len(encoded))
+	for i, v := range encoded {
+		pos, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		positions[i] = pos
+	}
+	return positions, nil
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+	"yadro-go/internal/adapter/secondary"
+	"yadro-go/pkg/logger"
+)
+
+const (
+	// the lease is taken when there is none, it is expired or already held by the holder
+	statementAcquireLease = `
+		INSERT INTO update_leases(name, holder, expires_at, heartbeat_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE
+		SET holder = excluded.holder, expires_at = excluded.expires_at, heartbeat_at = excluded.heartbeat_at
+		WHERE update_leases.holder = excluded.holder OR update_leases.expires_at <= excluded.heartbeat_at`
+	statementReleaseLease = `
+		DELETE FROM update_leases WHERE name = ? AND holder = ?`
+)
+
+type LeaseRepository struct {
+	log *slog.Logger
+	db  *sql.DB
+}
+
+func NewLeaseRepository(log *slog.Logger, db *sql.DB) *LeaseRepository {
+	return &LeaseRepository{log: log, db: db}
+}
+
+func (r *LeaseRepository) Acquire(ctx context.Context, name string, holder string, ttl time.Duration) (bool, error) {
+	const op = "lease.Acquire"
+	log := r.log.With(slog.String("op", op), slog.String("lease", name))
+
+	now := time.Now()
+	res, err := r.db.ExecContext(ctx, statementAcquireLease, name, holder, now.Add(ttl).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		log.Error("failed to acquire lease", logger.Err(err))
+		return false, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		log.Error("failed to get affected rows", logger.Err(err))
+		return false, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+
+	return n > 0, nil
+}
+
+func (r *LeaseRepository) Release(ctx context.Context, name string, holder string) error {
+	const op = "lease.Release"
+	log := r.log.With(slog.String("op", op), slog.String("lease", name))
+
+	if _, err := r.db.ExecContext(ctx, statementReleaseLease, name, holder); err != nil {
+		log.Error("failed to release lease", logger.Err(err))
+		return fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+	"yadro-go/internal/adapter/secondary"
+	"yadro-go/internal/core/domain"
+	"yadro-go/pkg/logger"
+)
+
+const (
+	statementInsertUpdateRun = `
+		INSERT INTO update_runs(trigger, mode, username, holder, state, started_at) VALUES (?, ?, ?, ?, ?, ?)`
+	statementFinishUpdateRun = `
+		UPDATE update_runs
+		SET state = ?, finished_at = ?, new_count = ?, updated_count = ?, failed_count = ?, error = ?
+		WHERE id = ?`
+	// runs of an instance holding the lease are still running
+	statementInterruptUpdateRuns = `
+		UPDATE update_runs SET state = ?, finished_at = ?, error = ?
+		WHERE finished_at IS NULL
+		AND holder NOT IN (SELECT holder FROM update_leases WHERE name = ? AND expires_at > ?)`
+	querySelectUpdateRuns = `
+		SELECT id, trigger, mode, username, state, started_at, finished_at, new_count, updated_count, failed_count, error
+		FROM update_runs WHERE id < ? ORDER BY id DESC LIMIT ?`
+	// runs don't overlap, so the latest started one has finished last; times stored as text don't sort
+	// across time zone offsets
+	querySelectLastSuccess = `
+		SELECT finished_at FROM update_runs WHERE state = ? ORDER BY id DESC LIMIT 1`
+)
+
+// errInterrupted is recorded for runs which were running when the process stopped
+const errInterrupted = "interrupted by restart"
+
+type UpdateRunRepository struct {
+	log *slog.Logger
+	db  *sql.DB
+}
+
+func NewUpdateRunRepository(log *slog.Logger, db *sql.DB) *UpdateRunRepository {
+	return &UpdateRunRepository{log: log, db: db}
+}
+
+func (r *UpdateRunRepository) Start(ctx context.Context, run *domain.UpdateRun) (int64, error) {
+	const op = "run.Start"
+	log := r.log.With(slog.String("op", op))
+
+	res, err := r.db.ExecContext(ctx, statementInsertUpdateRun,
+		run.Trigger, run.Mode, run.Username, run.Holder, run.State, run.Started)
+	if err != nil {
+		log.Error("failed to insert run", logger.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		log.Error("failed to get run id", logger.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+
+	return id, nil
+}
+
+func (r *UpdateRunRepository) Finish(ctx context.Context, run *domain.UpdateRun) error {
+	const op = "run.Finish"
+	log := r.log.With(slog.String("op", op), slog.Int64("run", run.Id))
+
+	_, err := r.db.ExecContext(ctx, statementFinishUpdateRun,
+		run.State, run.Finished, run.New, run.Updated, run.Failed, run.Error, run.Id)
+	if err != nil {
+		log.Error("failed to update run", logger.Err(err))
+		return fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+
+	return nil
+}
+
+func (r *UpdateRunRepository) Runs(ctx context.Context, limit int, before int64) ([]*domain.UpdateRun, error) {
+	const op = "run.Runs"
+	log := r.log.With(slog.String("op", op))
+
+	if before <= 0 {
+		before = math.MaxInt64
+	}
+
+	rows, err := r.db.QueryContext(ctx, querySelectUpdateRuns, before, limit)
+	if err != nil {
+		log.Error("failed to query runs", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+	defer rows.Close()
+
+	res := make([]*domain.UpdateRun, 0, limit)
+	for rows.Next() {
+		var run domain.UpdateRun
+		var finished sql.NullTime
+		err = rows.Scan(&run.Id, &run.Trigger, &run.Mode, &run.Username, &run.State, &run.Started, &finished,
+			&run.New, &run.Updated, &run.Failed, &run.Error)
+		if err != nil {
+			log.Error("failed to decode run", logger.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+		}
+		run.Finished = finished.Time
+		res = append(res, &run)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error("error during rows iteration", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+
+	return res, nil
+}
+
+func (r *UpdateRunRepository) Interrupt(ctx context.Context, lease string) error {
+	const op = "run.Interrupt"
+	log := r.log.With(slog.String("op", op))
+
+	// the time is bound from Go like in the other writes, so that finished times are in one format
+	now := time.Now()
+	res, err := r.db.ExecContext(ctx, statementInterruptUpdateRuns,
+		domain.UpdateJobFailed, now, errInterrupted, lease, now.UnixMilli())
+	if err != nil {
+		log.Error("failed to update runs", logger.Err(err))
+		return fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		log.Warn(fmt.Sprintf("%d update runs were interrupted", n))
+	}
+
+	return nil
+}
+
+func (r *UpdateRunRepository) LastSuccess(ctx context.Context) (time.Time, error) {
+	const op = "run.LastSuccess"
+	log := r.log.With(slog.String("op", op))
+
+	var finished time.Time
+	err := r.db.QueryRowContext(ctx, querySelectLastSuccess, domain.UpdateJobDone).Scan(&finished)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		log.Error("failed to query last successful run", logger.Err(err))
+		return time.Time{}, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+	}
+
+	return finished, nil
+}
@@ -6,3 +6,6 @@ var ErrUserNotFound = errors.New("user not found")
 
 var ErrComicNotFound = errors.New("comic not found")
 var ErrInternal = errors.New("internal error")
+
+// ErrOffline means the comic isn't cached and the provider makes no requests, it may exist upstream.
+var ErrOffline = errors.New("comic not cached while offline")
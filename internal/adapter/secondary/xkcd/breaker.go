@@ -0,0 +1,151 @@
+package xkcd
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var errCircuitOpen = errors.New("circuit breaker is open")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker stops requests to a failing server. It opens after threshold consecutive failures
+// and rejects requests for cooldown, then lets a single probe through. A successful probe closes it,
+// a failed one opens it again. Nil breaker lets every request through.
+type circuitBreaker struct {
+	log       *slog.Logger
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newCircuitBreaker(log *slog.Logger, threshold int, cooldown time.Duration) *circuitBreaker {
+	b := &circuitBreaker{
+		log:       log.With(slog.String("op", "xkcd.circuitBreaker")),
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+	b.publish()
+	return b
+}
+
+// allow tells if a request may be made now. The first request after cooldown becomes the probe,
+// it has to be followed by success, failure or abort.
+func (b *circuitBreaker) allow(now time.Time) bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if now.Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.setState(breakerHalfOpen)
+		b.log.Info("circuit breaker half-open, probing")
+		b.probing = true
+		return true
+	case breakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// success closes the breaker, the server has responded.
+func (b *circuitBreaker) success() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	if b.state != breakerClosed {
+		b.setState(breakerClosed)
+		b.log.Info("circuit breaker closed")
+	}
+}
+
+// failure counts a consecutive failure and opens the breaker on the threshold or a failed probe.
+func (b *circuitBreaker) failure(now time.Time) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	switch {
+	case b.state == breakerHalfOpen:
+		b.log.Warn("circuit breaker probe failed, opened again")
+	case b.state == breakerClosed && b.failures >= b.threshold:
+		b.log.Warn(fmt.Sprintf("circuit breaker opened after %d consecutive failures", b.failures))
+	default:
+		return
+	}
+
+	b.probing = false
+	b.openedAt = now
+	b.setState(breakerOpen)
+	metrics.Add("breaker_opened", 1)
+}
+
+// abort frees the probe of a request which has ended without telling anything about the server.
+func (b *circuitBreaker) abort() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (b *circuitBreaker) setState(state breakerState) {
+	b.state = state
+	b.publish()
+}
+
+func (b *circuitBreaker) publish() {
+	state := new(expvar.String)
+	state.Set(b.state.String())
+	metrics.Set("breaker_state", state)
+}
@@ -0,0 +1,59 @@
+package xkcd
+
+import (
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"testing"
+	"time"
+	"yadro-go/test/logger"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 6, 7, 12, 0, 0, 0, time.UTC)
+	b := newCircuitBreaker(slog.New(logger.EmptyHandler{}), 3, time.Minute)
+
+	// a success resets the count of consecutive failures
+	b.failure(start)
+	b.failure(start)
+	b.success()
+	b.failure(start)
+	b.failure(start)
+	assert.Equal(t, breakerClosed, b.state)
+	assert.True(t, b.allow(start))
+
+	b.failure(start)
+	assert.Equal(t, breakerOpen, b.state)
+	assert.False(t, b.allow(start.Add(time.Second)))
+
+	// a single probe after cooldown, an aborted one lets another through
+	assert.True(t, b.allow(start.Add(time.Minute)))
+	assert.Equal(t, breakerHalfOpen, b.state)
+	assert.False(t, b.allow(start.Add(time.Minute)))
+	b.abort()
+	assert.True(t, b.allow(start.Add(time.Minute)))
+
+	// a failed probe opens the breaker for another cooldown
+	b.failure(start.Add(time.Minute))
+	assert.Equal(t, breakerOpen, b.state)
+	assert.False(t, b.allow(start.Add(time.Minute+time.Second)))
+
+	assert.True(t, b.allow(start.Add(2*time.Minute)))
+	b.success()
+	assert.Equal(t, breakerClosed, b.state)
+	assert.True(t, b.allow(start.Add(2*time.Minute)))
+	assert.True(t, b.allow(start.Add(2*time.Minute)))
+}
+
+func TestCircuitBreaker_Nil(t *testing.T) {
+	t.Parallel()
+
+	var b *circuitBreaker
+	assert.True(t, b.allow(time.Now()))
+	assert.NotPanics(t, func() {
+		b.failure(time.Now())
+		b.success()
+		b.abort()
+	})
+}
@@ -0,0 +1,145 @@
+package xkcd
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"yadro-go/pkg/logger"
+)
+
+// cachedResponse is the raw body of a successful response with its validators,
+// which make the next request for the same url conditional.
+type cachedResponse struct {
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"last_modified,omitempty"`
+	Body         json.RawMessage `json:"body"`
+}
+
+// validators of a cached response, the only part of it kept in memory.
+type validators struct {
+	etag         string
+	lastModified string
+}
+
+// responseCache keeps responses in a file per url, so that they outlive the process. Only validators
+// are kept in memory, bodies are read from the files when they are needed. Nil cache keeps nothing.
+type responseCache struct {
+	log *slog.Logger
+	dir string
+
+	mu         sync.Mutex
+	validators map[string]validators
+}
+
+func newResponseCache(log *slog.Logger, dir string) *responseCache {
+	return &responseCache{log: log, dir: dir, validators: make(map[string]validators)}
+}
+
+// get returns validators of the cached response for the key, false if there is none.
+func (c *responseCache) get(key string) (validators, bool) {
+	if c == nil {
+		return validators{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if v, ok := c.validators[key]; ok {
+		return v, true
+	}
+
+	resp, err := c.read(key)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			c.log.Warn("failed to read cached response", slog.String("key", key), logger.Err(err))
+		}
+		return validators{}, false
+	}
+	v := validators{etag: resp.ETag, lastModified: resp.LastModified}
+	c.validators[key] = v
+	return v, true
+}
+
+// body reads the body of the cached response for the key. Validators of a response which can't be read
+// are forgotten, so that the next request for it isn't conditional.
+func (c *responseCache) body(key string) (json.RawMessage, error) {
+	if c == nil {
+		return nil, fs.ErrNotExist
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	resp, err := c.read(key)
+	if err != nil {
+		delete(c.validators, key)
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// put stores the response, a failure to write the file is only logged as the cache is an optimization.
+func (c *responseCache) put(key string, resp *cachedResponse) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.write(key, resp); err != nil {
+		delete(c.validators, key)
+		c.log.Warn("failed to write cached response", slog.String("key", key), logger.Err(err))
+		return
+	}
+	c.validators[key] = validators{etag: resp.ETag, lastModified: resp.LastModified}
+}
+
+func (c *responseCache) read(key string) (*cachedResponse, error) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &cachedResponse{}
+	if err = json.Unmarshal(data, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// write stores the response into a temporary file and renames it, so that the file is always complete.
+func (c *responseCache) write(key string, resp *cachedResponse) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+
+	path := c.path(key)
+	tmp, err := os.CreateTemp(c.dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// path maps the key, a url path like /42/info.0.json, to a file name like 42_info.0.json.
+func (c *responseCache) path(key string) string {
+	name := strings.ReplaceAll(strings.Trim(key, "/"), "/", "_")
+	return filepath.Join(c.dir, name)
+}
@@ -1,70 +1,316 @@
 package xkcd
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"expvar"
 	"fmt"
+	"golang.org/x/time/rate"
 	"io"
+	"io/fs"
 	"log/slog"
+	"math/rand/v2"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 	"yadro-go/internal/adapter/secondary"
 	"yadro-go/internal/core/domain"
 	"yadro-go/pkg/logger"
+	"yadro-go/pkg/sync"
+)
+
+// metrics of outbound requests and the circuit breaker, published with expvar
+var metrics = expvar.NewMap("xkcd")
+
+const (
+	defaultRetries     = 3
+	defaultBackoffBase = 500 * time.Millisecond
+	defaultBackoffMax  = 30 * time.Second
 )
 
 type HttpClient struct {
 	log *slog.Logger
 	c   *http.Client
 	url string
+
+	retries     int
+	backoffBase time.Duration
+	backoffMax  time.Duration
+
+	// cache keeps responses by url path, a cached one is requested again only if it has been modified.
+	// It is nil when disabled
+	cache   *responseCache
+	offline bool
+
+	// limiter, inFlight and breaker are nil when disabled
+	limiter  *rate.Limiter
+	inFlight *sync.Semaphore
+	breaker  *circuitBreaker
 }
 
-func NewHttpClient(log *slog.Logger, url string, timeout time.Duration) *HttpClient {
-	c := &http.Client{Timeout: timeout}
-	return &HttpClient{log: log, c: c, url: url}
+func NewHttpClient(log *slog.Logger, url string, timeout time.Duration, opts ...Option) *HttpClient {
+	xc := &HttpClient{
+		log:         log,
+		c:           &http.Client{Timeout: timeout},
+		url:         url,
+		retries:     defaultRetries,
+		backoffBase: defaultBackoffBase,
+		backoffMax:  defaultBackoffMax,
+	}
+
+	for _, opt := range opts {
+		opt(xc)
+	}
+
+	return xc
 }
 
-func (xc *HttpClient) GetById(id int) (*domain.Comic, error) {
+func (xc *HttpClient) GetById(ctx context.Context, id int) (*domain.Comic, error) {
 	const op = "xkcd.GetById"
-	log := xc.log.With(slog.String("op", op))
 
-	resp, err := xc.doGet(xc.makeComicUrl(id))
+	comic, err := xc.getComic(ctx, op, xc.makeComicUrl(id))
+	if err != nil {
+		return nil, err
+	}
+	return comic, nil
+}
+
+// LastNum returns the number of the current comic.
+func (xc *HttpClient) LastNum(ctx context.Context) (int, error) {
+	const op = "xkcd.LastNum"
+
+	comic, err := xc.getComic(ctx, op, xc.url+"/info.0.json")
 	if err != nil {
-		log.Error("failed to make a request", logger.Err(err))
-		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+		return 0, err
+	}
+	return comic.Num, nil
+}
+
+// getComic requests the url until it gets a response which is not worth retrying
+// or retries are exhausted.
+func (xc *HttpClient) getComic(ctx context.Context, op string, url string) (*domain.Comic, error) {
+	log := xc.log.With(slog.String("op", op), slog.String("url", url))
+
+	key := strings.TrimPrefix(url, xc.url)
+	if xc.offline {
+		body, err := xc.cache.body(key)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				log.Warn("failed to read cached response", logger.Err(err))
+			}
+			return nil, fmt.Errorf("%s: %w", op, secondary.ErrOffline)
+		}
+		comic, err := parseBody(body)
+		if err != nil {
+			log.Error("failed to parse cached body", logger.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+		}
+		return comic, nil
+	}
+
+	for attempt := 0; ; attempt++ {
+		comic, retryAfter, err := xc.tryGetComic(ctx, log, url, key)
+		if err == nil {
+			return comic, nil
+		}
+
+		var retryable *retryableError
+		if !errors.As(err, &retryable) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if attempt >= xc.retries {
+			log.Error(fmt.Sprintf("giving up after %d attempts", attempt+1), logger.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		// the server is not asked sooner than it wants, a too long wait counts as a failure
+		delay := retryAfter
+		if delay > xc.backoffMax {
+			log.Error(fmt.Sprintf("giving up: server asked to retry in %v", delay), logger.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if delay <= 0 {
+			delay = xc.backoff(attempt)
+		}
+		log.Warn(fmt.Sprintf("request failed, retrying in %v", delay), logger.Err(err))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+		}
+	}
+}
+
+// tryGetComic makes a single request within the limits, it fails fast while the circuit breaker is open.
+// The returned delay is the one asked by the server in the Retry-After header, zero if there is none.
+func (xc *HttpClient) tryGetComic(
+	ctx context.Context,
+	log *slog.Logger,
+	url string,
+	key string,
+) (*domain.Comic, time.Duration, error) {
+	if !xc.breaker.allow(time.Now()) {
+		metrics.Add("breaker_rejected", 1)
+		return nil, 0, fmt.Errorf("%w: %w", secondary.ErrInternal, errCircuitOpen)
+	}
+
+	release, err := xc.acquire(ctx)
+	if err != nil {
+		xc.breaker.abort()
+		log.Debug("request not made", logger.Err(err))
+		return nil, 0, fmt.Errorf("%w: %v", secondary.ErrInternal, err)
+	}
+	defer release()
+
+	comic, retryAfter, err := xc.request(ctx, log, url, key)
+
+	// only failures of the server count, client errors and not found comics mean it works
+	var retryable *retryableError
+	switch {
+	case err == nil:
+		xc.breaker.success()
+	case ctx.Err() != nil:
+		xc.breaker.abort()
+	case errors.As(err, &retryable):
+		xc.breaker.failure(time.Now())
+	default:
+		xc.breaker.success()
+	}
+	return comic, retryAfter, err
+}
+
+// acquire waits for a free in-flight slot and then for the rate limiter. The returned function frees the slot.
+func (xc *HttpClient) acquire(ctx context.Context) (func(), error) {
+	if xc.inFlight != nil {
+		select {
+		case xc.inFlight.C <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release := func() {
+		metrics.Add("in_flight", -1)
+		if xc.inFlight != nil {
+			xc.inFlight.Release()
+		}
+	}
+	metrics.Add("in_flight", 1)
+
+	if xc.limiter != nil {
+		if err := xc.limiter.Wait(ctx); err != nil {
+			release()
+			return nil, err
+		}
+	}
+
+	metrics.Add("requests", 1)
+	return release, nil
+}
+
+// request makes a single request, conditional if the response is cached. The cached comic is returned
+// when it hasn't been modified.
+func (xc *HttpClient) request(
+	ctx context.Context,
+	log *slog.Logger,
+	url string,
+	key string,
+) (*domain.Comic, time.Duration, error) {
+	cached, ok := xc.cache.get(key)
+	resp, err := xc.doGet(ctx, url, cached)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, 0, secondary.ErrInternal
+		}
+		log.Debug("failed to make a request", logger.Err(err))
+		return nil, 0, &retryableError{err: fmt.Errorf("%w: %v", secondary.ErrInternal, err)}
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && ok {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		log.Debug("not modified")
+
+		body, err := xc.cache.body(key)
+		if err != nil {
+			// the cached validators are forgotten with the body, the comic is requested again unconditionally
+			log.Warn("failed to read cached response, requesting it again", logger.Err(err))
+			return xc.request(ctx, log, url, key)
+		}
+		comic, err := parseBody(body)
+		if err != nil {
+			log.Error("failed to parse cached body", logger.Err(err))
+			return nil, 0, fmt.Errorf("%w: %v", secondary.ErrInternal, err)
+		}
+		return comic, 0, nil
+	}
+
 	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+
 		if resp.StatusCode == http.StatusNotFound {
-			return nil, fmt.Errorf("%s: %w", op, secondary.ErrComicNotFound)
+			return nil, 0, secondary.ErrComicNotFound
+		}
+
+		err = fmt.Errorf("%w: unexpected status %d", secondary.ErrInternal, resp.StatusCode)
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+			return nil, parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()), &retryableError{err: err}
 		}
 
 		log.With(slog.Int("status", resp.StatusCode)).Error("failed to fetch comic")
-		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+		return nil, 0, err
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Error("failed to read body", logger.Err(err))
-		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+		log.Debug("failed to read body", logger.Err(err))
+		return nil, 0, &retryableError{err: fmt.Errorf("%w: %v", secondary.ErrInternal, err)}
 	}
 
 	comic, err := parseBody(body)
 	if err != nil {
 		log.Error("failed to parse body", logger.Err(err))
-		return nil, fmt.Errorf("%s: %w", op, secondary.ErrInternal)
+		return nil, 0, fmt.Errorf("%w: %v", secondary.ErrInternal, err)
 	}
 
-	return comic, nil
+	xc.cache.put(key, &cachedResponse{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Body:         body,
+	})
+
+	return comic, 0, nil
 }
 
-func (xc *HttpClient) doGet(url string) (*http.Response, error) {
-	req, err := http.NewRequest("GET", url, nil)
+// backoff returns a random delay up to base * 2^attempt, capped by the max backoff.
+func (xc *HttpClient) backoff(attempt int) time.Duration {
+	ceiling := xc.backoffMax
+	if attempt < 32 && xc.backoffBase<<attempt < ceiling {
+		ceiling = xc.backoffBase << attempt
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(ceiling))) + 1
+}
+
+func (xc *HttpClient) doGet(ctx context.Context, url string, cached validators) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Add("Content-Type", "application/json")
+	if cached.etag != "" {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+	if cached.lastModified != "" {
+		req.Header.Set("If-Modified-Since", cached.lastModified)
+	}
 
 	resp, err := xc.c.Do(req)
 	if err != nil {
@@ -81,3 +327,30 @@ func parseBody(b []byte) (*domain.Comic, error) {
 	comic := &domain.Comic{}
 	return comic, json.Unmarshal(b, comic)
 }
+
+// retryableError marks failures which may succeed on another attempt: network errors, 429 and 5xx responses.
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// parseRetryAfter reads the Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0)
+	}
+	return 0
+}
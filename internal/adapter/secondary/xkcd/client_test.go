@@ -0,0 +1,331 @@
+package xkcd
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"yadro-go/internal/adapter/secondary"
+	"yadro-go/test/logger"
+)
+
+func TestHttpClient_GetById(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name             string
+		statuses         []int
+		retryAfter       string
+		expectedRequests int
+		expectedError    error
+	}{
+		{name: "Success", statuses: []int{http.StatusOK}, expectedRequests: 1},
+		{name: "RetriedServerError", statuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK},
+			expectedRequests: 3},
+		{name: "RetriedTooManyRequests", statuses: []int{http.StatusTooManyRequests, http.StatusOK}, retryAfter: "0",
+			expectedRequests: 2},
+		{name: "RetriesExhausted", statuses: []int{http.StatusInternalServerError}, expectedRequests: 4,
+			expectedError: secondary.ErrInternal},
+		{name: "RetryAfterTooLong", statuses: []int{http.StatusServiceUnavailable}, retryAfter: "3600",
+			expectedRequests: 1, expectedError: secondary.ErrInternal},
+		{name: "NotFoundNotRetried", statuses: []int{http.StatusNotFound}, expectedRequests: 1,
+			expectedError: secondary.ErrComicNotFound},
+		{name: "BadRequestNotRetried", statuses: []int{http.StatusBadRequest}, expectedRequests: 1,
+			expectedError: secondary.ErrInternal},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/42/info.0.json", r.URL.Path)
+
+				n := int(requests.Add(1))
+				status := testCase.statuses[min(n, len(testCase.statuses))-1]
+				if testCase.retryAfter != "" {
+					w.Header().Set("Retry-After", testCase.retryAfter)
+				}
+				w.WriteHeader(status)
+				if status == http.StatusOK {
+					_, _ = w.Write([]byte(`{"num": 42, "title": "Geico"}`))
+				}
+			}))
+			defer srv.Close()
+
+			xc := NewHttpClient(slog.New(logger.EmptyHandler{}), srv.URL, time.Second,
+				Retries(3), Backoff(time.Millisecond, 10*time.Millisecond))
+			comic, err := xc.GetById(context.Background(), 42)
+
+			require.ErrorIs(t, err, testCase.expectedError)
+			assert.Equal(t, testCase.expectedRequests, int(requests.Load()))
+			if testCase.expectedError == nil {
+				assert.Equal(t, 42, comic.Num)
+				assert.Equal(t, "Geico", comic.Title)
+			}
+		})
+	}
+}
+
+func TestHttpClient_LastNum(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/info.0.json", r.URL.Path)
+		_, _ = w.Write([]byte(`{"num": 2900}`))
+	}))
+	defer srv.Close()
+
+	xc := NewHttpClient(slog.New(logger.EmptyHandler{}), srv.URL, time.Second)
+	num, err := xc.LastNum(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 2900, num)
+}
+
+func TestHttpClient_RetryCancelled(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	xc := NewHttpClient(slog.New(logger.EmptyHandler{}), srv.URL, time.Second,
+		Retries(100), Backoff(time.Second, time.Minute))
+	_, err := xc.GetById(ctx, 1)
+
+	require.ErrorIs(t, err, secondary.ErrInternal)
+	assert.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+}
+
+func TestHttpClient_ConditionalRequest(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name      string
+		validator string
+		condition string
+	}{
+		{name: "ETag", validator: "ETag", condition: "If-None-Match"},
+		{name: "LastModified", validator: "Last-Modified", condition: "If-Modified-Since"},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			const value = `"v1"`
+			var requests, notModified atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				if r.Header.Get(testCase.condition) == value {
+					notModified.Add(1)
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Header().Set(testCase.validator, value)
+				_, _ = w.Write([]byte(`{"num": 42, "title": "Geico"}`))
+			}))
+			defer srv.Close()
+
+			xc := NewHttpClient(slog.New(logger.EmptyHandler{}), srv.URL, time.Second, CacheDir(t.TempDir()))
+			for i := 0; i < 3; i++ {
+				comic, err := xc.GetById(context.Background(), 42)
+				require.NoError(t, err)
+				assert.Equal(t, "Geico", comic.Title)
+			}
+			assert.Equal(t, 3, int(requests.Load()))
+			assert.Equal(t, 2, int(notModified.Load()))
+		})
+	}
+}
+
+func TestHttpClient_CacheDir(t *testing.T) {
+	t.Parallel()
+
+	var requests, notModified atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"num": 42, "title": "Geico"}`))
+	}))
+
+	dir := t.TempDir()
+	log := slog.New(logger.EmptyHandler{})
+	_, err := NewHttpClient(log, srv.URL, time.Second, CacheDir(dir)).GetById(context.Background(), 42)
+	require.NoError(t, err)
+
+	// a new client reads the validators from the directory
+	comic, err := NewHttpClient(log, srv.URL, time.Second, CacheDir(dir)).GetById(context.Background(), 42)
+	require.NoError(t, err)
+	assert.Equal(t, "Geico", comic.Title)
+	assert.Equal(t, 2, int(requests.Load()))
+	assert.Equal(t, 1, int(notModified.Load()))
+
+	// offline client doesn't need the server
+	srv.Close()
+	offline := NewHttpClient(log, srv.URL, time.Second, CacheDir(dir), Offline())
+	comic, err = offline.GetById(context.Background(), 42)
+	require.NoError(t, err)
+	assert.Equal(t, "Geico", comic.Title)
+
+	// the comic may exist, it isn't recorded as missing
+	_, err = offline.GetById(context.Background(), 43)
+	assert.ErrorIs(t, err, secondary.ErrOffline)
+	assert.NotErrorIs(t, err, secondary.ErrComicNotFound)
+}
+
+func TestHttpClient_CacheDisabled(t *testing.T) {
+	t.Parallel()
+
+	var conditional atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			conditional.Add(1)
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"num": 42, "title": "Geico"}`))
+	}))
+	defer srv.Close()
+
+	xc := NewHttpClient(slog.New(logger.EmptyHandler{}), srv.URL, time.Second, CacheDir(""))
+	for i := 0; i < 2; i++ {
+		_, err := xc.GetById(context.Background(), 42)
+		require.NoError(t, err)
+	}
+	assert.Nil(t, xc.cache)
+	assert.Equal(t, 0, int(conditional.Load()))
+}
+
+func TestHttpClient_CacheFileRemoved(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"num": 42, "title": "Geico"}`))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	xc := NewHttpClient(slog.New(logger.EmptyHandler{}), srv.URL, time.Second, CacheDir(dir))
+	_, err := xc.GetById(context.Background(), 42)
+	require.NoError(t, err)
+
+	// the body isn't kept in memory, the comic is requested again without validators
+	require.NoError(t, os.Remove(filepath.Join(dir, "42_info.0.json")))
+	comic, err := xc.GetById(context.Background(), 42)
+	require.NoError(t, err)
+	assert.Equal(t, "Geico", comic.Title)
+	assert.Equal(t, 3, int(requests.Load()))
+}
+
+func TestHttpClient_Limits(t *testing.T) {
+	t.Parallel()
+
+	var current, maxCurrent atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := current.Add(1)
+		defer current.Add(-1)
+		for {
+			m := maxCurrent.Load()
+			if n <= m || maxCurrent.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"num": 1}`))
+	}))
+	defer srv.Close()
+
+	xc := NewHttpClient(slog.New(logger.EmptyHandler{}), srv.URL, time.Second, RateLimit(100), MaxInFlight(2))
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 1; i <= 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := xc.GetById(context.Background(), i)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	// 10 requests at 100 rps take at least 90ms, 2 at a time of 20ms each take 100ms
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	assert.Equal(t, int32(2), maxCurrent.Load())
+}
+
+func TestHttpClient_CircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int32
+	var healthy atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"num": 1}`))
+	}))
+	defer srv.Close()
+
+	const cooldown = 50 * time.Millisecond
+	xc := NewHttpClient(slog.New(logger.EmptyHandler{}), srv.URL, time.Second,
+		Retries(3), Backoff(time.Millisecond, time.Millisecond), CircuitBreaker(2, cooldown))
+
+	// the second failure opens the breaker, remaining retries fail fast
+	_, err := xc.GetById(context.Background(), 1)
+	require.ErrorIs(t, err, errCircuitOpen)
+	assert.Equal(t, 2, int(requests.Load()))
+
+	_, err = xc.GetById(context.Background(), 1)
+	require.ErrorIs(t, err, secondary.ErrInternal)
+	assert.Equal(t, 2, int(requests.Load()))
+
+	// a probe after cooldown closes the breaker
+	healthy.Store(true)
+	time.Sleep(cooldown)
+	_, err = xc.GetById(context.Background(), 1)
+	require.NoError(t, err)
+	_, err = xc.GetById(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, 4, int(requests.Load()))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 6, 7, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(t, 120*time.Second, parseRetryAfter("120", now))
+	assert.Equal(t, 30*time.Second, parseRetryAfter("Fri, 07 Jun 2024 12:00:30 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("Fri, 07 Jun 2024 11:00:00 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+}
@@ -0,0 +1,72 @@
+package xkcd
+
+import (
+	"golang.org/x/time/rate"
+	"time"
+	"yadro-go/pkg/sync"
+)
+
+type Option func(*HttpClient)
+
+// Retries sets how many times a failed request is repeated, zero disables retries.
+func Retries(retries int) Option {
+	return func(xc *HttpClient) {
+		xc.retries = retries
+	}
+}
+
+// Backoff sets the initial and the maximum delay between retries. The delay doubles with every attempt
+// and is randomized, unless the server asks for a specific one with Retry-After.
+func Backoff(base time.Duration, max time.Duration) Option {
+	return func(xc *HttpClient) {
+		xc.backoffBase = base
+		xc.backoffMax = max
+	}
+}
+
+// CacheDir keeps raw responses in the directory, so that they are reused by conditional requests,
+// also after a restart. The directory has to exist, empty path disables the cache.
+func CacheDir(dir string) Option {
+	return func(xc *HttpClient) {
+		if dir != "" {
+			xc.cache = newResponseCache(xc.log, dir)
+		}
+	}
+}
+
+// Offline serves comics only from the cache without making requests, the ones which aren't cached
+// fail with secondary.ErrOffline.
+func Offline() Option {
+	return func(xc *HttpClient) {
+		xc.offline = true
+	}
+}
+
+// RateLimit sets how many requests per second are made at most, zero or less disables the limit.
+func RateLimit(rps float64) Option {
+	return func(xc *HttpClient) {
+		if rps > 0 {
+			xc.limiter = rate.NewLimiter(rate.Limit(rps), 1)
+		}
+	}
+}
+
+// MaxInFlight sets how many requests are made at once at most, zero or less disables the limit.
+func MaxInFlight(n int) Option {
+	return func(xc *HttpClient) {
+		if n > 0 {
+			xc.inFlight = sync.NewSemaphore(n)
+		}
+	}
+}
+
+// CircuitBreaker makes requests fail fast for cooldown after threshold consecutive failures
+// (network errors, 429 and 5xx responses), then a single request probes the server. Zero or less
+// threshold disables the breaker.
+func CircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(xc *HttpClient) {
+		if threshold > 0 {
+			xc.breaker = newCircuitBreaker(xc.log, threshold, cooldown)
+		}
+	}
+}
@@ -2,27 +2,40 @@ package app
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/sqlite3"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"log/slog"
 	nethttp "net/http"
+	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"syscall"
+	"time"
+	_ "time/tzdata"
+	"yadro-go/internal/adapter/primary"
 	"yadro-go/internal/adapter/primary/http"
+	"yadro-go/internal/adapter/secondary/index"
 	"yadro-go/internal/adapter/secondary/repository"
 	"yadro-go/internal/adapter/secondary/xkcd"
+	"yadro-go/internal/core/domain"
 	"yadro-go/internal/core/service"
 	"yadro-go/internal/core/service/stemming"
 	"yadro-go/internal/core/service/token"
 	"yadro-go/pkg/config"
+	"yadro-go/pkg/cron"
 	"yadro-go/pkg/httpserver"
 	logutil "yadro-go/pkg/logger"
 	"yadro-go/pkg/sqlite"
 )
 
+// updaterShutdownTimeout bounds waiting for a cancelled update to save its last batch.
+const updaterShutdownTimeout = 30 * time.Second
+
 func Run(logger *slog.Logger, cfg *config.Config) error {
 	const op = "app.Run"
 	log := logger.With(slog.String("op", op))
@@ -37,35 +50,88 @@ func Run(logger *slog.Logger, cfg *config.Config) error {
 
 	log.Info("migrations running")
 
-	driver, err := sqlite3.WithInstance(db, &sqlite3.Config{})
-	if err != nil {
-		log.Error("failed to create SQLite driver", logutil.Err(err))
+	if err = migrateUp(log, db, cfg.Migrations, ""); err != nil {
 		return err
 	}
-
-	m, err := migrate.NewWithDatabaseInstance(
-		"file://"+cfg.Migrations, "sqlite3", driver)
-	if err != nil {
-		log.Error("failed to create migration instance", logutil.Err(err))
-		return err
-	}
-
-	if err = m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
-		log.Error("failed to apply migrations", logutil.Err(err))
-		return err
+	if cfg.SearchBackend == config.SearchBackendFTS5 {
+		if err = migrateUp(log, db, filepath.Join(cfg.Migrations, "fts5"), fts5MigrationsTable); err != nil {
+			return err
+		}
 	}
 
 	log.Info("migrations done")
 
 	comicsRepo := repository.NewComicRepository(logger, db)
 	keywordsRepo := repository.NewKeywordRepository(logger, db)
+	searchIndex := index.New(logger, keywordsRepo, index.SnapshotPath(cfg.IndexSnapshot))
+	if err = searchIndex.Load(context.Background()); err != nil {
+		log.Error("failed to load search index", logutil.Err(err))
+		return err
+	}
 	usersRepo := repository.NewUserRepository(logger, db)
 	tokenManager := token.NewJwtTokenManager(logger, []byte(cfg.TokenSecret), cfg.TokenTTL)
 	stemmer := stemming.New()
 
-	client := xkcd.NewHttpClient(logger, cfg.Url, cfg.ReqTimeout)
-	updater := service.NewUpdater(logger, stemmer, comicsRepo, keywordsRepo, client, cfg.FetchLimit, cfg.Parallel)
-	scanner := service.NewScanner(logger, stemmer, comicsRepo, keywordsRepo)
+	clientOpts, err := xkcdOptions(cfg)
+	if err != nil {
+		log.Error("bad fetch config", logutil.Err(err))
+		return err
+	}
+	client := xkcd.NewHttpClient(
+		logger,
+		cfg.Url,
+		cfg.ReqTimeout,
+		clientOpts...,
+	)
+	runsRepo := repository.NewUpdateRunRepository(logger, db)
+	leasesRepo := repository.NewLeaseRepository(logger, db)
+	instanceId := cfg.InstanceId
+	if instanceId == "" {
+		instanceId = defaultInstanceId()
+	}
+	log.Info("instance id: " + instanceId)
+	updater := service.NewUpdater(
+		logger,
+		stemmer,
+		comicsRepo,
+		searchIndex,
+		runsRepo,
+		client,
+		cfg.FetchLimit,
+		cfg.Parallel,
+		service.BatchSize(cfg.FetchBatch),
+		service.RefreshWindow(cfg.RefreshWindow),
+		service.Lease(leasesRepo, instanceId, cfg.LeaseTTL),
+		service.SharedIndex(searchIndex),
+	)
+	if err = updater.InterruptRuns(context.Background()); err != nil {
+		log.Error("failed to record interrupted update runs", logutil.Err(err))
+		return err
+	}
+	if err = updater.ReindexIfIncomplete(context.Background()); err != nil {
+		log.Error("failed to reindex comics", logutil.Err(err))
+		return err
+	}
+	schedule, err := newSchedule(cfg)
+	if err != nil {
+		log.Error("bad scheduler config", logutil.Err(err))
+		return err
+	}
+	highlighter := service.NewHighlighter(stemmer, cfg.SnippetPre, cfg.SnippetPost, cfg.SnippetLength, cfg.SnippetEscape)
+	boosts := map[domain.Field]float64{
+		domain.FieldTitle:      cfg.BoostTitle,
+		domain.FieldAlt:        cfg.BoostAlt,
+		domain.FieldTranscript: cfg.BoostTranscript,
+	}
+
+	var scanner primary.QueryScanner
+	if cfg.SearchBackend == config.SearchBackendFTS5 {
+		fullTextRepo := repository.NewFullTextRepository(logger, db, boosts)
+		scanner = service.NewFullTextScanner(logger, stemmer, comicsRepo, fullTextRepo, highlighter)
+	} else {
+		scanner = service.NewScanner(logger, stemmer, comicsRepo, searchIndex, highlighter, boosts, cfg.FuzzyDistance)
+	}
+	log.Info("search backend: " + cfg.SearchBackend)
 	auth := service.NewAuth(logger, tokenManager, usersRepo)
 
 	handler := nethttp.NewServeMux()
@@ -78,7 +144,7 @@ func Run(logger *slog.Logger, cfg *config.Config) error {
 		auth,
 		cfg.RateLimit,
 		cfg.ConcurrencyLimit,
-		http.ScanTimeout(cfg.ScanTimeout), http.ScanLimit(cfg.ScanLimit),
+		http.ScanTimeout(cfg.ScanTimeout), http.ScanLimit(cfg.ScanLimit), http.ScanMaxLimit(cfg.ScanMaxLimit),
 	)
 	server := httpserver.New(
 		logger,
@@ -89,7 +155,7 @@ func Run(logger *slog.Logger, cfg *config.Config) error {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
 	defer stop()
 
-	go updater.StartScheduler(ctx, cfg.SchedulerHour, cfg.SchedulerMinute)
+	go updater.StartScheduler(ctx, schedule)
 	go server.Start()
 
 	select {
@@ -104,5 +170,108 @@ func Run(logger *slog.Logger, cfg *config.Config) error {
 		return err
 	}
 
+	// the running update is stopped before the database is closed
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), updaterShutdownTimeout)
+	defer cancel()
+	if err = updater.Shutdown(shutdownCtx); err != nil {
+		log.Error("updater shutdown error", logutil.Err(err))
+		return err
+	}
+
 	return err
 }
+
+// defaultInstanceId identifies the process among instances sharing the database on one host.
+func defaultInstanceId() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// xkcdOptions configures retries, limits and the response cache of the xkcd client, the cache directory is created.
+func xkcdOptions(cfg *config.Config) ([]xkcd.Option, error) {
+	opts := []xkcd.Option{
+		xkcd.Retries(cfg.FetchRetries), xkcd.Backoff(cfg.BackoffBase, cfg.BackoffMax),
+		xkcd.RateLimit(cfg.FetchRPS), xkcd.MaxInFlight(cfg.FetchInFlight),
+		xkcd.CircuitBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+	}
+
+	if cfg.FetchCacheDir != "" {
+		if err := os.MkdirAll(cfg.FetchCacheDir, 0o755); err != nil {
+			return nil, err
+		}
+		opts = append(opts, xkcd.CacheDir(cfg.FetchCacheDir))
+	}
+	if cfg.FetchOffline {
+		if cfg.FetchCacheDir == "" {
+			return nil, errors.New("offline fetching requires fetch_cache_dir")
+		}
+		opts = append(opts, xkcd.Offline())
+	}
+	return opts, nil
+}
+
+// newSchedule parses the cron expressions and loads the time zone of the scheduler.
+func newSchedule(cfg *config.Config) (service.Schedule, error) {
+	loc, err := time.LoadLocation(cfg.SchedulerZone)
+	if err != nil {
+		return service.Schedule{}, err
+	}
+
+	crons, err := parseCrons(cfg.SchedulerCron)
+	if err != nil {
+		return service.Schedule{}, err
+	}
+	refreshCrons, err := parseCrons(cfg.RefreshCron)
+	if err != nil {
+		return service.Schedule{}, err
+	}
+
+	return service.Schedule{
+		Crons:        crons,
+		RefreshCrons: refreshCrons,
+		Location:     loc,
+		Jitter:       cfg.SchedulerJitter,
+		CatchUp:      cfg.SchedulerCatchUp,
+	}, nil
+}
+
+func parseCrons(exprs []string) ([]*cron.Schedule, error) {
+	crons := make([]*cron.Schedule, 0, len(exprs))
+	for _, expr := range exprs {
+		c, err := cron.Parse(expr)
+		if err != nil {
+			return nil, err
+		}
+		crons = append(crons, c)
+	}
+	return crons, nil
+}
+
+// fts5MigrationsTable keeps versions of the FTS5 backend migrations apart from the main ones,
+// they are applied only when the backend is selected.
+const fts5MigrationsTable = "schema_migrations_fts5"
+
+// migrateUp applies migrations from the directory, empty table means the default versions table.
+func migrateUp(log *slog.Logger, db *sql.DB, dir string, table string) error {
+	driver, err := sqlite3.WithInstance(db, &sqlite3.Config{MigrationsTable: table})
+	if err != nil {
+		log.Error("failed to create SQLite driver", logutil.Err(err))
+		return err
+	}
+
+	m, err := migrate.NewWithDatabaseInstance("file://"+dir, "sqlite3", driver)
+	if err != nil {
+		log.Error("failed to create migration instance", logutil.Err(err))
+		return err
+	}
+
+	if err = m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		log.Error("failed to apply migrations", logutil.Err(err))
+		return err
+	}
+
+	return nil
+}
@@ -1,5 +1,7 @@
 package domain
 
+import "time"
+
 const (
 	ROLE_USER  = iota
 	ROLE_ADMIN = iota
@@ -13,9 +15,244 @@ type Comic struct {
 	Img        string `json:"img"`
 }
 
+type Field string
+
+const (
+	FieldTitle      Field = "title"
+	FieldAlt        Field = "alt"
+	FieldTranscript Field = "transcript"
+)
+
+var Fields = []Field{FieldTitle, FieldAlt, FieldTranscript}
+
+func ParseField(s string) (Field, bool) {
+	for _, field := range Fields {
+		if string(field) == s {
+			return field, true
+		}
+	}
+	return "", false
+}
+
+type Token struct {
+	Stem  string
+	Word  string
+	Pos   int
+	Field Field
+	// Start and End are byte offsets of the word in the tokenized text
+	Start int
+	End   int
+}
+
+type Posting struct {
+	Num       int
+	Field     Field
+	Freq      int
+	Positions []int
+	// Surfaces counts original words of the keyword in the field, they are saved but not kept by the index
+	Surfaces map[string]int
+}
+
 type ComicKeyword struct {
-	Word string
-	Nums []int
+	Word     string
+	Postings []Posting
+	// Surfaces counts original words the keyword was stemmed from
+	Surfaces map[string]int
+}
+
+type VocabularyWord struct {
+	Word    string
+	DocFreq int
+	// Surface is the most common original word of the stem
+	Surface string
+}
+
+type IndexStats struct {
+	Docs      int
+	AvgLength float64
+	// MaxNum is the greatest indexed comic number
+	MaxNum int
+	// Generation changes with every write to the index
+	Generation int64
+}
+
+type ScanOptions struct {
+	UseIndex bool
+	// Fields restricts query terms without explicit field, all fields are searched when empty
+	Fields []Field
+	// Fuzzy expands query terms to similar indexed words
+	Fuzzy bool
+	// Suggest makes corrected queries when nothing is found
+	Suggest bool
+	// Snippets makes highlighted excerpts of found comics
+	Snippets bool
+	// Limit is a page size, all results are returned when not positive
+	Limit int
+	// Cursor is a position after which the page starts
+	Cursor *PageCursor
+}
+
+// PageCursor is a position in ranked search results. It keeps index stats of the first page,
+// so that following pages are ranked the same way while new comics are indexed.
+type PageCursor struct {
+	Docs      int
+	AvgLength float64
+	// Generation is the index generation of the first page, comics indexed after it are skipped
+	Generation int64
+	Score      float64
+	Num        int
+}
+
+type ScoredComic struct {
+	Comic *Comic
+	Score float64
+	// Terms are indexed stems of the query found in the comic
+	Terms []string
+	// Snippet is an excerpt of the comic text with highlighted terms
+	Snippet string
+}
+
+// FullTextMatch is a comic found by a full-text search engine with its relevance score.
+type FullTextMatch struct {
+	Num   int
+	Score float64
+}
+
+type ScanResult struct {
+	Comics      []*ScoredComic
+	Suggestions []string
+	// Next is a cursor of the next page, nil for the last page
+	Next *PageCursor
+}
+
+// UpdateResult is an outcome of comics update.
+type UpdateResult struct {
+	// Total is the number of stored comics after the update
+	Total int
+	// New is the number of fetched and saved comics
+	New int
+	// Updated is the number of stored comics whose content has changed
+	Updated int
+	// Failures are comics which could not be fetched, they are requested again by the next update
+	Failures []*FetchFailure
+}
+
+type FetchFailure struct {
+	Num   int
+	Error string
+}
+
+// UpdateTrigger is what has started an update.
+type UpdateTrigger string
+
+const (
+	UpdateTriggerManual    UpdateTrigger = "manual"
+	UpdateTriggerScheduler UpdateTrigger = "scheduler"
+)
+
+// UpdateMode is which stored comics an update fetches again to find the edited ones.
+type UpdateMode string
+
+const (
+	// UpdateModeNew fetches new comics and the latest stored ones within the refresh window
+	UpdateModeNew UpdateMode = "new"
+	// UpdateModeRefresh fetches new comics, all stored ones and the ones recorded as missing
+	UpdateModeRefresh UpdateMode = "refresh"
+)
+
+type UpdateJobState string
+
+const (
+	UpdateJobRunning   UpdateJobState = "running"
+	UpdateJobDone      UpdateJobState = "done"
+	UpdateJobFailed    UpdateJobState = "failed"
+	UpdateJobCancelled UpdateJobState = "cancelled"
+)
+
+// UpdateJob is a state of a comics update running in background.
+type UpdateJob struct {
+	Id      int64
+	Trigger UpdateTrigger
+	Mode    UpdateMode
+	// Username is the user who has started a manual update
+	Username string
+	State    UpdateJobState
+	// Total is the number of comics to fetch, it is known once the job has compared stored comics with xkcd
+	Total   int
+	Fetched int
+	Failed  int
+	Started time.Time
+	// Finished is zero while the job is running
+	Finished time.Time
+	Errors   []string
+	// Result is set when the job is done
+	Result *UpdateResult
+}
+
+// UpdateRun is a record of a finished or running update kept in the database.
+type UpdateRun struct {
+	Id       int64
+	Trigger  UpdateTrigger
+	Mode     UpdateMode
+	Username string
+	// Holder is the instance running the update, empty without a lease
+	Holder  string
+	State   UpdateJobState
+	Started time.Time
+	// Finished is zero while the update is running
+	Finished time.Time
+	New      int
+	Updated  int
+	Failed   int
+	Error    string
+}
+
+// UpdateSchedule is when the scheduler runs updates.
+type UpdateSchedule struct {
+	// Active tells if this instance runs scheduled updates, only one of instances sharing the database does
+	Active bool
+	// Location is the time zone the cron expressions are matched in
+	Location string
+	// Jitter is the upper bound of a random delay added to every scheduled time
+	Jitter time.Duration
+	// Next is the time of the next update including the jitter, zero while the scheduler isn't running
+	Next  time.Time
+	Crons []*CronSchedule
+}
+
+// CronSchedule is a cron expression of the scheduler and its next time without the jitter.
+type CronSchedule struct {
+	Expr string
+	// Mode is the mode of updates run at the times of the expression
+	Mode UpdateMode
+	Next time.Time
+}
+
+type UpdateEventType string
+
+const (
+	UpdateEventComicFetched     UpdateEventType = "comic_fetched"
+	UpdateEventComicFailed      UpdateEventType = "comic_failed"
+	UpdateEventBatchSaved       UpdateEventType = "batch_saved"
+	UpdateEventIndexingStarted  UpdateEventType = "indexing_started"
+	UpdateEventIndexingFinished UpdateEventType = "indexing_finished"
+	UpdateEventCompleted        UpdateEventType = "completed"
+)
+
+// UpdateEvent is a progress notification of an update job.
+type UpdateEvent struct {
+	// Id grows with every event, so subscribers can continue after the last seen one
+	Id    int64
+	Type  UpdateEventType
+	JobId int64
+	Time  time.Time
+	// Num is a comic number of comic events
+	Num int
+	// Count is the number of comics in a batch or of new comics of a completed job
+	Count int
+	// State is set for completed events
+	State UpdateJobState
+	Error string
 }
 
 type User struct {
@@ -0,0 +1,48 @@
+package service
+
+import "math"
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// bm25 scores a single term occurrence of a document using Okapi BM25.
+// tf may be weighted by field boosts, df is the number of documents containing the term,
+// docs is the corpus size.
+func bm25(tf float64, df int, length int, docs int, avgLength float64) float64 {
+	if tf == 0 || df == 0 {
+		return 0
+	}
+	if docs < df {
+		docs = df
+	}
+	if avgLength <= 0 {
+		avgLength = 1
+	}
+	if length <= 0 {
+		length = int(math.Round(avgLength))
+	}
+
+	norm := bm25K1 * (1 - bm25B + bm25B*float64(length)/avgLength)
+
+	return bm25Idf(df, docs) * tf * (bm25K1 + 1) / (tf + norm)
+}
+
+// bm25Bound is the greatest bm25 of a term occurrence over all document lengths.
+func bm25Bound(tf float64, df int, docs int) float64 {
+	if tf == 0 || df == 0 {
+		return 0
+	}
+	if docs < df {
+		docs = df
+	}
+
+	norm := bm25K1 * (1 - bm25B)
+
+	return bm25Idf(df, docs) * tf * (bm25K1 + 1) / (tf + norm)
+}
+
+func bm25Idf(df int, docs int) float64 {
+	return math.Log(1 + (float64(docs-df)+0.5)/(float64(df)+0.5))
+}
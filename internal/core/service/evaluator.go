@@ -0,0 +1,320 @@
+package service
+
+import (
+	"golang.org/x/exp/maps"
+	"slices"
+	"yadro-go/internal/core/domain"
+	"yadro-go/internal/core/service/query"
+)
+
+type queryNode interface{}
+
+// queryPhrase is a sequence of stems with their relative positions, a single stem is a plain term.
+// Empty fields means any field.
+type queryPhrase struct {
+	tokens []domain.Token
+	fields []domain.Field
+	// variants are fuzzy alternatives of a single-stem term with their score weights
+	variants map[string]float64
+}
+
+type queryBool struct {
+	must    []queryNode
+	should  []queryNode
+	mustNot []queryNode
+}
+
+// compileQuery stems the parsed query. Terms without meaningful stems are dropped,
+// nil is returned if nothing is left to search for.
+// Terms without explicit field are searched in defaultFields.
+func compileQuery(stemmer Stemmer, node query.Node, defaultFields []domain.Field) queryNode {
+	switch n := node.(type) {
+	case *query.Term:
+		return compilePhrase(stemmer, n.Text, n.Field, defaultFields)
+	case *query.Phrase:
+		return compilePhrase(stemmer, n.Text, n.Field, defaultFields)
+	case *query.Bool:
+		b := &queryBool{
+			must:    compileQueries(stemmer, n.Must, defaultFields),
+			should:  compileQueries(stemmer, n.Should, defaultFields),
+			mustNot: compileQueries(stemmer, n.MustNot, defaultFields),
+		}
+		if len(b.must) == 0 && len(b.should) == 0 {
+			return nil
+		}
+		return b
+	default:
+		return nil
+	}
+}
+
+func compileQueries(stemmer Stemmer, nodes []query.Node, defaultFields []domain.Field) []queryNode {
+	res := make([]queryNode, 0, len(nodes))
+	for _, node := range nodes {
+		if compiled := compileQuery(stemmer, node, defaultFields); compiled != nil {
+			res = append(res, compiled)
+		}
+	}
+	return res
+}
+
+func compilePhrase(stemmer Stemmer, text string, field domain.Field, defaultFields []domain.Field) queryNode {
+	tokens := stemmer.Tokenize(text)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	fields := defaultFields
+	if len(field) > 0 {
+		fields = []domain.Field{field}
+	}
+
+	return &queryPhrase{tokens: tokens, fields: fields}
+}
+
+func queryStems(node queryNode, stems map[string]struct{}) map[string]struct{} {
+	switch n := node.(type) {
+	case *queryPhrase:
+		for _, token := range n.tokens {
+			stems[token.Stem] = struct{}{}
+		}
+		for variant := range n.variants {
+			stems[variant] = struct{}{}
+		}
+	case *queryBool:
+		for _, nodes := range [][]queryNode{n.must, n.should, n.mustNot} {
+			for _, child := range nodes {
+				queryStems(child, stems)
+			}
+		}
+	}
+	return stems
+}
+
+type wordPosting struct {
+	word string
+	domain.Posting
+}
+
+// postingsFromTokens groups comic tokens into postings by stem and field.
+func postingsFromTokens(num int, tokens []domain.Token) []wordPosting {
+	type key struct {
+		word  string
+		field domain.Field
+	}
+
+	indexes := make(map[key]int)
+	postings := make([]wordPosting, 0)
+	for _, token := range tokens {
+		k := key{word: token.Stem, field: token.Field}
+		i, ok := indexes[k]
+		if !ok {
+			i = len(postings)
+			indexes[k] = i
+			postings = append(postings, wordPosting{
+				word:    token.Stem,
+				Posting: domain.Posting{Num: num, Field: token.Field},
+			})
+		}
+
+		postings[i].Freq++
+		postings[i].Positions = append(postings[i].Positions, token.Pos)
+	}
+
+	return postings
+}
+
+type evaluator struct {
+	postings map[string]map[int][]*domain.Posting
+	boosts   map[domain.Field]float64
+	// hits holds boosted term frequencies of every non-negated phrase, used for scoring
+	hits []map[int]float64
+	// terms holds stems of non-negated phrases found in each comic
+	terms map[int]map[string]struct{}
+}
+
+// newEvaluator makes an evaluator over postings of visible comics, nil visible accepts every comic.
+func newEvaluator(keywords []*domain.ComicKeyword, boosts map[domain.Field]float64, visible func(num int) bool) *evaluator {
+	postings := make(map[string]map[int][]*domain.Posting, len(keywords))
+	for _, keyword := range keywords {
+		nums := make(map[int][]*domain.Posting, len(keyword.Postings))
+		for i := range keyword.Postings {
+			posting := &keyword.Postings[i]
+			if visible != nil && !visible(posting.Num) {
+				continue
+			}
+			nums[posting.Num] = append(nums[posting.Num], posting)
+		}
+		postings[keyword.Word] = nums
+	}
+
+	return &evaluator{postings: postings, boosts: boosts, terms: make(map[int]map[string]struct{})}
+}
+
+// eval returns comic numbers matching the node.
+func (e *evaluator) eval(node queryNode, positive bool) map[int]struct{} {
+	switch n := node.(type) {
+	case *queryPhrase:
+		hits := e.matchPhrase(n)
+		if positive {
+			e.hits = append(e.hits, hits)
+			e.addTerms(n, hits)
+		}
+
+		res := make(map[int]struct{}, len(hits))
+		for num := range hits {
+			res[num] = struct{}{}
+		}
+		return res
+	case *queryBool:
+		var res map[int]struct{}
+		if len(n.must) > 0 {
+			for _, child := range n.must {
+				res = intersect(res, e.eval(child, positive))
+			}
+			for _, child := range n.should {
+				e.eval(child, positive)
+			}
+		} else {
+			res = make(map[int]struct{})
+			for _, child := range n.should {
+				for num := range e.eval(child, positive) {
+					res[num] = struct{}{}
+				}
+			}
+		}
+
+		for _, child := range n.mustNot {
+			for num := range e.eval(child, false) {
+				delete(res, num)
+			}
+		}
+		return res
+	default:
+		return make(map[int]struct{})
+	}
+}
+
+// matchPhrase returns the number of phrase occurrences weighted by field boost for each comic containing it.
+func (e *evaluator) matchPhrase(phrase *queryPhrase) map[int]float64 {
+	res := make(map[int]float64)
+
+	e.matchStem(phrase, phrase.tokens[0].Stem, 1, res)
+
+	// variants are summed in a fixed order, so that equal queries get exactly equal scores
+	variants := maps.Keys(phrase.variants)
+	slices.Sort(variants)
+	for _, variant := range variants {
+		e.matchStem(phrase, variant, phrase.variants[variant], res)
+	}
+
+	return res
+}
+
+// matchStem accumulates weighted phrase occurrences, where the first phrase token is replaced by the stem.
+func (e *evaluator) matchStem(phrase *queryPhrase, stem string, weight float64, res map[int]float64) {
+	first := phrase.tokens[0]
+
+	for num, postings := range e.postings[stem] {
+		weighted := 0.0
+		for _, posting := range postings {
+			if len(phrase.fields) > 0 && !slices.Contains(phrase.fields, posting.Field) {
+				continue
+			}
+
+			count := posting.Freq
+			if len(phrase.tokens) > 1 {
+				count = 0
+				for _, pos := range posting.Positions {
+					if e.phraseAt(phrase, num, posting.Field, pos-first.Pos) {
+						count++
+					}
+				}
+			}
+
+			weighted += float64(count) * e.boost(posting.Field) * weight
+		}
+
+		if weighted > 0 {
+			res[num] += weighted
+		}
+	}
+}
+
+// addTerms remembers stems of the phrase for comics it was found in, fuzzy variants are added if present in the comic.
+func (e *evaluator) addTerms(phrase *queryPhrase, hits map[int]float64) {
+	for num := range hits {
+		terms, ok := e.terms[num]
+		if !ok {
+			terms = make(map[string]struct{})
+			e.terms[num] = terms
+		}
+
+		if _, ok = e.postings[phrase.tokens[0].Stem][num]; ok {
+			for _, token := range phrase.tokens {
+				terms[token.Stem] = struct{}{}
+			}
+		}
+		for variant := range phrase.variants {
+			if _, ok = e.postings[variant][num]; ok {
+				terms[variant] = struct{}{}
+			}
+		}
+	}
+}
+
+func (e *evaluator) phraseAt(phrase *queryPhrase, num int, field domain.Field, start int) bool {
+	for _, token := range phrase.tokens[1:] {
+		postings := e.postings[token.Stem][num]
+		i := slices.IndexFunc(postings, func(p *domain.Posting) bool {
+			return p.Field == field
+		})
+		if i < 0 {
+			return false
+		}
+		if _, found := slices.BinarySearch(postings[i].Positions, start+token.Pos); !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *evaluator) boost(field domain.Field) float64 {
+	if boost, ok := e.boosts[field]; ok {
+		return boost
+	}
+	return 1
+}
+
+// match scores the comic by the sum of BM25 of every matched phrase.
+func (e *evaluator) match(num int, length int, stats *domain.IndexStats) *NumMatch {
+	match := &NumMatch{num: num, terms: maps.Keys(e.terms[num])}
+	for _, hits := range e.hits {
+		match.score += bm25(hits[num], len(hits), length, stats.Docs, stats.AvgLength)
+	}
+	slices.Sort(match.terms)
+	return match
+}
+
+// bound is an upper bound of the comic score, it does not depend on comic length.
+func (e *evaluator) bound(num int, stats *domain.IndexStats) float64 {
+	bound := 0.0
+	for _, hits := range e.hits {
+		bound += bm25Bound(hits[num], len(hits), stats.Docs)
+	}
+	return bound
+}
+
+func intersect(a map[int]struct{}, b map[int]struct{}) map[int]struct{} {
+	if a == nil {
+		return b
+	}
+
+	res := make(map[int]struct{})
+	for num := range a {
+		if _, ok := b[num]; ok {
+			res[num] = struct{}{}
+		}
+	}
+	return res
+}
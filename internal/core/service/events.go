@@ -0,0 +1,97 @@
+package service
+
+import (
+	"sync"
+	"time"
+	"yadro-go/internal/core/domain"
+)
+
+const (
+	// eventsHistory is the number of recent events replayed to reconnected subscribers
+	eventsHistory = 1000
+	// subscriberBuffer is the number of events a subscriber may lag behind before it is dropped
+	subscriberBuffer = 256
+)
+
+// eventBus delivers update events to subscribers and keeps recent ones for replay.
+type eventBus struct {
+	mu          sync.Mutex
+	lastId      int64
+	history     []*domain.UpdateEvent
+	subscribers map[chan *domain.UpdateEvent]struct{}
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subscribers: make(map[chan *domain.UpdateEvent]struct{})}
+}
+
+// publish never blocks: a subscriber which doesn't keep up is dropped by closing its channel,
+// it is expected to subscribe again with the last received event id.
+func (b *eventBus) publish(event domain.UpdateEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastId++
+	event.Id = b.lastId
+	event.Time = time.Now()
+
+	b.history = append(b.history, &event)
+	if len(b.history) > eventsHistory {
+		b.history = b.history[len(b.history)-eventsHistory:]
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- &event:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe returns kept events following lastId and a channel of the next ones.
+// Ids greater than the last published one come from before a restart, then all kept events are replayed.
+func (b *eventBus) subscribe(lastId int64) ([]*domain.UpdateEvent, <-chan *domain.UpdateEvent, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if lastId > b.lastId {
+		lastId = 0
+	}
+
+	missed := make([]*domain.UpdateEvent, 0)
+	for _, event := range b.history {
+		if event.Id > lastId {
+			missed = append(missed, event)
+		}
+	}
+
+	ch := make(chan *domain.UpdateEvent, subscriberBuffer)
+	b.subscribers[ch] = struct{}{}
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return missed, ch, unsubscribe
+}
+
+// SubscribeEvents returns update events published after lastEventId, which are still kept, and a channel
+// of the following ones. The channel is closed when the subscriber falls behind or unsubscribes.
+func (u *Updater) SubscribeEvents(lastEventId int64) ([]*domain.UpdateEvent, <-chan *domain.UpdateEvent, func()) {
+	return u.events.subscribe(lastEventId)
+}
+
+// notify is the progress hook of update jobs: it updates the job state and publishes the event.
+func (u *Updater) notify(job *updateJob, event domain.UpdateEvent) {
+	event.JobId = job.id()
+	job.apply(event)
+	u.events.publish(event)
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"yadro-go/internal/core/domain"
+)
+
+func TestEventBus(t *testing.T) {
+	t.Parallel()
+
+	ids := func(events []*domain.UpdateEvent) []int64 {
+		res := make([]int64, len(events))
+		for i, event := range events {
+			res[i] = event.Id
+		}
+		return res
+	}
+
+	t.Run("MultipleSubscribers", func(t *testing.T) {
+		t.Parallel()
+
+		b := newEventBus()
+		_, first, unsubscribeFirst := b.subscribe(0)
+		defer unsubscribeFirst()
+		_, second, unsubscribeSecond := b.subscribe(0)
+		defer unsubscribeSecond()
+
+		b.publish(domain.UpdateEvent{Type: domain.UpdateEventComicFetched, Num: 1})
+
+		for _, ch := range []<-chan *domain.UpdateEvent{first, second} {
+			event := <-ch
+			assert.Equal(t, int64(1), event.Id)
+			assert.Equal(t, 1, event.Num)
+		}
+	})
+
+	t.Run("Replay", func(t *testing.T) {
+		t.Parallel()
+
+		b := newEventBus()
+		for i := 0; i < 3; i++ {
+			b.publish(domain.UpdateEvent{Type: domain.UpdateEventComicFetched})
+		}
+
+		missed, _, unsubscribe := b.subscribe(1)
+		unsubscribe()
+		assert.Equal(t, []int64{2, 3}, ids(missed))
+
+		// ids from before a restart
+		missed, _, unsubscribe = b.subscribe(10)
+		unsubscribe()
+		assert.Equal(t, []int64{1, 2, 3}, ids(missed))
+	})
+
+	t.Run("HistoryLimit", func(t *testing.T) {
+		t.Parallel()
+
+		b := newEventBus()
+		for i := 0; i < eventsHistory+5; i++ {
+			b.publish(domain.UpdateEvent{Type: domain.UpdateEventComicFetched})
+		}
+
+		missed, _, unsubscribe := b.subscribe(0)
+		unsubscribe()
+		assert.Len(t, missed, eventsHistory)
+		assert.Equal(t, int64(6), missed[0].Id)
+	})
+
+	t.Run("SlowSubscriberDropped", func(t *testing.T) {
+		t.Parallel()
+
+		b := newEventBus()
+		_, ch, unsubscribe := b.subscribe(0)
+		defer unsubscribe()
+
+		for i := 0; i < subscriberBuffer+1; i++ {
+			b.publish(domain.UpdateEvent{Type: domain.UpdateEventComicFetched})
+		}
+
+		received := 0
+		for range ch {
+			received++
+		}
+		assert.Equal(t, subscriberBuffer, received)
+	})
+}
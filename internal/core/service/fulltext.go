@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"yadro-go/internal/core/domain"
+	"yadro-go/internal/core/service/query"
+	"yadro-go/pkg/logger"
+)
+
+// FullTextScanner answers search queries with an external full-text engine instead of the keyword index.
+// Fuzzy matching and suggestions are not supported, Should clauses next to Must ones do not affect relevance.
+type FullTextScanner struct {
+	log         *slog.Logger
+	stemmer     Stemmer
+	comicRepo   ComicRepository
+	searcher    FullTextSearcher
+	highlighter *Highlighter
+}
+
+func NewFullTextScanner(
+	log *slog.Logger,
+	stemmer Stemmer,
+	comicRepo ComicRepository,
+	searcher FullTextSearcher,
+	highlighter *Highlighter,
+) *FullTextScanner {
+	return &FullTextScanner{
+		log:         log,
+		stemmer:     stemmer,
+		comicRepo:   comicRepo,
+		searcher:    searcher,
+		highlighter: highlighter,
+	}
+}
+
+func (s *FullTextScanner) Scan(ctx context.Context, search string, opts domain.ScanOptions) (*domain.ScanResult, error) {
+	const op = "fulltext.Scan"
+	log := s.log.With(slog.String("op", op))
+
+	node, err := query.Parse(search)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %w", op, ErrBadQuery, err)
+	}
+
+	result := &domain.ScanResult{Comics: make([]*domain.ScoredComic, 0)}
+
+	b := &matchBuilder{stemmer: s.stemmer, fields: opts.Fields, stems: make(map[string]struct{})}
+	match := b.build(node, false)
+	if len(match) == 0 {
+		return result, nil
+	}
+
+	limit := opts.Limit
+	if limit > 0 {
+		// one extra match tells whether there is a next page
+		limit++
+	}
+
+	matches, err := s.searcher.Search(ctx, match, limit, opts.Cursor)
+	if err != nil {
+		log.Error("failed to search comics", logger.Err(err))
+		return nil, err
+	}
+
+	if opts.Limit > 0 && len(matches) > opts.Limit {
+		matches = matches[:opts.Limit]
+		last := matches[len(matches)-1]
+		result.Next = &domain.PageCursor{Score: last.Score, Num: last.Num}
+	}
+
+	if len(matches) == 0 {
+		return result, nil
+	}
+
+	nums := make([]int, len(matches))
+	for i, m := range matches {
+		nums[i] = m.Num
+	}
+	comics, err := s.comicRepo.Comics(ctx, nums)
+	if err != nil {
+		log.Error("failed to get comics", logger.Err(err))
+		return nil, err
+	}
+
+	page := make([]*NumMatch, len(matches))
+	for i, m := range matches {
+		page[i] = &NumMatch{num: m.Num, score: m.Score}
+	}
+	result.Comics = finalizeResult(comics, page)
+
+	for _, scored := range result.Comics {
+		scored.Terms = s.matchedTerms(scored.Comic, b.stems)
+		if opts.Snippets && s.highlighter != nil {
+			scored.Snippet = s.highlighter.Snippet(scored.Comic, scored.Terms)
+		}
+	}
+
+	log.Debug(fmt.Sprintf("scan finished: %d comics on the page", len(result.Comics)))
+	return result, nil
+}
+
+// matchedTerms returns query stems found in the comic.
+func (s *FullTextScanner) matchedTerms(comic *domain.Comic, stems map[string]struct{}) []string {
+	found := make(map[string]struct{})
+	for _, token := range s.stemmer.StemComic(comic) {
+		if _, ok := stems[token.Stem]; ok {
+			found[token.Stem] = struct{}{}
+		}
+	}
+
+	terms := make([]string, 0, len(found))
+	for stem := range found {
+		terms = append(terms, stem)
+	}
+	slices.Sort(terms)
+	return terms
+}
+
+// matchBuilder translates a parsed query into an FTS5 match expression. Words are passed as they are
+// written, the engine tokenizer stems them. Stems of non-negated words are collected to report matched terms.
+type matchBuilder struct {
+	stemmer Stemmer
+	fields  []domain.Field
+	stems   map[string]struct{}
+}
+
+// build returns an empty string for a node that can not match anything.
+func (b *matchBuilder) build(node query.Node, negated bool) string {
+	switch n := node.(type) {
+	case *query.Term:
+		return b.phrase(n.Text, n.Field, negated)
+	case *query.Phrase:
+		return b.phrase(n.Text, n.Field, negated)
+	case *query.Bool:
+		positive := b.join(n.Must, " AND ", negated)
+		if len(positive) == 0 {
+			positive = b.join(n.Should, " OR ", negated)
+		}
+		if len(positive) == 0 {
+			return ""
+		}
+
+		if negative := b.join(n.MustNot, " OR ", !negated); len(negative) > 0 {
+			return "(" + positive + " NOT " + negative + ")"
+		}
+		return positive
+	default:
+		return ""
+	}
+}
+
+func (b *matchBuilder) join(nodes []query.Node, operator string, negated bool) string {
+	exprs := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		if expr := b.build(node, negated); len(expr) > 0 {
+			exprs = append(exprs, expr)
+		}
+	}
+
+	switch len(exprs) {
+	case 0:
+		return ""
+	case 1:
+		return exprs[0]
+	default:
+		return "(" + strings.Join(exprs, operator) + ")"
+	}
+}
+
+// phrase quotes the text from the first to the last word left after tokenizing. Ignored words around
+// them are dropped like the keyword index does it, the ones between them are kept, as the engine tokenizer
+// keeps stop words in the documents and the phrase has to span the same gaps the keyword index positions have.
+func (b *matchBuilder) phrase(text string, field domain.Field, negated bool) string {
+	tokens := b.stemmer.Tokenize(text)
+	if len(tokens) == 0 {
+		return ""
+	}
+
+	if !negated {
+		for _, token := range tokens {
+			b.stems[token.Stem] = struct{}{}
+		}
+	}
+	words := strings.ToLower(text[tokens[0].Start:tokens[len(tokens)-1].End])
+	expr := `"` + strings.ReplaceAll(words, `"`, `""`) + `"`
+
+	fields := b.fields
+	if len(field) > 0 {
+		fields = []domain.Field{field}
+	}
+
+	switch len(fields) {
+	case 0:
+		return expr
+	case 1:
+		return string(fields[0]) + " : " + expr
+	default:
+		columns := make([]string, len(fields))
+		for i, f := range fields {
+			columns[i] = string(f)
+		}
+		return "{" + strings.Join(columns, " ") + "} : " + expr
+	}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"testing"
+	"yadro-go/internal/core/domain"
+	mock_service "yadro-go/internal/core/service/mocks"
+	"yadro-go/internal/core/service/query"
+	"yadro-go/internal/core/service/stemming"
+	"yadro-go/test/logger"
+)
+
+func TestMatchBuilder(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name     string
+		search   string
+		fields   []domain.Field
+		expected string
+	}{
+		{name: "Words", search: "python code", expected: `("python" OR "code")`},
+		{name: "Must", search: "+python +code snake", expected: `("python" AND "code")`},
+		{name: "MustNot", search: "python -snake", expected: `("python" NOT "snake")`},
+		{name: "Phrase", search: `"Regular Expressions"`, expected: `"regular expressions"`},
+		{name: "StopWords", search: "the python", expected: `"python"`},
+		{name: "PhraseStopWords", search: `"the drop the table"`, expected: `"drop the table"`},
+		{name: "OnlyStopWords", search: "the", expected: ""},
+		{name: "Field", search: "title:python", expected: `title : "python"`},
+		{name: "DefaultFields", search: "python", fields: []domain.Field{domain.FieldTitle, domain.FieldAlt},
+			expected: `{title alt} : "python"`},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			node, err := query.Parse(testCase.search)
+			require.NoError(t, err)
+
+			b := &matchBuilder{stemmer: stemming.New(), fields: testCase.fields, stems: make(map[string]struct{})}
+			assert.Equal(t, testCase.expected, b.build(node, false))
+		})
+	}
+}
+
+func TestFullTextScanner_Scan(t *testing.T) {
+	t.Parallel()
+
+	var comic1 = &domain.Comic{Num: 1, Title: "Python"}
+	var comic2 = &domain.Comic{Num: 2, Title: "Python code"}
+
+	c := gomock.NewController(t)
+	comicRepo := mock_service.NewMockComicRepository(c)
+	searcher := mock_service.NewMockFullTextSearcher(c)
+
+	cursor := &domain.PageCursor{Score: 3, Num: 5}
+	searcher.EXPECT().Search(gomock.Any(), `("python" NOT "snake")`, 3, cursor).
+		Return([]*domain.FullTextMatch{{Num: 2, Score: 2}, {Num: 1, Score: 1}, {Num: 3, Score: 0.5}}, nil)
+	comicRepo.EXPECT().Comics(gomock.Any(), []int{2, 1}).Return([]*domain.Comic{comic1, comic2}, nil)
+
+	s := NewFullTextScanner(slog.New(logger.EmptyHandler{}), stemming.New(), comicRepo, searcher, nil)
+	result, err := s.Scan(context.Background(), "python -snake", domain.ScanOptions{Limit: 2, Cursor: cursor})
+	require.NoError(t, err)
+
+	assert.Equal(t, []*domain.ScoredComic{
+		{Comic: comic2, Score: 2, Terms: []string{"python"}},
+		{Comic: comic1, Score: 1, Terms: []string{"python"}},
+	}, result.Comics)
+	assert.Equal(t, &domain.PageCursor{Score: 1, Num: 1}, result.Next)
+}
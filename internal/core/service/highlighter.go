@@ -0,0 +1,157 @@
+package service
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+	"yadro-go/internal/core/domain"
+)
+
+const snippetEllipsis = "..."
+
+type Highlighter struct {
+	stemmer    Stemmer
+	pre        string
+	post       string
+	length     int
+	escapeHTML bool
+}
+
+// NewHighlighter makes a highlighter of snippets up to length bytes, matched words are wrapped in pre and post markers.
+// If escapeHTML is set, text around markers is HTML-escaped.
+func NewHighlighter(stemmer Stemmer, pre string, post string, length int, escapeHTML bool) *Highlighter {
+	return &Highlighter{
+		stemmer:    stemmer,
+		pre:        pre,
+		post:       post,
+		length:     length,
+		escapeHTML: escapeHTML,
+	}
+}
+
+// Snippet returns an excerpt of the comic transcript or alt text with the most terms, terms are matched by stem.
+// Empty string is returned if neither contains the terms.
+func (h *Highlighter) Snippet(comic *domain.Comic, terms []string) string {
+	if len(terms) == 0 {
+		return ""
+	}
+
+	stems := make(map[string]struct{}, len(terms))
+	for _, term := range terms {
+		stems[term] = struct{}{}
+	}
+
+	var text string
+	var matched []domain.Token
+	for _, candidate := range []string{comic.Transcript, comic.Alt} {
+		if found := h.match(candidate, stems); len(found) > len(matched) {
+			text, matched = candidate, found
+		}
+	}
+
+	if len(matched) == 0 {
+		return ""
+	}
+
+	start, end, matched := h.window(text, matched)
+	return h.render(text, start, end, matched)
+}
+
+func (h *Highlighter) match(text string, stems map[string]struct{}) []domain.Token {
+	matched := make([]domain.Token, 0)
+	for _, token := range h.stemmer.Tokenize(text) {
+		if _, ok := stems[token.Stem]; ok {
+			matched = append(matched, token)
+		}
+	}
+	return matched
+}
+
+// window finds byte range of the snippet holding the most matched tokens, the tokens are centered
+// and the range is extended to word boundaries. Matched tokens inside the range are returned.
+func (h *Highlighter) window(text string, matched []domain.Token) (int, int, []domain.Token) {
+	first, last := 0, 0
+	for i := range matched {
+		j := i
+		for j+1 < len(matched) && matched[j+1].End-matched[i].Start <= h.length {
+			j++
+		}
+		if j-i > last-first {
+			first, last = i, j
+		}
+	}
+	matched = matched[first : last+1]
+
+	from, to := matched[0].Start, matched[len(matched)-1].End
+	context := max(0, h.length-(to-from)) / 2
+
+	start := max(0, from-context)
+	if r, _ := utf8.DecodeLastRuneInString(text[:start]); start > 0 && !unicode.IsSpace(r) {
+		if i := strings.IndexFunc(text[start:from], unicode.IsSpace); i >= 0 {
+			start += i + 1
+		} else {
+			start = from
+		}
+	}
+
+	end := min(len(text), max(to, start+h.length))
+	if r, _ := utf8.DecodeRuneInString(text[end:]); end < len(text) && !unicode.IsSpace(r) {
+		if i := strings.LastIndexFunc(text[to:end], unicode.IsSpace); i >= 0 {
+			end = to + i
+		} else {
+			end = to
+		}
+	}
+
+	return start, end, matched
+}
+
+func (h *Highlighter) render(text string, start int, end int, matched []domain.Token) string {
+	var sb strings.Builder
+
+	if start > 0 {
+		sb.WriteString(snippetEllipsis)
+	}
+
+	last := start
+	for _, token := range matched {
+		sb.WriteString(h.text(text[last:token.Start]))
+		sb.WriteString(h.pre)
+		sb.WriteString(h.text(text[token.Start:token.End]))
+		sb.WriteString(h.post)
+		last = token.End
+	}
+	sb.WriteString(h.text(text[last:end]))
+
+	if end < len(text) {
+		sb.WriteString(snippetEllipsis)
+	}
+
+	return sb.String()
+}
+
+// text collapses whitespace, so that transcript lines are joined into a single line.
+func (h *Highlighter) text(str string) string {
+	var sb strings.Builder
+	space := false
+	for _, r := range str {
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space {
+			sb.WriteRune(' ')
+			space = false
+		}
+		sb.WriteRune(r)
+	}
+	if space {
+		sb.WriteRune(' ')
+	}
+
+	if h.escapeHTML {
+		return html.EscapeString(sb.String())
+	}
+	return sb.String()
+}
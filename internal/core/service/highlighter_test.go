@@ -0,0 +1,64 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"yadro-go/internal/core/domain"
+	"yadro-go/internal/core/service/stemming"
+)
+
+func TestHighlighter_Snippet(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name     string
+		comic    *domain.Comic
+		terms    []string
+		length   int
+		expected string
+	}{
+		{
+			name:     "ByStem",
+			comic:    &domain.Comic{Transcript: "He was running fast."},
+			terms:    []string{"run"},
+			length:   100,
+			expected: "He was <em>running</em> fast.",
+		},
+		{
+			name:     "MostTermsField",
+			comic:    &domain.Comic{Transcript: "A python.", Alt: "Pythons write python code."},
+			terms:    []string{"python", "code"},
+			length:   100,
+			expected: "<em>Pythons</em> write <em>python</em> <em>code</em>.",
+		},
+		{
+			name:     "Window",
+			comic:    &domain.Comic{Transcript: "one two three four five six\nseven <sql> tables eight nine ten eleven"},
+			terms:    []string{"tabl"},
+			length:   30,
+			expected: "...seven &lt;sql&gt; <em>tables</em> eight nine...",
+		},
+		{
+			name:     "NotFound",
+			comic:    &domain.Comic{Transcript: "He was running fast."},
+			terms:    []string{"python"},
+			length:   100,
+			expected: "",
+		},
+		{
+			name:     "NoTerms",
+			comic:    &domain.Comic{Transcript: "He was running fast."},
+			length:   100,
+			expected: "",
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := NewHighlighter(stemming.New(), "<em>", "</em>", testCase.length, true)
+			assert.Equal(t, testCase.expected, h.Snippet(testCase.comic, testCase.terms))
+		})
+	}
+}
@@ -2,29 +2,79 @@ package service
 
 import (
 	"context"
+	"time"
 	"yadro-go/internal/core/domain"
 )
 
 //go:generate mockgen -source=interfaces.go -destination=mocks/mock.go
 
 type Stemmer interface {
-	StemString(str string) []string
-	StemComic(comic *domain.Comic) []string
+	Tokenize(str string) []domain.Token
+	StemComic(comic *domain.Comic) []domain.Token
 }
 
 type ComicProvider interface {
-	GetById(id int) (*domain.Comic, error)
+	GetById(ctx context.Context, id int) (*domain.Comic, error)
+	// LastNum returns the number of the latest published comic
+	LastNum(ctx context.Context) (int, error)
 }
 
 type ComicRepository interface {
 	Comics(ctx context.Context, nums []int) ([]*domain.Comic, error)
 	All(ctx context.Context) ([]*domain.Comic, error)
 	Save(ctx context.Context, comics []*domain.Comic) error
+	// Missing returns numbers of comics known not to exist
+	Missing(ctx context.Context) ([]int, error)
+	SaveMissing(ctx context.Context, nums []int) error
 }
 
 type KeywordRepository interface {
 	Keywords(ctx context.Context, keywords []string) ([]*domain.ComicKeyword, error)
-	Save(ctx context.Context, keywords []*domain.ComicKeyword) error
+	Lengths(ctx context.Context, nums []int) (map[int]int, error)
+	// Generations returns index generations the comics were first indexed at
+	Generations(ctx context.Context, nums []int) (map[int]int64, error)
+	Stats(ctx context.Context) (*domain.IndexStats, error)
+	Vocabulary(ctx context.Context) ([]*domain.VocabularyWord, error)
+	// Save replaces postings of comics in lengths in one transaction
+	Save(ctx context.Context, keywords []*domain.ComicKeyword, lengths map[int]int) error
+	// Reindex replaces the whole index in one transaction
+	Reindex(ctx context.Context, keywords []*domain.ComicKeyword, lengths map[int]int) error
+}
+
+// IndexReloader keeps an in-memory copy of the index current with writes of other instances.
+type IndexReloader interface {
+	// Reload loads the index again when the repository has been written since it was loaded
+	Reload(ctx context.Context) error
+}
+
+// FullTextSearcher finds comics by an FTS5 match expression, best first. Matches follow the cursor,
+// all of them are returned when limit is not positive.
+type FullTextSearcher interface {
+	Search(ctx context.Context, match string, limit int, after *domain.PageCursor) ([]*domain.FullTextMatch, error)
+}
+
+type UpdateRunRepository interface {
+	// Start records a running update and returns its id
+	Start(ctx context.Context, run *domain.UpdateRun) (int64, error)
+	// Finish records the outcome of the run with the id
+	Finish(ctx context.Context, run *domain.UpdateRun) error
+	// Runs returns up to limit runs started before the one with the id, latest first.
+	// Zero id means the latest runs.
+	Runs(ctx context.Context, limit int, before int64) ([]*domain.UpdateRun, error)
+	// Interrupt marks runs left running by a stopped process as failed, runs of the live holder
+	// of the lease are kept
+	Interrupt(ctx context.Context, lease string) error
+	// LastSuccess returns when the latest successful run has finished, zero time if there is none
+	LastSuccess(ctx context.Context) (time.Time, error)
+}
+
+// LeaseRepository keeps leases shared by server instances on one database. A lease is held
+// until it expires, the holder extends it by acquiring again.
+type LeaseRepository interface {
+	// Acquire takes the lease for ttl if it is free, expired or held by the holder, and reports if it is taken
+	Acquire(ctx context.Context, name string, holder string, ttl time.Duration) (bool, error)
+	// Release frees the lease if it is held by the holder
+	Release(ctx context.Context, name string, holder string) error
 }
 
 type UserRepository interface {
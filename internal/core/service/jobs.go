@@ -0,0 +1,395 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+	"yadro-go/internal/core/domain"
+	"yadro-go/pkg/logger"
+)
+
+// maxJobs is the number of update jobs kept for lookups, the oldest finished ones are forgotten first.
+const maxJobs = 100
+
+// updateJob tracks progress of a single update run.
+type updateJob struct {
+	mu     sync.Mutex
+	job    domain.UpdateJob
+	err    error
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (j *updateJob) snapshot() *domain.UpdateJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job := j.job
+	job.Errors = slices.Clone(j.job.Errors)
+	return &job
+}
+
+// run makes a record of the finished job.
+func (j *updateJob) run() *domain.UpdateRun {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	run := &domain.UpdateRun{
+		Id:       j.job.Id,
+		Trigger:  j.job.Trigger,
+		Mode:     j.job.Mode,
+		Username: j.job.Username,
+		State:    j.job.State,
+		Started:  j.job.Started,
+		Finished: j.job.Finished,
+		Failed:   j.job.Failed,
+	}
+	if j.job.Result != nil {
+		run.New = j.job.Result.New
+		run.Updated = j.job.Result.Updated
+	}
+	if j.err != nil {
+		run.Error = j.err.Error()
+	}
+	return run
+}
+
+func (j *updateJob) setTotal(total int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.job.Total = total
+}
+
+func (j *updateJob) id() int64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.job.Id
+}
+
+// apply counts comic events in the job progress.
+func (j *updateJob) apply(event domain.UpdateEvent) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	switch event.Type {
+	case domain.UpdateEventComicFetched:
+		j.job.Fetched++
+	case domain.UpdateEventComicFailed:
+		j.job.Failed++
+		j.job.Errors = append(j.job.Errors, fmt.Sprintf("comic %d: %s", event.Num, event.Error))
+	}
+}
+
+func (j *updateJob) finish(ctx context.Context, result *domain.UpdateResult, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.job.Finished = time.Now()
+	j.job.Result = result
+	j.err = err
+
+	switch {
+	case errors.Is(context.Cause(ctx), ErrLeaseLost):
+		j.job.State = domain.UpdateJobFailed
+		j.err = ErrLeaseLost
+		err = ErrLeaseLost
+	case ctx.Err() != nil:
+		j.job.State = domain.UpdateJobCancelled
+	case err != nil:
+		j.job.State = domain.UpdateJobFailed
+	default:
+		j.job.State = domain.UpdateJobDone
+	}
+	if err != nil {
+		j.job.Errors = append(j.job.Errors, err.Error())
+	}
+}
+
+// startJob takes the update lock and lease, records the run and runs the update in background.
+// The job outlives ctx, it is cancelled by CancelUpdate or Shutdown. Its id is the id of the run.
+func (u *Updater) startJob(
+	ctx context.Context,
+	trigger domain.UpdateTrigger,
+	mode domain.UpdateMode,
+	username string,
+) (*updateJob, error) {
+	const op = "updater.startJob"
+	log := u.log.With(slog.String("op", op))
+
+	if !u.mu.TryLock() {
+		return nil, ErrUpdateInProgress
+	}
+
+	if err := u.acquireUpdateLease(ctx); err != nil {
+		u.mu.Unlock()
+		return nil, err
+	}
+
+	run := &domain.UpdateRun{
+		Trigger:  trigger,
+		Mode:     mode,
+		Username: username,
+		State:    domain.UpdateJobRunning,
+		Started:  time.Now(),
+	}
+	if u.updateLease != nil {
+		run.Holder = u.updateLease.holder
+	}
+	id, err := u.runRepo.Start(ctx, run)
+	if err != nil {
+		u.updateLease.release(context.WithoutCancel(ctx))
+		u.mu.Unlock()
+		log.Error("failed to record update run", logger.Err(err))
+		return nil, ErrInternal
+	}
+
+	jobCtx, cancel := context.WithCancelCause(u.jobsCtx)
+	job := &updateJob{
+		job: domain.UpdateJob{
+			Id:       id,
+			Trigger:  trigger,
+			Mode:     mode,
+			Username: username,
+			State:    domain.UpdateJobRunning,
+			Started:  run.Started,
+		},
+		cancel: func() { cancel(context.Canceled) },
+		done:   make(chan struct{}),
+	}
+	u.addJob(job)
+
+	go u.updateLease.keep(jobCtx, func() { cancel(ErrLeaseLost) })
+
+	go func() {
+		defer u.mu.Unlock()
+		defer u.updateLease.release(context.WithoutCancel(jobCtx))
+		defer cancel(context.Canceled)
+
+		result, err := u.update(jobCtx, job)
+		job.finish(jobCtx, result, err)
+
+		// the run is recorded even when the job is cancelled
+		if err := u.runRepo.Finish(context.WithoutCancel(jobCtx), job.run()); err != nil {
+			u.log.With(slog.String("op", op)).Error("failed to record update run", logger.Err(err))
+		}
+
+		completed := domain.UpdateEvent{Type: domain.UpdateEventCompleted, State: job.snapshot().State}
+		if result != nil {
+			completed.Count = result.New
+		}
+		if err != nil {
+			completed.Error = err.Error()
+		}
+		u.notify(job, completed)
+		close(job.done)
+	}()
+
+	return job, nil
+}
+
+// acquireUpdateLease takes the update lease, runs left by an instance whose lease has expired
+// are marked as interrupted then, as no other instance is updating.
+func (u *Updater) acquireUpdateLease(ctx context.Context) error {
+	const op = "updater.acquireUpdateLease"
+	log := u.log.With(slog.String("op", op))
+
+	if u.updateLease == nil {
+		return nil
+	}
+
+	ok, err := u.updateLease.acquire(ctx)
+	if err != nil {
+		log.Error("failed to acquire update lease", logger.Err(err))
+		return ErrInternal
+	}
+	if !ok {
+		log.Debug("update lease is held by another instance")
+		return ErrUpdateInProgress
+	}
+
+	if err = u.runRepo.Interrupt(ctx, updateLeaseName); err != nil {
+		u.updateLease.release(context.WithoutCancel(ctx))
+		log.Error("failed to record interrupted update runs", logger.Err(err))
+		return ErrInternal
+	}
+
+	// the previous holder may have been another instance, writes have to go to an index which has its changes.
+	// The index is loaded again only when its generation has changed
+	if err = u.reloadIndex(ctx); err != nil {
+		u.updateLease.release(context.WithoutCancel(ctx))
+		log.Error("failed to reload index", logger.Err(err))
+		return ErrInternal
+	}
+
+	return nil
+}
+
+// withUpdateLease runs fn holding the update lease like an update job does, so that it doesn't write
+// along with an update of another instance. The lease is renewed while fn runs, ctx of fn is cancelled
+// when the lease is lost.
+func (u *Updater) withUpdateLease(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := u.acquireUpdateLease(ctx); err != nil {
+		return err
+	}
+
+	leaseCtx, cancel := context.WithCancelCause(ctx)
+	defer u.updateLease.release(context.WithoutCancel(ctx))
+	defer cancel(context.Canceled)
+
+	go u.updateLease.keep(leaseCtx, func() { cancel(ErrLeaseLost) })
+
+	err := fn(leaseCtx)
+	if errors.Is(context.Cause(leaseCtx), ErrLeaseLost) {
+		return ErrLeaseLost
+	}
+	return err
+}
+
+// reloadIndex picks up index writes of other instances, it does nothing without a shared index.
+func (u *Updater) reloadIndex(ctx context.Context) error {
+	if u.index == nil {
+		return nil
+	}
+	return u.index.Reload(ctx)
+}
+
+// InterruptRuns marks runs left running by a stopped instance as failed, runs of an instance holding
+// the update lease are kept. With a lease it does nothing while another instance is updating.
+func (u *Updater) InterruptRuns(ctx context.Context) error {
+	const op = "updater.InterruptRuns"
+
+	if u.updateLease == nil {
+		if err := u.runRepo.Interrupt(ctx, updateLeaseName); err != nil {
+			return fmt.Errorf("%s: %w", op, ErrInternal)
+		}
+		return nil
+	}
+
+	if err := u.acquireUpdateLease(ctx); err != nil {
+		if errors.Is(err, ErrUpdateInProgress) {
+			return nil
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	u.updateLease.release(ctx)
+	return nil
+}
+
+func (u *Updater) addJob(job *updateJob) {
+	u.jobsMu.Lock()
+	defer u.jobsMu.Unlock()
+
+	u.jobs[job.job.Id] = job
+	u.jobIds = append(u.jobIds, job.job.Id)
+
+	// only the latest job may be running, as the update lock allows one at a time
+	if len(u.jobIds) > maxJobs {
+		delete(u.jobs, u.jobIds[0])
+		u.jobIds = u.jobIds[1:]
+	}
+}
+
+func (u *Updater) findJob(id int64) (*updateJob, error) {
+	u.jobsMu.Lock()
+	defer u.jobsMu.Unlock()
+
+	job, ok := u.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return job, nil
+}
+
+// StartUpdate runs an update in background and returns its job right away.
+// The update outlives ctx, it is cancelled by CancelUpdate or Shutdown.
+func (u *Updater) StartUpdate(
+	ctx context.Context,
+	trigger domain.UpdateTrigger,
+	mode domain.UpdateMode,
+	username string,
+) (*domain.UpdateJob, error) {
+	const op = "updater.StartUpdate"
+
+	job, err := u.startJob(ctx, trigger, mode, username)
+	if err != nil {
+		if errors.Is(err, ErrUpdateInProgress) {
+			u.log.Warn("update already in progress")
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return job.snapshot(), nil
+}
+
+// Shutdown cancels the running update job and waits for it to finish, so that it doesn't outlive
+// the database. Updates can not be started afterwards.
+func (u *Updater) Shutdown(ctx context.Context) error {
+	const op = "updater.Shutdown"
+
+	u.stopJobs()
+
+	// the update lock is held by the running job until it has finished, and then by Shutdown for good
+	locked := make(chan struct{})
+	go func() {
+		u.mu.Lock()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	}
+}
+
+// UpdateJob returns the current state of a recent update job.
+func (u *Updater) UpdateJob(id int64) (*domain.UpdateJob, error) {
+	const op = "updater.UpdateJob"
+
+	job, err := u.findJob(id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return job.snapshot(), nil
+}
+
+// UpdateRuns returns up to limit recorded runs started before the one with the id, latest first,
+// and the id to continue from, zero for the last page.
+func (u *Updater) UpdateRuns(ctx context.Context, limit int, before int64) ([]*domain.UpdateRun, int64, error) {
+	const op = "updater.UpdateRuns"
+	log := u.log.With(slog.String("op", op))
+
+	runs, err := u.runRepo.Runs(ctx, limit+1, before)
+	if err != nil {
+		log.Error("failed to get update runs", logger.Err(err))
+		return nil, 0, fmt.Errorf("%s: %w", op, ErrInternal)
+	}
+
+	if len(runs) <= limit {
+		return runs, 0, nil
+	}
+	runs = runs[:limit]
+	return runs, runs[limit-1].Id, nil
+}
+
+// CancelUpdate stops a running update job and waits until it finishes. Comics saved before
+// the cancellation are kept. Cancelling a finished job does nothing.
+func (u *Updater) CancelUpdate(id int64) (*domain.UpdateJob, error) {
+	const op = "updater.CancelUpdate"
+
+	job, err := u.findJob(id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	job.cancel()
+	<-job.done
+	return job.snapshot(), nil
+}
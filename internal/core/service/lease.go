@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"yadro-go/pkg/logger"
+)
+
+const (
+	updateLeaseName    = "update"
+	schedulerLeaseName = "scheduler"
+)
+
+// lease is a lock shared by server instances on one database. The holder keeps it with heartbeats,
+// a lease which hasn't been renewed for ttl is taken over by another instance. Nil lease is always held.
+type lease struct {
+	log    *slog.Logger
+	repo   LeaseRepository
+	name   string
+	holder string
+	ttl    time.Duration
+}
+
+func (l *lease) acquire(ctx context.Context) (bool, error) {
+	if l == nil {
+		return true, nil
+	}
+	return l.repo.Acquire(ctx, l.name, l.holder, l.ttl)
+}
+
+func (l *lease) release(ctx context.Context) {
+	if l == nil {
+		return
+	}
+	if err := l.repo.Release(ctx, l.name, l.holder); err != nil {
+		l.log.Error("failed to release lease", slog.String("lease", l.name), logger.Err(err))
+	}
+}
+
+// heartbeat is how often the lease is renewed, a few times within ttl so that a failed renewal is retried.
+func (l *lease) heartbeat() time.Duration {
+	if l == nil {
+		return schedulerTick
+	}
+	return l.ttl / 3
+}
+
+// keep renews the lease until ctx is done and calls lost when it has been taken over
+// or couldn't be renewed before it expired.
+func (l *lease) keep(ctx context.Context, lost func()) {
+	if l == nil {
+		return
+	}
+
+	log := l.log.With(slog.String("lease", l.name))
+	ticker := time.NewTicker(l.heartbeat())
+	defer ticker.Stop()
+
+	renewed := time.Now()
+	for {
+		select {
+		case <-ticker.C:
+			ok, err := l.acquire(ctx)
+			if err != nil {
+				if time.Since(renewed) < l.ttl {
+					log.Warn("failed to renew lease", logger.Err(err))
+					continue
+				}
+				log.Error("lease expired before it could be renewed", logger.Err(err))
+				lost()
+				return
+			}
+			if !ok {
+				log.Error("lease taken over by another instance")
+				lost()
+				return
+			}
+			renewed = time.Now()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"testing"
+	"time"
+	"yadro-go/internal/core/domain"
+	mock_service "yadro-go/internal/core/service/mocks"
+	"yadro-go/test/logger"
+)
+
+func TestLease_Keep(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name string
+		// results of renewals after the lease is taken
+		renewals []bool
+		errs     []error
+		lost     bool
+	}{
+		{name: "TakenOver", renewals: []bool{true, false}, errs: []error{nil, nil}, lost: true},
+		{name: "RenewFailedOnce", renewals: []bool{false, true, true}, errs: []error{ErrInternal, nil, nil}},
+		// renewals fail until ttl passes
+		{name: "Expired", renewals: []bool{false, false, false}, lost: true,
+			errs: []error{ErrInternal, ErrInternal, ErrInternal}},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := gomock.NewController(t)
+			repo := mock_service.NewMockLeaseRepository(c)
+			calls := make([]*gomock.Call, len(testCase.renewals))
+			for i := range testCase.renewals {
+				calls[i] = repo.EXPECT().Acquire(gomock.Any(), "update", "a", 30*time.Millisecond).
+					Return(testCase.renewals[i], testCase.errs[i])
+			}
+			gomock.InOrder(calls...)
+			repo.EXPECT().Acquire(gomock.Any(), "update", "a", 30*time.Millisecond).Return(true, nil).AnyTimes()
+
+			l := &lease{log: slog.New(logger.EmptyHandler{}), repo: repo, name: "update", holder: "a", ttl: 30 * time.Millisecond}
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			lost := false
+			l.keep(ctx, func() { lost = true })
+			assert.Equal(t, testCase.lost, lost)
+		})
+	}
+}
+
+func TestLease_Nil(t *testing.T) {
+	t.Parallel()
+
+	var l *lease
+	ok, err := l.acquire(context.Background())
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, schedulerTick, l.heartbeat())
+	assert.NotPanics(t, func() {
+		l.release(context.Background())
+		l.keep(context.Background(), func() {})
+	})
+}
+
+func TestUpdater_StartUpdateLeaseHeld(t *testing.T) {
+	t.Parallel()
+
+	c := gomock.NewController(t)
+	leaseRepo := mock_service.NewMockLeaseRepository(c)
+	leaseRepo.EXPECT().Acquire(gomock.Any(), updateLeaseName, "b", time.Minute).Return(false, nil)
+
+	u := NewUpdater(slog.New(logger.EmptyHandler{}), nil, nil, nil, nil, nil, 1000, 1,
+		Lease(leaseRepo, "b", time.Minute))
+	_, err := u.StartUpdate(context.Background(), domain.UpdateTriggerManual, domain.UpdateModeNew, "admin")
+	assert.ErrorIs(t, err, ErrUpdateInProgress)
+
+	// the in-process lock is released
+	assert.True(t, u.mu.TryLock())
+}
+
+func TestUpdater_StartUpdateLease(t *testing.T) {
+	t.Parallel()
+
+	c := gomock.NewController(t)
+	leaseRepo := mock_service.NewMockLeaseRepository(c)
+	runRepo := mock_service.NewMockUpdateRunRepository(c)
+	comicProvider := mock_service.NewMockComicProvider(c)
+	comicRepo := mock_service.NewMockComicRepository(c)
+	keywordRepo := mock_service.NewMockKeywordRepository(c)
+
+	gomock.InOrder(
+		leaseRepo.EXPECT().Acquire(gomock.Any(), updateLeaseName, "a", time.Minute).Return(true, nil),
+		// runs of an instance which has lost the lease are interrupted
+		runRepo.EXPECT().Interrupt(gomock.Any(), updateLeaseName).Return(nil),
+		runRepo.EXPECT().Start(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run *domain.UpdateRun) (int64, error) {
+			assert.Equal(t, "a", run.Holder)
+			return 1, nil
+		}),
+		runRepo.EXPECT().Finish(gomock.Any(), gomock.Any()).Return(nil),
+		leaseRepo.EXPECT().Release(gomock.Any(), updateLeaseName, "a").Return(nil),
+	)
+	comicRepo.EXPECT().All(gomock.Any()).Return(nil, nil)
+	comicRepo.EXPECT().Missing(gomock.Any()).Return(nil, nil)
+	comicProvider.EXPECT().LastNum(gomock.Any()).Return(0, nil)
+	keywordRepo.EXPECT().Stats(gomock.Any()).Return(&domain.IndexStats{}, nil)
+
+	u := NewUpdater(slog.New(logger.EmptyHandler{}), nil, comicRepo, keywordRepo, runRepo, comicProvider, 1000, 1,
+		Lease(leaseRepo, "a", time.Minute))
+	_, err := u.Update(context.Background())
+	require.NoError(t, err)
+}
+
+func TestUpdater_StartUpdateLeaseLost(t *testing.T) {
+	t.Parallel()
+
+	c := gomock.NewController(t)
+	leaseRepo := mock_service.NewMockLeaseRepository(c)
+	runRepo := mock_service.NewMockUpdateRunRepository(c)
+	comicProvider := mock_service.NewMockComicProvider(c)
+	comicRepo := mock_service.NewMockComicRepository(c)
+
+	const ttl = 30 * time.Millisecond
+	gomock.InOrder(
+		leaseRepo.EXPECT().Acquire(gomock.Any(), updateLeaseName, "a", ttl).Return(true, nil),
+		leaseRepo.EXPECT().Acquire(gomock.Any(), updateLeaseName, "a", ttl).Return(false, nil),
+	)
+	leaseRepo.EXPECT().Release(gomock.Any(), updateLeaseName, "a").Return(nil)
+	runRepo.EXPECT().Interrupt(gomock.Any(), updateLeaseName).Return(nil)
+	runRepo.EXPECT().Start(gomock.Any(), gomock.Any()).Return(int64(1), nil)
+	runRepo.EXPECT().Finish(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run *domain.UpdateRun) error {
+		assert.Equal(t, domain.UpdateJobFailed, run.State)
+		assert.Equal(t, ErrLeaseLost.Error(), run.Error)
+		return nil
+	})
+	comicRepo.EXPECT().All(gomock.Any()).Return(nil, nil)
+	comicRepo.EXPECT().Missing(gomock.Any()).Return(nil, nil)
+	// the update is stuck until the job is cancelled
+	comicProvider.EXPECT().LastNum(gomock.Any()).DoAndReturn(func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+
+	u := NewUpdater(slog.New(logger.EmptyHandler{}), nil, comicRepo, nil, runRepo, comicProvider, 1000, 1,
+		Lease(leaseRepo, "a", ttl))
+	_, err := u.Update(context.Background())
+	assert.ErrorIs(t, err, ErrLeaseLost)
+}
+
+func TestUpdater_ReindexLease(t *testing.T) {
+	t.Parallel()
+
+	// another instance is updating, its writes are not mixed with the reindex
+	t.Run("LeaseHeld", func(t *testing.T) {
+		t.Parallel()
+
+		c := gomock.NewController(t)
+		leaseRepo := mock_service.NewMockLeaseRepository(c)
+		leaseRepo.EXPECT().Acquire(gomock.Any(), updateLeaseName, "b", time.Minute).Return(false, nil).Times(2)
+
+		u := NewUpdater(slog.New(logger.EmptyHandler{}), nil, nil, nil, nil, nil, 1000, 1,
+			Lease(leaseRepo, "b", time.Minute))
+		_, err := u.Reindex(context.Background())
+		assert.ErrorIs(t, err, ErrUpdateInProgress)
+
+		// the startup check is skipped
+		require.NoError(t, u.ReindexIfIncomplete(context.Background()))
+		assert.True(t, u.mu.TryLock())
+	})
+
+	t.Run("LeaseFree", func(t *testing.T) {
+		t.Parallel()
+
+		c := gomock.NewController(t)
+		leaseRepo := mock_service.NewMockLeaseRepository(c)
+		runRepo := mock_service.NewMockUpdateRunRepository(c)
+		reloader := mock_service.NewMockIndexReloader(c)
+		comicRepo := mock_service.NewMockComicRepository(c)
+		keywordRepo := mock_service.NewMockKeywordRepository(c)
+
+		gomock.InOrder(
+			leaseRepo.EXPECT().Acquire(gomock.Any(), updateLeaseName, "a", time.Minute).Return(true, nil),
+			runRepo.EXPECT().Interrupt(gomock.Any(), updateLeaseName).Return(nil),
+			// the index has to have writes of the previous holder before it is written
+			reloader.EXPECT().Reload(gomock.Any()).Return(nil),
+			comicRepo.EXPECT().All(gomock.Any()).Return(nil, nil),
+			keywordRepo.EXPECT().Reindex(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil),
+			leaseRepo.EXPECT().Release(gomock.Any(), updateLeaseName, "a").Return(nil),
+		)
+
+		u := NewUpdater(slog.New(logger.EmptyHandler{}), nil, comicRepo, keywordRepo, runRepo, nil, 1000, 1,
+			Lease(leaseRepo, "a", time.Minute), SharedIndex(reloader))
+		count, err := u.Reindex(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+}
+
+func TestUpdater_InterruptRuns(t *testing.T) {
+	t.Parallel()
+
+	t.Run("WithoutLease", func(t *testing.T) {
+		t.Parallel()
+
+		c := gomock.NewController(t)
+		runRepo := mock_service.NewMockUpdateRunRepository(c)
+		runRepo.EXPECT().Interrupt(gomock.Any(), updateLeaseName).Return(nil)
+
+		u := NewUpdater(slog.New(logger.EmptyHandler{}), nil, nil, nil, runRepo, nil, 1000, 1)
+		require.NoError(t, u.InterruptRuns(context.Background()))
+	})
+
+	t.Run("LeaseFree", func(t *testing.T) {
+		t.Parallel()
+
+		c := gomock.NewController(t)
+		leaseRepo := mock_service.NewMockLeaseRepository(c)
+		runRepo := mock_service.NewMockUpdateRunRepository(c)
+		gomock.InOrder(
+			leaseRepo.EXPECT().Acquire(gomock.Any(), updateLeaseName, "a", time.Minute).Return(true, nil),
+			runRepo.EXPECT().Interrupt(gomock.Any(), updateLeaseName).Return(nil),
+			leaseRepo.EXPECT().Release(gomock.Any(), updateLeaseName, "a").Return(nil),
+		)
+
+		u := NewUpdater(slog.New(logger.EmptyHandler{}), nil, nil, nil, runRepo, nil, 1000, 1,
+			Lease(leaseRepo, "a", time.Minute))
+		require.NoError(t, u.InterruptRuns(context.Background()))
+	})
+
+	// another instance is updating, its run isn't interrupted
+	t.Run("LeaseHeld", func(t *testing.T) {
+		t.Parallel()
+
+		c := gomock.NewController(t)
+		leaseRepo := mock_service.NewMockLeaseRepository(c)
+		runRepo := mock_service.NewMockUpdateRunRepository(c)
+		leaseRepo.EXPECT().Acquire(gomock.Any(), updateLeaseName, "a", time.Minute).Return(false, nil)
+
+		u := NewUpdater(slog.New(logger.EmptyHandler{}), nil, nil, nil, runRepo, nil, 1000, 1,
+			Lease(leaseRepo, "a", time.Minute))
+		require.NoError(t, u.InterruptRuns(context.Background()))
+	})
+}
+
+func TestUpdater_Lead(t *testing.T) {
+	t.Parallel()
+
+	c := gomock.NewController(t)
+	leaseRepo := mock_service.NewMockLeaseRepository(c)
+	runRepo := mock_service.NewMockUpdateRunRepository(c)
+
+	gomock.InOrder(
+		leaseRepo.EXPECT().Acquire(gomock.Any(), schedulerLeaseName, "a", time.Minute).Return(false, nil),
+		leaseRepo.EXPECT().Acquire(gomock.Any(), schedulerLeaseName, "a", time.Minute).Return(true, nil),
+		// the new leader checks if an update has been missed
+		runRepo.EXPECT().LastSuccess(gomock.Any()).Return(time.Now(), nil),
+		leaseRepo.EXPECT().Acquire(gomock.Any(), schedulerLeaseName, "a", time.Minute).Return(true, nil),
+		leaseRepo.EXPECT().Acquire(gomock.Any(), schedulerLeaseName, "a", time.Minute).Return(false, ErrInternal),
+	)
+
+	u := NewUpdater(slog.New(logger.EmptyHandler{}), nil, nil, nil, runRepo, nil, 1000, 1,
+		Lease(leaseRepo, "a", time.Minute))
+	ctx := context.Background()
+	assert.False(t, u.lead(ctx, false, time.Hour))
+	assert.True(t, u.lead(ctx, false, time.Hour))
+	assert.True(t, u.lead(ctx, true, time.Hour))
+	assert.False(t, u.lead(ctx, true, time.Hour))
+}
@@ -7,6 +7,7 @@ package mock_service
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 	domain "yadro-go/internal/core/domain"
 
 	gomock "github.com/golang/mock/gomock"
@@ -36,10 +37,10 @@ func (m *MockStemmer) EXPECT() *MockStemmerMockRecorder {
 }
 
 // StemComic mocks base method.
-func (m *MockStemmer) StemComic(comic *domain.Comic) []string {
+func (m *MockStemmer) StemComic(comic *domain.Comic) []domain.Token {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "StemComic", comic)
-	ret0, _ := ret[0].([]string)
+	ret0, _ := ret[0].([]domain.Token)
 	return ret0
 }
 
@@ -49,18 +50,18 @@ func (mr *MockStemmerMockRecorder) StemComic(comic interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StemComic", reflect.TypeOf((*MockStemmer)(nil).StemComic), comic)
 }
 
-// StemString mocks base method.
-func (m *MockStemmer) StemString(str string) []string {
+// Tokenize mocks base method.
+func (m *MockStemmer) Tokenize(str string) []domain.Token {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "StemString", str)
-	ret0, _ := ret[0].([]string)
+	ret := m.ctrl.Call(m, "Tokenize", str)
+	ret0, _ := ret[0].([]domain.Token)
 	return ret0
 }
 
-// StemString indicates an expected call of StemString.
-func (mr *MockStemmerMockRecorder) StemString(str interface{}) *gomock.Call {
+// Tokenize indicates an expected call of Tokenize.
+func (mr *MockStemmerMockRecorder) Tokenize(str interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StemString", reflect.TypeOf((*MockStemmer)(nil).StemString), str)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Tokenize", reflect.TypeOf((*MockStemmer)(nil).Tokenize), str)
 }
 
 // MockComicProvider is a mock of ComicProvider interface.
@@ -87,18 +88,33 @@ func (m *MockComicProvider) EXPECT() *MockComicProviderMockRecorder {
 }
 
 // GetById mocks base method.
-func (m *MockComicProvider) GetById(id int) (*domain.Comic, error) {
+func (m *MockComicProvider) GetById(ctx context.Context, id int) (*domain.Comic, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetById", id)
+	ret := m.ctrl.Call(m, "GetById", ctx, id)
 	ret0, _ := ret[0].(*domain.Comic)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetById indicates an expected call of GetById.
-func (mr *MockComicProviderMockRecorder) GetById(id interface{}) *gomock.Call {
+func (mr *MockComicProviderMockRecorder) GetById(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetById", reflect.TypeOf((*MockComicProvider)(nil).GetById), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetById", reflect.TypeOf((*MockComicProvider)(nil).GetById), ctx, id)
+}
+
+// LastNum mocks base method.
+func (m *MockComicProvider) LastNum(ctx context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LastNum", ctx)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LastNum indicates an expected call of LastNum.
+func (mr *MockComicProviderMockRecorder) LastNum(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LastNum", reflect.TypeOf((*MockComicProvider)(nil).LastNum), ctx)
 }
 
 // MockComicRepository is a mock of ComicRepository interface.
@@ -154,6 +170,21 @@ func (mr *MockComicRepositoryMockRecorder) Comics(ctx, nums interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Comics", reflect.TypeOf((*MockComicRepository)(nil).Comics), ctx, nums)
 }
 
+// Missing mocks base method.
+func (m *MockComicRepository) Missing(ctx context.Context) ([]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Missing", ctx)
+	ret0, _ := ret[0].([]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Missing indicates an expected call of Missing.
+func (mr *MockComicRepositoryMockRecorder) Missing(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Missing", reflect.TypeOf((*MockComicRepository)(nil).Missing), ctx)
+}
+
 // Save mocks base method.
 func (m *MockComicRepository) Save(ctx context.Context, comics []*domain.Comic) error {
 	m.ctrl.T.Helper()
@@ -168,6 +199,20 @@ func (mr *MockComicRepositoryMockRecorder) Save(ctx, comics interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockComicRepository)(nil).Save), ctx, comics)
 }
 
+// SaveMissing mocks base method.
+func (m *MockComicRepository) SaveMissing(ctx context.Context, nums []int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveMissing", ctx, nums)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveMissing indicates an expected call of SaveMissing.
+func (mr *MockComicRepositoryMockRecorder) SaveMissing(ctx, nums interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveMissing", reflect.TypeOf((*MockComicRepository)(nil).SaveMissing), ctx, nums)
+}
+
 // MockKeywordRepository is a mock of KeywordRepository interface.
 type MockKeywordRepository struct {
 	ctrl     *gomock.Controller
@@ -191,6 +236,21 @@ func (m *MockKeywordRepository) EXPECT() *MockKeywordRepositoryMockRecorder {
 	return m.recorder
 }
 
+// Generations mocks base method.
+func (m *MockKeywordRepository) Generations(ctx context.Context, nums []int) (map[int]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Generations", ctx, nums)
+	ret0, _ := ret[0].(map[int]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Generations indicates an expected call of Generations.
+func (mr *MockKeywordRepositoryMockRecorder) Generations(ctx, nums interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Generations", reflect.TypeOf((*MockKeywordRepository)(nil).Generations), ctx, nums)
+}
+
 // Keywords mocks base method.
 func (m *MockKeywordRepository) Keywords(ctx context.Context, keywords []string) ([]*domain.ComicKeyword, error) {
 	m.ctrl.T.Helper()
@@ -206,18 +266,300 @@ func (mr *MockKeywordRepositoryMockRecorder) Keywords(ctx, keywords interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Keywords", reflect.TypeOf((*MockKeywordRepository)(nil).Keywords), ctx, keywords)
 }
 
+// Lengths mocks base method.
+func (m *MockKeywordRepository) Lengths(ctx context.Context, nums []int) (map[int]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Lengths", ctx, nums)
+	ret0, _ := ret[0].(map[int]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Lengths indicates an expected call of Lengths.
+func (mr *MockKeywordRepositoryMockRecorder) Lengths(ctx, nums interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lengths", reflect.TypeOf((*MockKeywordRepository)(nil).Lengths), ctx, nums)
+}
+
+// Reindex mocks base method.
+func (m *MockKeywordRepository) Reindex(ctx context.Context, keywords []*domain.ComicKeyword, lengths map[int]int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reindex", ctx, keywords, lengths)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reindex indicates an expected call of Reindex.
+func (mr *MockKeywordRepositoryMockRecorder) Reindex(ctx, keywords, lengths interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reindex", reflect.TypeOf((*MockKeywordRepository)(nil).Reindex), ctx, keywords, lengths)
+}
+
 // Save mocks base method.
-func (m *MockKeywordRepository) Save(ctx context.Context, keywords []*domain.ComicKeyword) error {
+func (m *MockKeywordRepository) Save(ctx context.Context, keywords []*domain.ComicKeyword, lengths map[int]int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Save", ctx, keywords)
+	ret := m.ctrl.Call(m, "Save", ctx, keywords, lengths)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Save indicates an expected call of Save.
-func (mr *MockKeywordRepositoryMockRecorder) Save(ctx, keywords interface{}) *gomock.Call {
+func (mr *MockKeywordRepositoryMockRecorder) Save(ctx, keywords, lengths interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockKeywordRepository)(nil).Save), ctx, keywords, lengths)
+}
+
+// Stats mocks base method.
+func (m *MockKeywordRepository) Stats(ctx context.Context) (*domain.IndexStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats", ctx)
+	ret0, _ := ret[0].(*domain.IndexStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Stats indicates an expected call of Stats.
+func (mr *MockKeywordRepositoryMockRecorder) Stats(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockKeywordRepository)(nil).Stats), ctx)
+}
+
+// Vocabulary mocks base method.
+func (m *MockKeywordRepository) Vocabulary(ctx context.Context) ([]*domain.VocabularyWord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Vocabulary", ctx)
+	ret0, _ := ret[0].([]*domain.VocabularyWord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Vocabulary indicates an expected call of Vocabulary.
+func (mr *MockKeywordRepositoryMockRecorder) Vocabulary(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Vocabulary", reflect.TypeOf((*MockKeywordRepository)(nil).Vocabulary), ctx)
+}
+
+// MockIndexReloader is a mock of IndexReloader interface.
+type MockIndexReloader struct {
+	ctrl     *gomock.Controller
+	recorder *MockIndexReloaderMockRecorder
+}
+
+// MockIndexReloaderMockRecorder is the mock recorder for MockIndexReloader.
+type MockIndexReloaderMockRecorder struct {
+	mock *MockIndexReloader
+}
+
+// NewMockIndexReloader creates a new mock instance.
+func NewMockIndexReloader(ctrl *gomock.Controller) *MockIndexReloader {
+	mock := &MockIndexReloader{ctrl: ctrl}
+	mock.recorder = &MockIndexReloaderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIndexReloader) EXPECT() *MockIndexReloaderMockRecorder {
+	return m.recorder
+}
+
+// Reload mocks base method.
+func (m *MockIndexReloader) Reload(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reload", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reload indicates an expected call of Reload.
+func (mr *MockIndexReloaderMockRecorder) Reload(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reload", reflect.TypeOf((*MockIndexReloader)(nil).Reload), ctx)
+}
+
+// MockFullTextSearcher is a mock of FullTextSearcher interface.
+type MockFullTextSearcher struct {
+	ctrl     *gomock.Controller
+	recorder *MockFullTextSearcherMockRecorder
+}
+
+// MockFullTextSearcherMockRecorder is the mock recorder for MockFullTextSearcher.
+type MockFullTextSearcherMockRecorder struct {
+	mock *MockFullTextSearcher
+}
+
+// NewMockFullTextSearcher creates a new mock instance.
+func NewMockFullTextSearcher(ctrl *gomock.Controller) *MockFullTextSearcher {
+	mock := &MockFullTextSearcher{ctrl: ctrl}
+	mock.recorder = &MockFullTextSearcherMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockFullTextSearcher) EXPECT() *MockFullTextSearcherMockRecorder {
+	return m.recorder
+}
+
+// Search mocks base method.
+func (m *MockFullTextSearcher) Search(ctx context.Context, match string, limit int, after *domain.PageCursor) ([]*domain.FullTextMatch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Search", ctx, match, limit, after)
+	ret0, _ := ret[0].([]*domain.FullTextMatch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockFullTextSearcherMockRecorder) Search(ctx, match, limit, after interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockFullTextSearcher)(nil).Search), ctx, match, limit, after)
+}
+
+// MockUpdateRunRepository is a mock of UpdateRunRepository interface.
+type MockUpdateRunRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockUpdateRunRepositoryMockRecorder
+}
+
+// MockUpdateRunRepositoryMockRecorder is the mock recorder for MockUpdateRunRepository.
+type MockUpdateRunRepositoryMockRecorder struct {
+	mock *MockUpdateRunRepository
+}
+
+// NewMockUpdateRunRepository creates a new mock instance.
+func NewMockUpdateRunRepository(ctrl *gomock.Controller) *MockUpdateRunRepository {
+	mock := &MockUpdateRunRepository{ctrl: ctrl}
+	mock.recorder = &MockUpdateRunRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUpdateRunRepository) EXPECT() *MockUpdateRunRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Finish mocks base method.
+func (m *MockUpdateRunRepository) Finish(ctx context.Context, run *domain.UpdateRun) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Finish", ctx, run)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Finish indicates an expected call of Finish.
+func (mr *MockUpdateRunRepositoryMockRecorder) Finish(ctx, run interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Finish", reflect.TypeOf((*MockUpdateRunRepository)(nil).Finish), ctx, run)
+}
+
+// Interrupt mocks base method.
+func (m *MockUpdateRunRepository) Interrupt(ctx context.Context, lease string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Interrupt", ctx, lease)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Interrupt indicates an expected call of Interrupt.
+func (mr *MockUpdateRunRepositoryMockRecorder) Interrupt(ctx, lease interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Interrupt", reflect.TypeOf((*MockUpdateRunRepository)(nil).Interrupt), ctx, lease)
+}
+
+// LastSuccess mocks base method.
+func (m *MockUpdateRunRepository) LastSuccess(ctx context.Context) (time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LastSuccess", ctx)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LastSuccess indicates an expected call of LastSuccess.
+func (mr *MockUpdateRunRepositoryMockRecorder) LastSuccess(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LastSuccess", reflect.TypeOf((*MockUpdateRunRepository)(nil).LastSuccess), ctx)
+}
+
+// Runs mocks base method.
+func (m *MockUpdateRunRepository) Runs(ctx context.Context, limit int, before int64) ([]*domain.UpdateRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Runs", ctx, limit, before)
+	ret0, _ := ret[0].([]*domain.UpdateRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Runs indicates an expected call of Runs.
+func (mr *MockUpdateRunRepositoryMockRecorder) Runs(ctx, limit, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Runs", reflect.TypeOf((*MockUpdateRunRepository)(nil).Runs), ctx, limit, before)
+}
+
+// Start mocks base method.
+func (m *MockUpdateRunRepository) Start(ctx context.Context, run *domain.UpdateRun) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Start", ctx, run)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Start indicates an expected call of Start.
+func (mr *MockUpdateRunRepositoryMockRecorder) Start(ctx, run interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Start", reflect.TypeOf((*MockUpdateRunRepository)(nil).Start), ctx, run)
+}
+
+// MockLeaseRepository is a mock of LeaseRepository interface.
+type MockLeaseRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockLeaseRepositoryMockRecorder
+}
+
+// MockLeaseRepositoryMockRecorder is the mock recorder for MockLeaseRepository.
+type MockLeaseRepositoryMockRecorder struct {
+	mock *MockLeaseRepository
+}
+
+// NewMockLeaseRepository creates a new mock instance.
+func NewMockLeaseRepository(ctrl *gomock.Controller) *MockLeaseRepository {
+	mock := &MockLeaseRepository{ctrl: ctrl}
+	mock.recorder = &MockLeaseRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLeaseRepository) EXPECT() *MockLeaseRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Acquire mocks base method.
+func (m *MockLeaseRepository) Acquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Acquire", ctx, name, holder, ttl)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Acquire indicates an expected call of Acquire.
+func (mr *MockLeaseRepositoryMockRecorder) Acquire(ctx, name, holder, ttl interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Acquire", reflect.TypeOf((*MockLeaseRepository)(nil).Acquire), ctx, name, holder, ttl)
+}
+
+// Release mocks base method.
+func (m *MockLeaseRepository) Release(ctx context.Context, name, holder string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Release", ctx, name, holder)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Release indicates an expected call of Release.
+func (mr *MockLeaseRepositoryMockRecorder) Release(ctx, name, holder interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockKeywordRepository)(nil).Save), ctx, keywords)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Release", reflect.TypeOf((*MockLeaseRepository)(nil).Release), ctx, name, holder)
 }
 
 // MockUserRepository is a mock of UserRepository interface.
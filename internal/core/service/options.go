@@ -0,0 +1,40 @@
+package service
+
+import "time"
+
+type UpdaterOption func(*Updater)
+
+// BatchSize sets how many fetched comics are saved and indexed at once. Saved batches are kept
+// when an update is interrupted, so the next one fetches only the rest.
+func BatchSize(size int) UpdaterOption {
+	return func(u *Updater) {
+		u.batchSize = size
+	}
+}
+
+// RefreshWindow sets how many latest stored comics every update fetches again to find the edited ones,
+// zero disables it. Refresh updates fetch all of them.
+func RefreshWindow(size int) UpdaterOption {
+	return func(u *Updater) {
+		u.refreshWindow = size
+	}
+}
+
+// SharedIndex makes the instance reload its in-memory index after other instances sharing the database
+// have written to it: when the update lease changes hands to the instance, before it writes, and on every
+// scheduler tick, so that runs completed elsewhere are searched here too.
+func SharedIndex(index IndexReloader) UpdaterOption {
+	return func(u *Updater) {
+		u.index = index
+	}
+}
+
+// Lease makes server instances sharing the database run one update at a time and only one of them
+// run scheduled updates. The holder renews the lease with heartbeats, a lease which hasn't been renewed
+// for ttl is taken over. The holder has to be unique among the instances.
+func Lease(repo LeaseRepository, holder string, ttl time.Duration) UpdaterOption {
+	return func(u *Updater) {
+		u.updateLease = &lease{log: u.log, repo: repo, name: updateLeaseName, holder: holder, ttl: ttl}
+		u.schedulerLease = &lease{log: u.log, repo: repo, name: schedulerLeaseName, holder: holder, ttl: ttl}
+	}
+}
@@ -0,0 +1,136 @@
+package query
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+	"yadro-go/internal/core/domain"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenPhrase
+	tokenLParen
+	tokenRParen
+	tokenPlus
+	tokenMinus
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenField
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+	// offset is a byte offset of the text in the query
+	offset int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of query"
+	case tokenPhrase:
+		return `"` + t.text + `"`
+	case tokenField:
+		return "'" + t.text + ":'"
+	default:
+		return "'" + t.text + "'"
+	}
+}
+
+func lex(str string) ([]token, error) {
+	tokens := make([]token, 0)
+
+	for i := 0; i < len(str); {
+		r, size := utf8.DecodeRuneInString(str[i:])
+		pos := utf8.RuneCountInString(str[:i]) + 1
+
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: pos})
+			i += size
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: pos})
+			i += size
+		case r == '+' || r == '-':
+			kind := tokenPlus
+			if r == '-' {
+				kind = tokenMinus
+			}
+			tokens = append(tokens, token{kind: kind, text: string(r), pos: pos})
+			i += size
+		case r == '"':
+			end := indexRune(str, i+size, '"')
+			if end < 0 {
+				return nil, newError(pos, "unterminated phrase")
+			}
+			tokens = append(tokens, token{kind: tokenPhrase, text: str[i+size : end], pos: pos, offset: i + size})
+			i = end + 1
+		default:
+			start := i
+			for i < len(str) {
+				r, size = utf8.DecodeRuneInString(str[i:])
+				if unicode.IsSpace(r) || r == '(' || r == ')' || r == '"' {
+					break
+				}
+				i += size
+			}
+			text := str[start:i]
+			if field, rest, ok := splitField(text); ok {
+				tokens = append(tokens, token{kind: tokenField, text: field, pos: pos})
+				if len(rest) == 0 {
+					continue
+				}
+				text, pos = rest, pos+utf8.RuneCountInString(field)+1
+				start += len(field) + 1
+			}
+			word := wordToken(text, pos)
+			word.offset = start
+			tokens = append(tokens, word)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: utf8.RuneCountInString(str) + 1}), nil
+}
+
+func wordToken(text string, pos int) token {
+	switch text {
+	case "AND", "&&":
+		return token{kind: tokenAnd, text: text, pos: pos}
+	case "OR", "||":
+		return token{kind: tokenOr, text: text, pos: pos}
+	case "NOT":
+		return token{kind: tokenNot, text: text, pos: pos}
+	default:
+		return token{kind: tokenWord, text: text, pos: pos}
+	}
+}
+
+// splitField detects "field:" prefix of a word.
+func splitField(text string) (string, string, bool) {
+	name, rest, found := strings.Cut(text, ":")
+	if !found {
+		return "", "", false
+	}
+	if _, ok := domain.ParseField(name); !ok {
+		return "", "", false
+	}
+	return name, rest, true
+}
+
+func indexRune(str string, from int, r rune) int {
+	for i, v := range str[from:] {
+		if v == r {
+			return from + i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,279 @@
+package query
+
+import (
+	"fmt"
+	"yadro-go/internal/core/domain"
+)
+
+// Node is an element of a parsed search query.
+type Node interface {
+	isNode()
+}
+
+// Term is a single word of the query, it may stem to several keywords.
+// Empty Field means the term is not scoped to a field.
+type Term struct {
+	Field domain.Field
+	Text  string
+}
+
+// Phrase is a quoted sequence of words that must appear next to each other.
+type Phrase struct {
+	Field domain.Field
+	Text  string
+}
+
+// Bool combines clauses: every Must clause has to match, none of MustNot may match,
+// Should clauses are alternatives when there are no Must clauses and add relevance otherwise.
+type Bool struct {
+	Must    []Node
+	Should  []Node
+	MustNot []Node
+}
+
+func (*Term) isNode()   {}
+func (*Phrase) isNode() {}
+func (*Bool) isNode()   {}
+
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Msg)
+}
+
+func newError(pos int, msg string) *Error {
+	return &Error{Pos: pos, Msg: msg}
+}
+
+type occur int
+
+const (
+	occurShould occur = iota
+	occurMust
+	occurMustNot
+)
+
+type clause struct {
+	node  Node
+	occur occur
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// Parse builds a query tree from the search string.
+// Words are joined by an implicit OR, AND/OR/NOT operators, +/- prefixes,
+// parentheses and quoted phrases are supported. Empty query results in nil Node.
+func Parse(str string) (Node, error) {
+	tokens, err := lex(str)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, nil
+	}
+
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, newError(t.pos, fmt.Sprintf("unexpected %s", t))
+	}
+
+	return node, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (Node, error) {
+	start := p.peek()
+	clauses := make([]clause, 0)
+
+	for {
+		c, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, c)
+
+		t := p.peek()
+		if t.kind == tokenOr {
+			p.next()
+			if k := p.peek().kind; k == tokenEOF || k == tokenRParen {
+				return nil, newError(t.pos, "OR is missing right operand")
+			}
+			continue
+		}
+		if t.kind == tokenEOF || t.kind == tokenRParen {
+			break
+		}
+	}
+
+	if len(clauses) == 1 && clauses[0].occur == occurShould {
+		return clauses[0].node, nil
+	}
+
+	return buildBool(start.pos, clauses)
+}
+
+func (p *parser) parseAnd() (clause, error) {
+	start := p.peek()
+	first, err := p.parseUnary()
+	if err != nil {
+		return clause{}, err
+	}
+
+	if p.peek().kind != tokenAnd {
+		return first, nil
+	}
+
+	clauses := []clause{first}
+	for p.peek().kind == tokenAnd {
+		t := p.next()
+		if k := p.peek().kind; k == tokenEOF || k == tokenRParen {
+			return clause{}, newError(t.pos, "AND is missing right operand")
+		}
+
+		c, err := p.parseUnary()
+		if err != nil {
+			return clause{}, err
+		}
+		clauses = append(clauses, c)
+	}
+
+	for i := range clauses {
+		if clauses[i].occur == occurShould {
+			clauses[i].occur = occurMust
+		}
+	}
+
+	node, err := buildBool(start.pos, clauses)
+	if err != nil {
+		return clause{}, err
+	}
+
+	return clause{node: node, occur: occurShould}, nil
+}
+
+func (p *parser) parseUnary() (clause, error) {
+	t := p.peek()
+
+	o := occurShould
+	switch t.kind {
+	case tokenNot, tokenMinus:
+		o = occurMustNot
+		p.next()
+	case tokenPlus:
+		o = occurMust
+		p.next()
+	default:
+	}
+
+	node, err := p.parsePrimary()
+	if err != nil {
+		return clause{}, err
+	}
+
+	return clause{node: node, occur: o}, nil
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokenField:
+		if k := p.peek().kind; k != tokenWord && k != tokenPhrase && k != tokenLParen {
+			return nil, newError(t.pos, fmt.Sprintf("field %s is missing a term", t))
+		}
+
+		node, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+
+		setField(node, domain.Field(t.text))
+		return node, nil
+	case tokenWord:
+		return &Term{Text: t.text}, nil
+	case tokenPhrase:
+		if len(t.text) == 0 {
+			return nil, newError(t.pos, "empty phrase")
+		}
+		return &Phrase{Text: t.text}, nil
+	case tokenLParen:
+		if p.peek().kind == tokenRParen {
+			return nil, newError(t.pos, "empty group")
+		}
+
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, newError(t.pos, "unbalanced parenthesis")
+		}
+		return node, nil
+	default:
+		return nil, newError(t.pos, fmt.Sprintf("unexpected %s", t))
+	}
+}
+
+func buildBool(pos int, clauses []clause) (Node, error) {
+	b := &Bool{}
+	for _, c := range clauses {
+		switch c.occur {
+		case occurMust:
+			b.Must = append(b.Must, c.node)
+		case occurMustNot:
+			b.MustNot = append(b.MustNot, c.node)
+		default:
+			b.Should = append(b.Should, c.node)
+		}
+	}
+
+	if len(b.Must) == 0 && len(b.Should) == 0 {
+		return nil, newError(pos, "query can not consist of negated terms only")
+	}
+
+	return b, nil
+}
+
+// setField scopes the node terms that have no field yet.
+func setField(node Node, field domain.Field) {
+	switch n := node.(type) {
+	case *Term:
+		if len(n.Field) == 0 {
+			n.Field = field
+		}
+	case *Phrase:
+		if len(n.Field) == 0 {
+			n.Field = field
+		}
+	case *Bool:
+		for _, nodes := range [][]Node{n.Must, n.Should, n.MustNot} {
+			for _, child := range nodes {
+				setField(child, field)
+			}
+		}
+	}
+}
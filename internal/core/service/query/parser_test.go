@@ -0,0 +1,113 @@
+package query
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"yadro-go/internal/core/domain"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		input    string
+		expected Node
+	}{
+		{"", nil},
+		{"   ", nil},
+		{"python", &Term{Text: "python"}},
+		{"python code", &Bool{Should: []Node{&Term{Text: "python"}, &Term{Text: "code"}}}},
+		{"python OR code", &Bool{Should: []Node{&Term{Text: "python"}, &Term{Text: "code"}}}},
+		{"python AND code", &Bool{Must: []Node{&Term{Text: "python"}, &Term{Text: "code"}}}},
+		{"+python -java code", &Bool{
+			Must:    []Node{&Term{Text: "python"}},
+			Should:  []Node{&Term{Text: "code"}},
+			MustNot: []Node{&Term{Text: "java"}},
+		}},
+		{"python AND NOT java", &Bool{
+			Must:    []Node{&Term{Text: "python"}},
+			MustNot: []Node{&Term{Text: "java"}},
+		}},
+		{`"bobby tables"`, &Phrase{Text: "bobby tables"}},
+		{`(sql OR "bobby tables") AND school`, &Bool{Must: []Node{
+			&Bool{Should: []Node{&Term{Text: "sql"}, &Phrase{Text: "bobby tables"}}},
+			&Term{Text: "school"},
+		}}},
+		{"a AND b OR c", &Bool{Should: []Node{
+			&Bool{Must: []Node{&Term{Text: "a"}, &Term{Text: "b"}}},
+			&Term{Text: "c"},
+		}}},
+		{"e-mail", &Term{Text: "e-mail"}},
+		{"http://xkcd.com", &Term{Text: "http://xkcd.com"}},
+		{`title:compiling alt:"sword fight"`, &Bool{Should: []Node{
+			&Term{Field: domain.FieldTitle, Text: "compiling"},
+			&Phrase{Field: domain.FieldAlt, Text: "sword fight"},
+		}}},
+		{"title: python", &Term{Field: domain.FieldTitle, Text: "python"}},
+		{"title:(java alt:python) +code", &Bool{
+			Must: []Node{&Term{Text: "code"}},
+			Should: []Node{&Bool{Should: []Node{
+				&Term{Field: domain.FieldTitle, Text: "java"},
+				&Term{Field: domain.FieldAlt, Text: "python"},
+			}}},
+		}},
+	}
+
+	for _, testCase := range testTable {
+		node, err := Parse(testCase.input)
+		require.NoError(t, err, testCase.input)
+		assert.Equal(t, testCase.expected, node, testCase.input)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		input string
+		pos   int
+	}{
+		{`"bobby tables`, 1},
+		{`python ""`, 8},
+		{"(python", 1},
+		{"python)", 7},
+		{"()", 1},
+		{"AND python", 1},
+		{"python AND", 8},
+		{"python OR", 8},
+		{"NOT python", 1},
+		{"-python -java", 1},
+		{"python (NOT java)", 9},
+		{"+", 2},
+		{"title:", 1},
+		{"title: AND python", 1},
+	}
+
+	for _, testCase := range testTable {
+		_, err := Parse(testCase.input)
+		var queryErr *Error
+		require.ErrorAs(t, err, &queryErr, testCase.input)
+		assert.Equal(t, testCase.pos, queryErr.Pos, testCase.input)
+	}
+}
+
+func TestWords(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		input    string
+		expected []Span
+	}{
+		{"", []Span{}},
+		{"pyton", []Span{{"pyton", 0, 5}}},
+		{"pyton AND -jva", []Span{{"pyton", 0, 5}, {"jva", 11, 14}}},
+		{`title:pyton alt:"bobby  tabels"`, []Span{{"pyton", 6, 11}, {"bobby", 17, 22}, {"tabels", 24, 30}}},
+		{"(сбой)", []Span{{"сбой", 1, 9}}},
+		{`"unterminated`, nil},
+	}
+
+	for _, testCase := range testTable {
+		assert.Equal(t, testCase.expected, Words(testCase.input), testCase.input)
+	}
+}
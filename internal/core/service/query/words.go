@@ -0,0 +1,55 @@
+package query
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Span is a search word with its byte offsets in the query.
+type Span struct {
+	Text  string
+	Start int
+	End   int
+}
+
+// Words returns words of the query terms and phrases, operators and field names are skipped.
+// Nil is returned for a malformed query.
+func Words(str string) []Span {
+	tokens, err := lex(str)
+	if err != nil {
+		return nil
+	}
+
+	spans := make([]Span, 0, len(tokens))
+	for _, t := range tokens {
+		switch t.kind {
+		case tokenWord:
+			spans = append(spans, Span{Text: t.text, Start: t.offset, End: t.offset + len(t.text)})
+		case tokenPhrase:
+			spans = append(spans, phraseWords(t)...)
+		}
+	}
+
+	return spans
+}
+
+func phraseWords(t token) []Span {
+	spans := make([]Span, 0)
+	rest := t.text
+	offset := t.offset
+	for len(rest) > 0 {
+		start := strings.IndexFunc(rest, func(r rune) bool { return !unicode.IsSpace(r) })
+		if start < 0 {
+			break
+		}
+		end := strings.IndexFunc(rest[start:], unicode.IsSpace)
+		if end < 0 {
+			end = len(rest) - start
+		}
+
+		spans = append(spans, Span{Text: rest[start : start+end], Start: offset + start, End: offset + start + end})
+		rest = rest[start+end:]
+		offset += start + end
+	}
+	return spans
+}
@@ -6,7 +6,9 @@ import (
 	"golang.org/x/exp/maps"
 	"log/slog"
 	"slices"
+	"sync"
 	"yadro-go/internal/core/domain"
+	"yadro-go/internal/core/service/query"
 	"yadro-go/pkg/logger"
 )
 
@@ -15,33 +17,115 @@ type Scanner struct {
 	stemmer     Stemmer
 	comicRepo   ComicRepository
 	keywordRepo KeywordRepository
+	highlighter *Highlighter
+	boosts      map[domain.Field]float64
+	fuzzyDist   int
+
+	vocabMu *sync.Mutex
+	vocab   *vocabulary
 }
 
 type NumMatch struct {
 	num   int
-	match int
+	score float64
+	terms []string
 }
 
-func NewScanner(log *slog.Logger, stemmer Stemmer, comicRepo ComicRepository, keywordRepo KeywordRepository) *Scanner {
+func NewScanner(
+	log *slog.Logger,
+	stemmer Stemmer,
+	comicRepo ComicRepository,
+	keywordRepo KeywordRepository,
+	highlighter *Highlighter,
+	boosts map[domain.Field]float64,
+	fuzzyDistance int,
+) *Scanner {
 	return &Scanner{
 		log:         log,
 		stemmer:     stemmer,
 		comicRepo:   comicRepo,
 		keywordRepo: keywordRepo,
+		highlighter: highlighter,
+		boosts:      boosts,
+		fuzzyDist:   fuzzyDistance,
+		vocabMu:     &sync.Mutex{},
 	}
 }
 
-func (s *Scanner) Scan(ctx context.Context, query string, useIndex bool) ([]string, error) {
-	words := s.stemmer.StemString(query)
+func (s *Scanner) Scan(ctx context.Context, search string, opts domain.ScanOptions) (*domain.ScanResult, error) {
+	const op = "scanner.Scan"
+	log := s.log.With(slog.String("op", op))
+
+	node, err := query.Parse(search)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %w", op, ErrBadQuery, err)
+	}
+
+	result := &domain.ScanResult{Comics: make([]*domain.ScoredComic, 0)}
+
+	compiled := compileQuery(s.stemmer, node, opts.Fields)
+	if compiled == nil {
+		return result, nil
+	}
+
+	if opts.Fuzzy {
+		vocab, err := s.vocabulary(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		expandFuzzy(compiled, vocab, s.fuzzyDist)
+	}
+
+	words := maps.Keys(queryStems(compiled, make(map[string]struct{})))
+
+	var page []*NumMatch
+	var next *domain.PageCursor
+	var comics []*domain.Comic
+	if opts.UseIndex {
+		page, next, err = s.scanKeywords(ctx, compiled, words, opts)
+	} else {
+		page, next, comics, err = s.scanComics(ctx, compiled, words, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if comics == nil && len(page) > 0 {
+		nums := make([]int, len(page))
+		for i, match := range page {
+			nums[i] = match.num
+		}
+		if comics, err = s.comicRepo.Comics(ctx, nums); err != nil {
+			log.Error("failed to get comics", logger.Err(err))
+			return nil, err
+		}
+	}
+
+	result.Comics = finalizeResult(comics, page)
+	result.Next = next
+
+	if opts.Snippets && s.highlighter != nil {
+		for _, scored := range result.Comics {
+			scored.Snippet = s.highlighter.Snippet(scored.Comic, scored.Terms)
+		}
+	}
 
-	if useIndex {
-		return s.scanKeywords(ctx, words)
+	if len(result.Comics) == 0 && opts.Suggest && opts.Cursor == nil {
+		if result.Suggestions, err = s.suggest(ctx, search); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
 	}
 
-	return s.scanComics(ctx, words)
+	return result, nil
 }
 
-func (s *Scanner) scanComics(ctx context.Context, words []string) ([]string, error) {
+// scanComics matches every stored comic, comics are returned to avoid loading them again.
+func (s *Scanner) scanComics(
+	ctx context.Context,
+	compiled queryNode,
+	words []string,
+	opts domain.ScanOptions,
+) ([]*NumMatch, *domain.PageCursor, []*domain.Comic, error) {
 	const op = "scanner.scanComics"
 	log := s.log.With(slog.String("op", op))
 
@@ -50,96 +134,191 @@ func (s *Scanner) scanComics(ctx context.Context, words []string) ([]string, err
 	comics, err := s.comicRepo.All(ctx)
 	if err != nil {
 		log.Error("failed to get comics", logger.Err(err))
-		return nil, err
+		return nil, nil, nil, err
 	}
 
-	wordsSet := make(map[string]bool, len(words))
+	// generations of pageable results tell comics indexed after the first page, which are skipped
+	var generations map[int]int64
+	if opts.Limit > 0 || opts.Cursor != nil {
+		nums := make([]int, len(comics))
+		for i, comic := range comics {
+			nums[i] = comic.Num
+		}
+		if generations, err = s.keywordRepo.Generations(ctx, nums); err != nil {
+			log.Error("failed to get comic generations", logger.Err(err))
+			return nil, nil, nil, err
+		}
+	}
+	if opts.Cursor != nil {
+		comics = slices.DeleteFunc(comics, func(comic *domain.Comic) bool {
+			return !indexedBy(generations, comic.Num, opts.Cursor.Generation)
+		})
+	}
+
+	keywordsMap := make(map[string]*domain.ComicKeyword, len(words))
 	for _, word := range words {
-		wordsSet[word] = true
+		keywordsMap[word] = &domain.ComicKeyword{Word: word}
 	}
 
-	matches := make([]*NumMatch, 0)
-	for num, comic := range comics {
-		matchCount := 0
+	stats := &domain.IndexStats{Docs: len(comics)}
+	lengths := make(map[int]int, len(comics))
+	totalLength := 0
+	for _, comic := range comics {
 		select {
 		case <-ctx.Done():
 			log.Warn("scanning stopped, finishing")
-			return nil, ctx.Err()
+			return nil, nil, nil, ctx.Err()
 
 		default:
-			for _, keyword := range s.stemmer.StemComic(comic) {
-				if wordsSet[keyword] {
-					matchCount++
+			tokens := s.stemmer.StemComic(comic)
+			lengths[comic.Num] = len(tokens)
+			totalLength += len(tokens)
+			stats.MaxNum = max(stats.MaxNum, comic.Num)
+			stats.Generation = max(stats.Generation, generations[comic.Num])
+
+			for _, posting := range postingsFromTokens(comic.Num, tokens) {
+				if keyword, ok := keywordsMap[posting.word]; ok {
+					keyword.Postings = append(keyword.Postings, posting.Posting)
 				}
 			}
-			if matchCount > 0 {
-				matches = append(matches, &NumMatch{num: num, match: matchCount})
-			}
 		}
 	}
 
-	log.Debug(fmt.Sprintf("scan finished: found %d matches", len(matches)))
-	return finalizeResult(comics, matches), nil
+	if len(comics) > 0 {
+		stats.AvgLength = float64(totalLength) / float64(len(comics))
+	}
+	if opts.Cursor != nil {
+		stats = cursorStats(opts.Cursor)
+	}
+
+	e := newEvaluator(maps.Values(keywordsMap), s.boosts, nil)
+	nums := e.eval(compiled, true)
+
+	page, next, err := rank(e, nums, stats, opts, func([]int) (map[int]int, error) {
+		return lengths, nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	log.Debug(fmt.Sprintf("scan finished: found %d matches", len(nums)))
+	return page, next, comics, nil
 }
 
-func (s *Scanner) scanKeywords(ctx context.Context, words []string) ([]string, error) {
+func (s *Scanner) scanKeywords(
+	ctx context.Context,
+	compiled queryNode,
+	words []string,
+	opts domain.ScanOptions,
+) ([]*NumMatch, *domain.PageCursor, error) {
 	const op = "scanner.scanKeywords"
 	log := s.log.With(slog.String("op", op))
 
 	log.Debug("scanning index")
 
+	stats := cursorStats(opts.Cursor)
+	if stats == nil {
+		var err error
+		if stats, err = s.keywordRepo.Stats(ctx); err != nil {
+			log.Error("failed to get index stats", logger.Err(err))
+			return nil, nil, err
+		}
+	}
+
 	keywords, err := s.keywordRepo.Keywords(ctx, words)
 	if err != nil {
 		log.Error("failed to get keywords", logger.Err(err))
-		return nil, err
+		return nil, nil, err
 	}
 
-	matches := make(map[int]*NumMatch)
-	for _, keyword := range keywords {
-		if len(keyword.Nums) == 0 {
-			continue
+	// comics indexed after the first page are skipped, so that scores are consistent with its stats
+	var visible func(num int) bool
+	if opts.Cursor != nil {
+		generations, err := s.keywordRepo.Generations(ctx, postingNums(keywords))
+		if err != nil {
+			log.Error("failed to get comic generations", logger.Err(err))
+			return nil, nil, err
+		}
+		visible = func(num int) bool {
+			return indexedBy(generations, num, opts.Cursor.Generation)
 		}
+	}
 
-		select {
-		case <-ctx.Done():
+	e := newEvaluator(keywords, s.boosts, visible)
+	nums := e.eval(compiled, true)
+
+	if len(nums) == 0 {
+		log.Debug("scan finished: no matches")
+		return make([]*NumMatch, 0), nil, nil
+	}
+
+	scored := 0
+	page, next, err := rank(e, nums, stats, opts, func(batch []int) (map[int]int, error) {
+		if err := ctx.Err(); err != nil {
 			log.Warn("scanning stopped, finishing")
-			return nil, ctx.Err()
+			return nil, err
+		}
 
-		default:
-			for _, num := range keyword.Nums {
-				numMatch, ok := matches[num]
-				if !ok {
-					matches[num] = &NumMatch{num: num, match: 1}
-				} else {
-					numMatch.match++
-				}
-			}
+		lengths, err := s.keywordRepo.Lengths(ctx, batch)
+		if err != nil {
+			log.Error("failed to get comic lengths", logger.Err(err))
+			return nil, err
 		}
-	}
 
-	comics, err := s.comicRepo.Comics(ctx, maps.Keys(matches))
+		scored += len(batch)
+		return lengths, nil
+	})
 	if err != nil {
-		log.Error("failed to get comics")
-		return nil, err
+		return nil, nil, err
 	}
 
-	log.Debug(fmt.Sprintf("scan finished: found %d matches", len(matches)))
-	return finalizeResult(comics, maps.Values(matches)), nil
+	log.Debug(fmt.Sprintf("scan finished: found %d matches, scored %d", len(nums), scored))
+	return page, next, nil
 }
 
-func finalizeResult(comics []*domain.Comic, matches []*NumMatch) []string {
-	slices.SortFunc(matches, func(a, b *NumMatch) int {
-		return b.match - a.match
-	})
+// cursorStats returns index stats the cursor page was ranked with, nil for no cursor.
+func cursorStats(cursor *domain.PageCursor) *domain.IndexStats {
+	if cursor == nil {
+		return nil
+	}
+	return &domain.IndexStats{Docs: cursor.Docs, AvgLength: cursor.AvgLength, Generation: cursor.Generation}
+}
 
+// indexedBy tells if the comic was indexed at the generation or before it.
+func indexedBy(generations map[int]int64, num int, generation int64) bool {
+	indexed, ok := generations[num]
+	return ok && indexed <= generation
+}
+
+// postingNums returns distinct numbers of comics the keywords are found in.
+func postingNums(keywords []*domain.ComicKeyword) []int {
+	seen := make(map[int]struct{})
+	nums := make([]int, 0)
+	for _, keyword := range keywords {
+		for _, posting := range keyword.Postings {
+			if _, ok := seen[posting.Num]; !ok {
+				seen[posting.Num] = struct{}{}
+				nums = append(nums, posting.Num)
+			}
+		}
+	}
+	return nums
+}
+
+// finalizeResult joins ranked matches with their comics keeping the order.
+func finalizeResult(comics []*domain.Comic, matches []*NumMatch) []*domain.ScoredComic {
 	comicMap := make(map[int]*domain.Comic, len(comics))
 	for _, comic := range comics {
 		comicMap[comic.Num] = comic
 	}
 
-	result := make([]string, len(matches))
-	for i, match := range matches {
-		result[i] = comicMap[match.num].Img
+	result := make([]*domain.ScoredComic, 0, len(matches))
+	for _, match := range matches {
+		comic, ok := comicMap[match.num]
+		if !ok {
+			continue
+		}
+		result = append(result, &domain.ScoredComic{Comic: comic, Score: match.score, Terms: match.terms})
 	}
 
 	return result
@@ -0,0 +1,518 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"yadro-go/internal/core/domain"
+	mock_service "yadro-go/internal/core/service/mocks"
+	"yadro-go/test/logger"
+)
+
+func TestScanner_ScanIndex(t *testing.T) {
+	t.Parallel()
+
+	var comicShort = &domain.Comic{Num: 1, Title: "Python", Img: "img1"}
+	var comicLong = &domain.Comic{Num: 2, Title: "Long", Img: "img2"}
+	var comicOther = &domain.Comic{Num: 3, Title: "Other", Img: "img3"}
+
+	c := gomock.NewController(t)
+	stemmer := newWordsStemmer(c)
+	comicRepo := mock_service.NewMockComicRepository(c)
+	keywordRepo := mock_service.NewMockKeywordRepository(c)
+
+	keywordRepo.EXPECT().Keywords(gomock.Any(), gomock.InAnyOrder([]string{"python", "code"})).
+		Return([]*domain.ComicKeyword{
+			{Word: "python", Postings: []domain.Posting{{Num: 1, Freq: 1}, {Num: 2, Freq: 1}}},
+			{Word: "code", Postings: []domain.Posting{{Num: 3, Freq: 1}}},
+		}, nil)
+	keywordRepo.EXPECT().Stats(gomock.Any()).Return(&domain.IndexStats{Docs: 100, AvgLength: 50, MaxNum: 100}, nil)
+	keywordRepo.EXPECT().Lengths(gomock.Any(), gomock.InAnyOrder([]int{1, 2, 3})).
+		Return(map[int]int{1: 1, 2: 2000, 3: 50}, nil)
+	comicRepo.EXPECT().Comics(gomock.Any(), gomock.InAnyOrder([]int{1, 2, 3})).
+		Return([]*domain.Comic{comicLong, comicOther, comicShort}, nil)
+
+	s := NewScanner(slog.New(logger.EmptyHandler{}), stemmer, comicRepo, keywordRepo, nil, nil, 2)
+	res, err := s.Scan(context.Background(), "python code", domain.ScanOptions{UseIndex: true})
+	require.NoError(t, err)
+	require.Len(t, res.Comics, 3)
+
+	assert.Equal(t, comicShort, res.Comics[0].Comic)
+	assert.Equal(t, comicOther, res.Comics[1].Comic)
+	assert.Equal(t, comicLong, res.Comics[2].Comic)
+	assert.Greater(t, res.Comics[0].Score, res.Comics[1].Score)
+	assert.Greater(t, res.Comics[1].Score, res.Comics[2].Score)
+	assert.Equal(t, []string{"python"}, res.Comics[0].Terms)
+	assert.Equal(t, []string{"code"}, res.Comics[1].Terms)
+}
+
+func TestScanner_ScanIndexNoMatches(t *testing.T) {
+	t.Parallel()
+
+	c := gomock.NewController(t)
+	stemmer := newWordsStemmer(c)
+	comicRepo := mock_service.NewMockComicRepository(c)
+	keywordRepo := mock_service.NewMockKeywordRepository(c)
+
+	keywordRepo.EXPECT().Stats(gomock.Any()).Return(&domain.IndexStats{Docs: 10, AvgLength: 10, MaxNum: 10}, nil)
+	keywordRepo.EXPECT().Keywords(gomock.Any(), []string{"noth"}).Return([]*domain.ComicKeyword{}, nil)
+
+	s := NewScanner(slog.New(logger.EmptyHandler{}), stemmer, comicRepo, keywordRepo, nil, nil, 2)
+	res, err := s.Scan(context.Background(), "noth", domain.ScanOptions{UseIndex: true})
+	require.NoError(t, err)
+	assert.Empty(t, res.Comics)
+}
+
+func TestScanner_ScanIndexQuery(t *testing.T) {
+	t.Parallel()
+
+	var comics = map[int]*domain.Comic{
+		1: {Num: 1, Img: "img1"},
+		2: {Num: 2, Img: "img2"},
+		3: {Num: 3, Img: "img3"},
+		4: {Num: 4, Img: "img4"},
+	}
+	var keywords = []*domain.ComicKeyword{
+		{Word: "python", Postings: []domain.Posting{
+			{Num: 1, Freq: 1, Positions: []int{0}},
+			{Num: 2, Freq: 1, Positions: []int{3}},
+			{Num: 3, Freq: 1, Positions: []int{1}},
+		}},
+		{Word: "java", Postings: []domain.Posting{
+			{Num: 2, Freq: 1, Positions: []int{1}},
+			{Num: 4, Freq: 1, Positions: []int{0}},
+		}},
+		{Word: "bobby", Postings: []domain.Posting{
+			{Num: 1, Freq: 2, Positions: []int{2, 7}},
+			{Num: 3, Freq: 1, Positions: []int{4}},
+		}},
+		{Word: "tables", Postings: []domain.Posting{
+			{Num: 1, Freq: 1, Positions: []int{5}},
+			{Num: 3, Freq: 1, Positions: []int{5}},
+		}},
+	}
+
+	testTable := []struct {
+		name     string
+		query    string
+		expected []int
+	}{
+		{name: "Or", query: "java OR bobby", expected: []int{1, 2, 3, 4}},
+		{name: "And", query: "python AND java", expected: []int{2}},
+		{name: "Not", query: "python AND NOT java", expected: []int{1, 3}},
+		{name: "RequiredProhibited", query: "+python -bobby java", expected: []int{2}},
+		{name: "Phrase", query: `"bobby tables"`, expected: []int{3}},
+		{name: "PhraseGroup", query: `("bobby tables" OR java) -python`, expected: []int{4}},
+		{name: "NoMatch", query: "python AND java AND bobby", expected: []int{}},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := gomock.NewController(t)
+			stemmer := newWordsStemmer(c)
+			comicRepo := mock_service.NewMockComicRepository(c)
+			keywordRepo := mock_service.NewMockKeywordRepository(c)
+
+			keywordRepo.EXPECT().Keywords(gomock.Any(), gomock.Any()).Return(keywords, nil)
+			keywordRepo.EXPECT().Stats(gomock.Any()).AnyTimes().Return(&domain.IndexStats{Docs: 4, AvgLength: 10, MaxNum: 4}, nil)
+			keywordRepo.EXPECT().Lengths(gomock.Any(), gomock.Any()).AnyTimes().Return(map[int]int{}, nil)
+			comicRepo.EXPECT().Comics(gomock.Any(), gomock.Any()).AnyTimes().
+				DoAndReturn(func(_ context.Context, nums []int) ([]*domain.Comic, error) {
+					res := make([]*domain.Comic, 0, len(nums))
+					for _, num := range nums {
+						res = append(res, comics[num])
+					}
+					return res, nil
+				})
+
+			s := NewScanner(slog.New(logger.EmptyHandler{}), stemmer, comicRepo, keywordRepo, nil, nil, 2)
+			res, err := s.Scan(context.Background(), testCase.query, domain.ScanOptions{UseIndex: true})
+			require.NoError(t, err)
+
+			nums := make([]int, len(res.Comics))
+			for i, v := range res.Comics {
+				nums[i] = v.Comic.Num
+			}
+			assert.ElementsMatch(t, testCase.expected, nums)
+		})
+	}
+}
+
+func TestScanner_ScanIndexFields(t *testing.T) {
+	t.Parallel()
+
+	var comicTitle = &domain.Comic{Num: 1, Img: "img1"}
+	var comicTranscript = &domain.Comic{Num: 2, Img: "img2"}
+	var keywords = []*domain.ComicKeyword{
+		{Word: "compil", Postings: []domain.Posting{
+			{Num: 1, Field: domain.FieldTitle, Freq: 1, Positions: []int{0}},
+			{Num: 2, Field: domain.FieldTranscript, Freq: 1, Positions: []int{4}},
+		}},
+	}
+	var boosts = map[domain.Field]float64{domain.FieldTitle: 3, domain.FieldTranscript: 1}
+
+	testTable := []struct {
+		name     string
+		query    string
+		fields   []domain.Field
+		expected []*domain.Comic
+	}{
+		{name: "TitleBoosted", query: "compil", expected: []*domain.Comic{comicTitle, comicTranscript}},
+		{name: "FieldScoped", query: "transcript:compil", expected: []*domain.Comic{comicTranscript}},
+		{name: "DefaultFields", query: "compil", fields: []domain.Field{domain.FieldAlt}, expected: []*domain.Comic{}},
+		{
+			name:     "ExplicitFieldOverridesDefault",
+			query:    "title:compil",
+			fields:   []domain.Field{domain.FieldAlt},
+			expected: []*domain.Comic{comicTitle},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := gomock.NewController(t)
+			stemmer := newWordsStemmer(c)
+			comicRepo := mock_service.NewMockComicRepository(c)
+			keywordRepo := mock_service.NewMockKeywordRepository(c)
+
+			keywordRepo.EXPECT().Keywords(gomock.Any(), []string{"compil"}).Return(keywords, nil)
+			keywordRepo.EXPECT().Stats(gomock.Any()).AnyTimes().Return(&domain.IndexStats{Docs: 10, AvgLength: 10, MaxNum: 10}, nil)
+			keywordRepo.EXPECT().Lengths(gomock.Any(), gomock.Any()).AnyTimes().
+				Return(map[int]int{1: 10, 2: 10}, nil)
+			comicRepo.EXPECT().Comics(gomock.Any(), gomock.Any()).AnyTimes().
+				Return([]*domain.Comic{comicTitle, comicTranscript}, nil)
+
+			s := NewScanner(slog.New(logger.EmptyHandler{}), stemmer, comicRepo, keywordRepo, nil, boosts, 2)
+			res, err := s.Scan(context.Background(), testCase.query,
+				domain.ScanOptions{UseIndex: true, Fields: testCase.fields})
+			require.NoError(t, err)
+
+			comics := make([]*domain.Comic, len(res.Comics))
+			for i, v := range res.Comics {
+				comics[i] = v.Comic
+			}
+			assert.Equal(t, testCase.expected, comics)
+		})
+	}
+}
+
+func TestScanner_VocabularyGeneration(t *testing.T) {
+	t.Parallel()
+
+	c := gomock.NewController(t)
+	keywordRepo := mock_service.NewMockKeywordRepository(c)
+
+	// a comic is changed in place, the number of comics stays the same and only the generation tells
+	gomock.InOrder(
+		keywordRepo.EXPECT().Stats(gomock.Any()).Times(2).Return(&domain.IndexStats{Docs: 1, Generation: 1}, nil),
+		keywordRepo.EXPECT().Stats(gomock.Any()).Times(1).Return(&domain.IndexStats{Docs: 1, Generation: 2}, nil),
+	)
+	gomock.InOrder(
+		keywordRepo.EXPECT().Vocabulary(gomock.Any()).Times(1).
+			Return([]*domain.VocabularyWord{{Word: "python", DocFreq: 1}}, nil),
+		keywordRepo.EXPECT().Vocabulary(gomock.Any()).Times(1).
+			Return([]*domain.VocabularyWord{{Word: "rust", DocFreq: 1}}, nil),
+	)
+
+	s := NewScanner(slog.New(logger.EmptyHandler{}), nil, nil, keywordRepo, nil, nil, 2)
+
+	for range 2 {
+		vocab, err := s.vocabulary(context.Background())
+		require.NoError(t, err)
+		assert.Contains(t, vocab.freqs, "python")
+	}
+
+	vocab, err := s.vocabulary(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"rust": 1}, vocab.freqs)
+}
+
+func TestScanner_ScanIndexFuzzy(t *testing.T) {
+	t.Parallel()
+
+	var comicExact = &domain.Comic{Num: 1, Img: "img1"}
+	var comicFuzzy = &domain.Comic{Num: 2, Img: "img2"}
+
+	c := gomock.NewController(t)
+	stemmer := newWordsStemmer(c)
+	comicRepo := mock_service.NewMockComicRepository(c)
+	keywordRepo := mock_service.NewMockKeywordRepository(c)
+
+	keywordRepo.EXPECT().Stats(gomock.Any()).AnyTimes().Return(&domain.IndexStats{Docs: 10, AvgLength: 10, MaxNum: 10}, nil)
+	keywordRepo.EXPECT().Vocabulary(gomock.Any()).Times(1).Return([]*domain.VocabularyWord{
+		{Word: "recus", DocFreq: 1},
+		{Word: "recurs", DocFreq: 5},
+		{Word: "python", DocFreq: 3},
+	}, nil)
+	keywordRepo.EXPECT().Keywords(gomock.Any(), gomock.InAnyOrder([]string{"recus", "recurs"})).Times(2).
+		Return([]*domain.ComicKeyword{
+			{Word: "recus", Postings: []domain.Posting{{Num: 2, Freq: 1}}},
+			{Word: "recurs", Postings: []domain.Posting{{Num: 1, Freq: 1}}},
+		}, nil)
+	keywordRepo.EXPECT().Lengths(gomock.Any(), gomock.Any()).AnyTimes().Return(map[int]int{1: 10, 2: 10}, nil)
+	comicRepo.EXPECT().Comics(gomock.Any(), gomock.Any()).AnyTimes().
+		Return([]*domain.Comic{comicExact, comicFuzzy}, nil)
+
+	s := NewScanner(slog.New(logger.EmptyHandler{}), stemmer, comicRepo, keywordRepo, nil, nil, 2)
+
+	res, err := s.Scan(context.Background(), "recurs", domain.ScanOptions{UseIndex: true, Fuzzy: true})
+	require.NoError(t, err)
+	require.Len(t, res.Comics, 2)
+	assert.Equal(t, comicExact, res.Comics[0].Comic)
+	assert.Equal(t, comicFuzzy, res.Comics[1].Comic)
+	assert.Greater(t, res.Comics[0].Score, res.Comics[1].Score)
+	assert.Equal(t, []string{"recurs"}, res.Comics[0].Terms)
+	assert.Equal(t, []string{"recus"}, res.Comics[1].Terms)
+
+	res, err = s.Scan(context.Background(), "recus", domain.ScanOptions{UseIndex: true, Fuzzy: true})
+	require.NoError(t, err)
+	require.Len(t, res.Comics, 2)
+	assert.Equal(t, comicFuzzy, res.Comics[0].Comic)
+	assert.Equal(t, comicExact, res.Comics[1].Comic)
+}
+
+func TestScanner_ScanIndexSuggest(t *testing.T) {
+	t.Parallel()
+
+	var vocabulary = []*domain.VocabularyWord{
+		{Word: "recurs", DocFreq: 2, Surface: "recursion"},
+		{Word: "recus", DocFreq: 3, Surface: "recuse"},
+		{Word: "python", DocFreq: 3, Surface: "python"},
+		{Word: "code", DocFreq: 8},
+	}
+
+	testTable := []struct {
+		name     string
+		query    string
+		suggest  bool
+		expected []string
+	}{
+		{name: "Disabled", query: "recurss", expected: nil},
+		{name: "Ranked", query: "recurss", suggest: true, expected: []string{"recursion", "recuse"}},
+		{name: "Syntax", query: `+pyton AND "recurss code"`, suggest: true, expected: []string{
+			`+python AND "recursion code"`,
+			`+python AND "recuse code"`,
+		}},
+		{name: "Known", query: "python AND code", suggest: true, expected: nil},
+		{name: "NothingSimilar", query: "zzzzzz", suggest: true, expected: nil},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := gomock.NewController(t)
+			stemmer := newWordsStemmer(c)
+			comicRepo := mock_service.NewMockComicRepository(c)
+			keywordRepo := mock_service.NewMockKeywordRepository(c)
+
+			keywordRepo.EXPECT().Keywords(gomock.Any(), gomock.Any()).Return([]*domain.ComicKeyword{}, nil)
+			keywordRepo.EXPECT().Stats(gomock.Any()).AnyTimes().Return(&domain.IndexStats{Docs: 10, AvgLength: 10, MaxNum: 10}, nil)
+			keywordRepo.EXPECT().Vocabulary(gomock.Any()).AnyTimes().Return(vocabulary, nil)
+
+			s := NewScanner(slog.New(logger.EmptyHandler{}), stemmer, comicRepo, keywordRepo, nil, nil, 2)
+			res, err := s.Scan(context.Background(), testCase.query,
+				domain.ScanOptions{UseIndex: true, Suggest: testCase.suggest})
+			require.NoError(t, err)
+			assert.Empty(t, res.Comics)
+			assert.Equal(t, testCase.expected, res.Suggestions)
+		})
+	}
+}
+
+func TestScanner_ScanIndexPages(t *testing.T) {
+	t.Parallel()
+
+	comics := make(map[int]*domain.Comic)
+	postings := make([]domain.Posting, 0)
+	generations := make(map[int]int64)
+	for num := 2; num <= 6; num++ {
+		comics[num] = &domain.Comic{Num: num}
+		postings = append(postings, domain.Posting{Num: num, Freq: num%3 + 1})
+		generations[num] = 1
+	}
+	// comic 1, which failed to be fetched before, is indexed while pages are read,
+	// it must not appear on the next pages or change their scores
+	comics[1] = &domain.Comic{Num: 1}
+	updated := append(slices.Clone(postings), domain.Posting{Num: 1, Freq: 10})
+
+	c := gomock.NewController(t)
+	stemmer := newWordsStemmer(c)
+	comicRepo := mock_service.NewMockComicRepository(c)
+	keywordRepo := mock_service.NewMockKeywordRepository(c)
+
+	indexed, generation := postings, int64(1)
+	keywordRepo.EXPECT().Stats(gomock.Any()).AnyTimes().DoAndReturn(func(context.Context) (*domain.IndexStats, error) {
+		return &domain.IndexStats{Docs: len(indexed), AvgLength: 10, MaxNum: 6, Generation: generation}, nil
+	})
+	keywordRepo.EXPECT().Generations(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, nums []int) (map[int]int64, error) {
+			res := make(map[int]int64, len(nums))
+			for _, num := range nums {
+				if generation, ok := generations[num]; ok {
+					res[num] = generation
+				}
+			}
+			return res, nil
+		})
+	keywordRepo.EXPECT().Keywords(gomock.Any(), []string{"python"}).AnyTimes().
+		DoAndReturn(func(context.Context, []string) ([]*domain.ComicKeyword, error) {
+			return []*domain.ComicKeyword{{Word: "python", Postings: indexed}}, nil
+		})
+	keywordRepo.EXPECT().Lengths(gomock.Any(), gomock.Any()).AnyTimes().Return(map[int]int{}, nil)
+	comicRepo.EXPECT().Comics(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, nums []int) ([]*domain.Comic, error) {
+			res := make([]*domain.Comic, 0, len(nums))
+			for _, num := range nums {
+				res = append(res, comics[num])
+			}
+			return res, nil
+		})
+
+	s := NewScanner(slog.New(logger.EmptyHandler{}), stemmer, comicRepo, keywordRepo, nil, nil, 2)
+
+	all, err := s.Scan(context.Background(), "python", domain.ScanOptions{UseIndex: true})
+	require.NoError(t, err)
+	require.Len(t, all.Comics, 5)
+	assert.Nil(t, all.Next)
+
+	paged := make([]*domain.ScoredComic, 0)
+	opts := domain.ScanOptions{UseIndex: true, Limit: 2}
+	for pages := 1; ; pages++ {
+		require.LessOrEqual(t, pages, 3)
+
+		res, err := s.Scan(context.Background(), "python", opts)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(res.Comics), 2)
+		paged = append(paged, res.Comics...)
+
+		indexed, generation = updated, 2
+		generations[1] = generation
+		if res.Next == nil {
+			break
+		}
+		opts.Cursor = res.Next
+	}
+
+	assert.Equal(t, all.Comics, paged)
+}
+
+func TestScanner_ScanIndexTopK(t *testing.T) {
+	t.Parallel()
+
+	// every comic has a common word, a few have a rare one, lengths vary
+	const total = 500
+	comics := make(map[int]*domain.Comic, total)
+	lengths := make(map[int]int, total)
+	common := &domain.ComicKeyword{Word: "code"}
+	rare := &domain.ComicKeyword{Word: "python"}
+	for num := 1; num <= total; num++ {
+		comics[num] = &domain.Comic{Num: num}
+		lengths[num] = 5 + num*7%40
+		common.Postings = append(common.Postings, domain.Posting{Num: num, Freq: 1 + num%4})
+		if num%50 == 0 {
+			rare.Postings = append(rare.Postings, domain.Posting{Num: num, Freq: 1})
+		}
+	}
+
+	c := gomock.NewController(t)
+	stemmer := newWordsStemmer(c)
+	comicRepo := mock_service.NewMockComicRepository(c)
+	keywordRepo := mock_service.NewMockKeywordRepository(c)
+
+	loaded := 0
+	keywordRepo.EXPECT().Stats(gomock.Any()).AnyTimes().
+		Return(&domain.IndexStats{Docs: total, AvgLength: 25, MaxNum: total}, nil)
+	keywordRepo.EXPECT().Keywords(gomock.Any(), gomock.Any()).AnyTimes().
+		Return([]*domain.ComicKeyword{common, rare}, nil)
+	keywordRepo.EXPECT().Lengths(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, nums []int) (map[int]int, error) {
+			loaded += len(nums)
+			res := make(map[int]int, len(nums))
+			for _, num := range nums {
+				res[num] = lengths[num]
+			}
+			return res, nil
+		})
+	comicRepo.EXPECT().Comics(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, nums []int) ([]*domain.Comic, error) {
+			res := make([]*domain.Comic, 0, len(nums))
+			for _, num := range nums {
+				res = append(res, comics[num])
+			}
+			return res, nil
+		})
+
+	s := NewScanner(slog.New(logger.EmptyHandler{}), stemmer, comicRepo, keywordRepo, nil, nil, 2)
+
+	all, err := s.Scan(context.Background(), "python code", domain.ScanOptions{UseIndex: true})
+	require.NoError(t, err)
+	require.Len(t, all.Comics, total)
+	require.Equal(t, total, loaded)
+
+	for _, limit := range []int{1, 10, 100} {
+		loaded = 0
+		res, err := s.Scan(context.Background(), "python code", domain.ScanOptions{UseIndex: true, Limit: limit})
+		require.NoError(t, err)
+		assert.Equal(t, all.Comics[:limit], res.Comics, limit)
+		assert.Less(t, loaded, total, limit)
+	}
+}
+
+func TestScanner_ScanBadQuery(t *testing.T) {
+	t.Parallel()
+
+	c := gomock.NewController(t)
+	stemmer := newWordsStemmer(c)
+	comicRepo := mock_service.NewMockComicRepository(c)
+	keywordRepo := mock_service.NewMockKeywordRepository(c)
+
+	s := NewScanner(slog.New(logger.EmptyHandler{}), stemmer, comicRepo, keywordRepo, nil, nil, 2)
+	for _, search := range []string{`"bobby tables`, "(python", "NOT python", "python AND"} {
+		_, err := s.Scan(context.Background(), search, domain.ScanOptions{UseIndex: true})
+		require.ErrorIs(t, err, ErrBadQuery, search)
+	}
+}
+
+func TestScanner_ScanComics(t *testing.T) {
+	t.Parallel()
+
+	var comicTitle = &domain.Comic{Num: 1, Title: "Python", Img: "img1"}
+	var comicTranscript = &domain.Comic{Num: 2, Title: "Long", Img: "img2"}
+	var comicNone = &domain.Comic{Num: 3, Title: "Other", Img: "img3"}
+
+	c := gomock.NewController(t)
+	stemmer := newWordsStemmer(c)
+	comicRepo := mock_service.NewMockComicRepository(c)
+	keywordRepo := mock_service.NewMockKeywordRepository(c)
+
+	comicRepo.EXPECT().All(gomock.Any()).Return([]*domain.Comic{comicTranscript, comicNone, comicTitle}, nil)
+	stemmer.EXPECT().StemComic(comicTitle).Return(tokens("python"))
+	stemmer.EXPECT().StemComic(comicTranscript).Return(tokens("long", "snake", "python", "snake", "snake", "snake"))
+	stemmer.EXPECT().StemComic(comicNone).Return(tokens("other"))
+
+	s := NewScanner(slog.New(logger.EmptyHandler{}), stemmer, comicRepo, keywordRepo, nil, nil, 2)
+	res, err := s.Scan(context.Background(), "python", domain.ScanOptions{})
+	require.NoError(t, err)
+	require.Len(t, res.Comics, 2)
+	assert.Equal(t, comicTitle, res.Comics[0].Comic)
+	assert.Equal(t, comicTranscript, res.Comics[1].Comic)
+}
+
+// newWordsStemmer makes a stemmer mock that treats every whitespace separated word as a stem.
+func newWordsStemmer(c *gomock.Controller) *mock_service.MockStemmer {
+	stemmer := mock_service.NewMockStemmer(c)
+	stemmer.EXPECT().Tokenize(gomock.Any()).AnyTimes().DoAndReturn(func(str string) []domain.Token {
+		return tokens(strings.Fields(str)...)
+	})
+	return stemmer
+}
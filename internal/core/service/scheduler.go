@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+	"yadro-go/internal/core/domain"
+	"yadro-go/pkg/cron"
+	"yadro-go/pkg/logger"
+)
+
+// Schedule is when the scheduler runs updates.
+type Schedule struct {
+	// Crons are matched by the wall clock in the Location, an update runs at the earliest of them
+	Crons []*cron.Schedule
+	// RefreshCrons are times of refresh updates, which fetch all stored comics again
+	RefreshCrons []*cron.Schedule
+	Location     *time.Location
+	// Jitter is the upper bound of a random delay added to every scheduled time.
+	// It should be shorter than the interval between the times, otherwise some of them are skipped.
+	Jitter time.Duration
+	// CatchUp runs an update on start when the last successful one has finished longer ago, zero disables it
+	CatchUp time.Duration
+}
+
+// schedulerTick bounds waiting for the next time. Timers run on the monotonic clock and don't notice
+// changes of the wall clock, so it is checked again at least this often.
+const schedulerTick = time.Minute
+
+type scheduler struct {
+	mu       sync.Mutex
+	schedule Schedule
+	jitter   func() time.Duration
+	// planned is the matched cron time, next is the planned time with jitter
+	planned time.Time
+	next    time.Time
+	mode    domain.UpdateMode
+	// checked is the last wall clock reading, fired is when the last update was started
+	checked time.Time
+	fired   time.Time
+	// leader tells if the instance holds the scheduler lease
+	leader bool
+}
+
+func newScheduler(schedule Schedule, now time.Time) *scheduler {
+	if schedule.Location == nil {
+		schedule.Location = time.Local
+	}
+
+	s := &scheduler{schedule: schedule, checked: now}
+	if schedule.Jitter > 0 {
+		s.jitter = func() time.Duration {
+			return rand.N(schedule.Jitter)
+		}
+	}
+	s.plan(now)
+	return s
+}
+
+// plan finds the earliest time of the crons after the given one, zero time if none of them matches again.
+// A refresh wins over a regular update at the same time, as it fetches new comics too.
+func (s *scheduler) plan(after time.Time) {
+	s.planned = time.Time{}
+	for _, c := range s.crons() {
+		next := c.Next(after.In(s.schedule.Location))
+		if next.IsZero() || !s.planned.IsZero() && next.After(s.planned) {
+			continue
+		}
+		if next.Equal(s.planned) && s.mode == domain.UpdateModeRefresh {
+			continue
+		}
+		s.planned, s.mode = next, c.mode
+	}
+
+	s.next = s.planned
+	if !s.planned.IsZero() && s.jitter != nil {
+		s.next = s.planned.Add(s.jitter())
+	}
+}
+
+type modeCron struct {
+	*cron.Schedule
+	mode domain.UpdateMode
+}
+
+func (s *scheduler) crons() []modeCron {
+	res := make([]modeCron, 0, len(s.schedule.Crons)+len(s.schedule.RefreshCrons))
+	for _, c := range s.schedule.Crons {
+		res = append(res, modeCron{Schedule: c, mode: domain.UpdateModeNew})
+	}
+	for _, c := range s.schedule.RefreshCrons {
+		res = append(res, modeCron{Schedule: c, mode: domain.UpdateModeRefresh})
+	}
+	return res
+}
+
+// due tells if an update has to run at the wall clock time now and its mode. When the clock has been set back,
+// the next time is planned again from now, but not before the last update, so that it isn't repeated.
+// When the clock has jumped forward over several times, the update runs once.
+func (s *scheduler) due(now time.Time) (domain.UpdateMode, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Before(s.checked) {
+		s.plan(later(now, s.fired))
+	}
+	s.checked = now
+
+	if s.next.IsZero() || now.Before(s.next) {
+		return "", false
+	}
+	mode := s.mode
+	s.fired = now
+	s.plan(now)
+	return mode, true
+}
+
+// wait returns how long to wait before checking the clock again.
+func (s *scheduler) wait(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.next.IsZero() {
+		return schedulerTick
+	}
+	return max(0, min(s.next.Sub(now), schedulerTick))
+}
+
+func (s *scheduler) setLeader(leader bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.leader = leader
+}
+
+func (s *scheduler) info() *domain.UpdateSchedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info := &domain.UpdateSchedule{
+		Active:   s.leader,
+		Location: s.schedule.Location.String(),
+		Jitter:   s.schedule.Jitter,
+		Next:     s.next,
+	}
+	after := later(s.checked, s.fired).In(s.schedule.Location)
+	for _, c := range s.crons() {
+		info.Crons = append(info.Crons, &domain.CronSchedule{Expr: c.String(), Mode: c.mode, Next: c.Next(after)})
+	}
+	return info
+}
+
+func later(t time.Time, u time.Time) time.Time {
+	if u.After(t) {
+		return u
+	}
+	return t
+}
+
+// now reads the wall clock without the monotonic one, so that times are compared by the wall clock.
+func now() time.Time {
+	return time.Now().Round(0)
+}
+
+// StartScheduler runs updates at the times of the schedule until ctx is done. An update which is due
+// while another one is running is skipped. With a lease only the instance holding it runs scheduled updates.
+func (u *Updater) StartScheduler(ctx context.Context, schedule Schedule) {
+	const op = "updater.StartScheduler"
+	log := u.log.With(slog.String("op", op))
+
+	s := newScheduler(schedule, now())
+	u.setScheduler(s)
+	defer u.setScheduler(nil)
+	defer u.schedulerLease.release(context.WithoutCancel(ctx))
+
+	log.Debug(fmt.Sprintf("scheduler started: next schedule time %v", s.info().Next))
+
+	leader := u.lead(ctx, false, schedule.CatchUp)
+	s.setLeader(leader)
+
+	timer := time.NewTimer(min(s.wait(now()), u.schedulerLease.heartbeat()))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			t := now()
+			// updates run by other instances are searched here too
+			if err := u.reloadIndex(ctx); err != nil {
+				log.Error("failed to reload index", logger.Err(err))
+			}
+			leader = u.lead(ctx, leader, schedule.CatchUp)
+			s.setLeader(leader)
+			if mode, ok := s.due(t); ok && leader {
+				log.Debug(fmt.Sprintf("%s update by scheduler", mode))
+				u.startScheduled(ctx, log, mode)
+				log.Debug(fmt.Sprintf("next schedule time %v", s.info().Next))
+			}
+			timer.Reset(min(s.wait(t), u.schedulerLease.heartbeat()))
+		case <-ctx.Done():
+			log.Debug("scheduler stopped")
+			return
+		}
+	}
+}
+
+// lead takes or renews the scheduler lease and reports if the instance holds it. An instance which
+// has just taken it catches up on an update missed while it hasn't run scheduled updates.
+func (u *Updater) lead(ctx context.Context, leader bool, catchUp time.Duration) bool {
+	const op = "updater.lead"
+	log := u.log.With(slog.String("op", op))
+
+	ok, err := u.schedulerLease.acquire(ctx)
+	if err != nil {
+		log.Error("failed to acquire scheduler lease", logger.Err(err))
+	}
+
+	switch {
+	case ok && !leader:
+		log.Info("running scheduled updates")
+		if u.catchUpDue(ctx, catchUp) {
+			log.Info("last successful update is too old, catching up")
+			u.startScheduled(ctx, log, domain.UpdateModeNew)
+		}
+	case !ok && leader:
+		log.Warn("scheduler lease lost, scheduled updates run by another instance")
+	}
+	return ok
+}
+
+func (u *Updater) startScheduled(ctx context.Context, log *slog.Logger, mode domain.UpdateMode) {
+	if job, err := u.StartUpdate(ctx, domain.UpdateTriggerScheduler, mode, ""); err != nil {
+		log.Error("scheduled update error", logger.Err(err))
+	} else {
+		log.Info(fmt.Sprintf("scheduled update job %d started", job.Id))
+	}
+}
+
+// catchUpDue tells if the last successful update has finished longer than period ago or there is none.
+func (u *Updater) catchUpDue(ctx context.Context, period time.Duration) bool {
+	const op = "updater.catchUpDue"
+	log := u.log.With(slog.String("op", op))
+
+	if period <= 0 {
+		return false
+	}
+
+	last, err := u.runRepo.LastSuccess(ctx)
+	if err != nil {
+		log.Error("failed to get last successful update", logger.Err(err))
+		return false
+	}
+	return last.IsZero() || time.Since(last) > period
+}
+
+func (u *Updater) setScheduler(s *scheduler) {
+	u.schedulerMu.Lock()
+	defer u.schedulerMu.Unlock()
+
+	u.scheduler = s
+}
+
+// Schedule returns the times of the next scheduled updates.
+func (u *Updater) Schedule() (*domain.UpdateSchedule, error) {
+	const op = "updater.Schedule"
+
+	u.schedulerMu.Lock()
+	s := u.scheduler
+	u.schedulerMu.Unlock()
+
+	if s == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrNoScheduler)
+	}
+	return s.info(), nil
+}
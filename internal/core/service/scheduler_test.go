@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"testing"
+	"time"
+	"yadro-go/internal/core/domain"
+	mock_service "yadro-go/internal/core/service/mocks"
+	"yadro-go/pkg/cron"
+	"yadro-go/test/logger"
+)
+
+func mustCron(t *testing.T, exprs ...string) []*cron.Schedule {
+	t.Helper()
+
+	res := make([]*cron.Schedule, len(exprs))
+	for i, expr := range exprs {
+		c, err := cron.Parse(expr)
+		require.NoError(t, err)
+		res[i] = c
+	}
+	return res
+}
+
+func TestScheduler_Due(t *testing.T) {
+	t.Parallel()
+
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 6, 7, hour, minute, 0, 0, time.UTC)
+	}
+
+	testTable := []struct {
+		name  string
+		crons []string
+		// clock is the sequence of wall clock readings after the start at 01:00
+		clock []time.Time
+		fired []bool
+	}{
+		{name: "Daily", crons: []string{"0 3 * * *"},
+			clock: []time.Time{at(2, 59), at(3, 0), at(3, 1), at(3, 0).Add(24 * time.Hour)},
+			fired: []bool{false, true, false, true}},
+		{name: "EarliestCron", crons: []string{"0 3 * * *", "30 2 * * *"},
+			clock: []time.Time{at(2, 30), at(3, 0)},
+			fired: []bool{true, true}},
+		// the clock has jumped over several times, the update runs once
+		{name: "JumpForward", crons: []string{"*/10 * * * *"},
+			clock: []time.Time{at(5, 0), at(5, 5), at(5, 10)},
+			fired: []bool{true, false, true}},
+		// the clock has gone back over the time of the last update, it isn't repeated
+		{name: "JumpBackAfterRun", crons: []string{"0 3 * * *"},
+			clock: []time.Time{at(3, 0), at(2, 0), at(3, 0), at(3, 0).Add(24 * time.Hour)},
+			fired: []bool{true, false, false, true}},
+		// the clock has gone back a day before the next time, the closer time is planned
+		{name: "JumpBackBeforeRun", crons: []string{"0 3 * * *"},
+			clock: []time.Time{at(2, 0), at(2, 0).Add(-24 * time.Hour), at(3, 0).Add(-24 * time.Hour)},
+			fired: []bool{false, false, true}},
+		{name: "Impossible", crons: []string{"0 0 30 2 *"},
+			clock: []time.Time{at(3, 0), at(3, 0).AddDate(10, 0, 0)},
+			fired: []bool{false, false}},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := newScheduler(Schedule{Crons: mustCron(t, testCase.crons...), Location: time.UTC}, at(1, 0))
+			for i, now := range testCase.clock {
+				_, fired := s.due(now)
+				assert.Equal(t, testCase.fired[i], fired, "at %v", now)
+			}
+		})
+	}
+}
+
+func TestScheduler_Mode(t *testing.T) {
+	t.Parallel()
+
+	at := func(day, hour int) time.Time {
+		return time.Date(2024, 6, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	// refreshes run on Sundays at the time of the daily update, which they replace
+	s := newScheduler(Schedule{
+		Crons:        mustCron(t, "0 3 * * *"),
+		RefreshCrons: mustCron(t, "0 3 * * sun"),
+		Location:     time.UTC,
+	}, at(7, 0))
+
+	for _, step := range []struct {
+		day  int
+		mode domain.UpdateMode
+	}{{7, domain.UpdateModeNew}, {8, domain.UpdateModeNew}, {9, domain.UpdateModeRefresh}, {10, domain.UpdateModeNew}} {
+		mode, fired := s.due(at(step.day, 3))
+		require.True(t, fired)
+		assert.Equal(t, step.mode, mode, "on %d", step.day)
+	}
+
+	info := s.info()
+	require.Len(t, info.Crons, 2)
+	assert.Equal(t, domain.UpdateModeNew, info.Crons[0].Mode)
+	assert.Equal(t, domain.UpdateModeRefresh, info.Crons[1].Mode)
+	assert.True(t, at(16, 3).Equal(info.Crons[1].Next))
+}
+
+func TestScheduler_Jitter(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 6, 7, 1, 0, 0, 0, time.UTC)
+	planned := time.Date(2024, 6, 7, 3, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 100; i++ {
+		s := newScheduler(Schedule{Crons: mustCron(t, "0 3 * * *"), Location: time.UTC, Jitter: time.Minute}, start)
+		info := s.info()
+		assert.False(t, info.Next.Before(planned))
+		assert.True(t, info.Next.Before(planned.Add(time.Minute)))
+		assert.True(t, planned.Equal(info.Crons[0].Next))
+	}
+}
+
+func TestScheduler_Wait(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 6, 7, 2, 59, 30, 0, time.UTC)
+	s := newScheduler(Schedule{Crons: mustCron(t, "0 3 * * *"), Location: time.UTC}, start)
+	assert.Equal(t, 30*time.Second, s.wait(start))
+	assert.Equal(t, schedulerTick, s.wait(start.Add(-time.Hour)))
+	assert.Equal(t, time.Duration(0), s.wait(start.Add(time.Hour)))
+
+	none := newScheduler(Schedule{Location: time.UTC}, start)
+	assert.Equal(t, schedulerTick, none.wait(start))
+}
+
+func TestScheduler_Info(t *testing.T) {
+	t.Parallel()
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	start := time.Date(2024, 6, 7, 0, 0, 0, 0, time.UTC)
+	s := newScheduler(Schedule{Crons: mustCron(t, "0 3 * * *", "0 12 * * mon"), Location: berlin}, start)
+
+	info := s.info()
+	assert.Equal(t, "Europe/Berlin", info.Location)
+	assert.True(t, time.Date(2024, 6, 7, 1, 0, 0, 0, time.UTC).Equal(info.Next))
+	require.Len(t, info.Crons, 2)
+	assert.Equal(t, "0 3 * * *", info.Crons[0].Expr)
+	assert.True(t, time.Date(2024, 6, 7, 1, 0, 0, 0, time.UTC).Equal(info.Crons[0].Next))
+	assert.Equal(t, "0 12 * * mon", info.Crons[1].Expr)
+	assert.True(t, time.Date(2024, 6, 10, 10, 0, 0, 0, time.UTC).Equal(info.Crons[1].Next))
+}
+
+func TestUpdater_Schedule(t *testing.T) {
+	t.Parallel()
+
+	u := NewUpdater(slog.New(logger.EmptyHandler{}), nil, nil, nil, nil, nil, 1000, 1)
+	_, err := u.Schedule()
+	assert.ErrorIs(t, err, ErrNoScheduler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		u.StartScheduler(ctx, Schedule{Crons: mustCron(t, "0 3 * * *"), Location: time.UTC})
+	}()
+
+	assert.Eventually(t, func() bool {
+		schedule, err := u.Schedule()
+		return err == nil && len(schedule.Crons) == 1 && !schedule.Next.IsZero()
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+	_, err = u.Schedule()
+	assert.ErrorIs(t, err, ErrNoScheduler)
+}
+
+func TestUpdater_CatchUpDue(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name     string
+		period   time.Duration
+		last     time.Time
+		err      error
+		expected bool
+	}{
+		{name: "Recent", period: time.Hour, last: time.Now().Add(-time.Minute), expected: false},
+		{name: "Old", period: time.Hour, last: time.Now().Add(-2 * time.Hour), expected: true},
+		{name: "NeverSucceeded", period: time.Hour, expected: true},
+		{name: "RepositoryError", period: time.Hour, err: ErrInternal, expected: false},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := gomock.NewController(t)
+			runRepo := mock_service.NewMockUpdateRunRepository(c)
+			runRepo.EXPECT().LastSuccess(gomock.Any()).Return(testCase.last, testCase.err)
+
+			u := NewUpdater(slog.New(logger.EmptyHandler{}), nil, nil, nil, runRepo, nil, 1000, 1)
+			assert.Equal(t, testCase.expected, u.catchUpDue(context.Background(), testCase.period))
+		})
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		t.Parallel()
+
+		u := NewUpdater(slog.New(logger.EmptyHandler{}), nil, nil, nil, nil, nil, 1000, 1)
+		assert.False(t, u.catchUpDue(context.Background(), 0))
+	})
+}
+
+func TestUpdater_StartSchedulerCatchUp(t *testing.T) {
+	t.Parallel()
+
+	c := gomock.NewController(t)
+	runRepo := mock_service.NewMockUpdateRunRepository(c)
+	comicProvider := mock_service.NewMockComicProvider(c)
+	comicRepo := mock_service.NewMockComicRepository(c)
+	keywordRepo := mock_service.NewMockKeywordRepository(c)
+
+	runRepo.EXPECT().LastSuccess(gomock.Any()).Return(time.Time{}, nil)
+	runRepo.EXPECT().Start(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, run *domain.UpdateRun) (int64, error) {
+			assert.Equal(t, domain.UpdateTriggerScheduler, run.Trigger)
+			return 1, nil
+		})
+	runRepo.EXPECT().Finish(gomock.Any(), gomock.Any()).Return(nil)
+	comicProvider.EXPECT().LastNum(gomock.Any()).Return(0, nil)
+	comicRepo.EXPECT().All(gomock.Any()).Return(nil, nil)
+	comicRepo.EXPECT().Missing(gomock.Any()).Return(nil, nil)
+	keywordRepo.EXPECT().Stats(gomock.Any()).Return(&domain.IndexStats{}, nil)
+
+	u := NewUpdater(slog.New(logger.EmptyHandler{}), nil, comicRepo, keywordRepo, runRepo, comicProvider, 1000, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		u.StartScheduler(ctx, Schedule{Location: time.UTC, CatchUp: time.Hour})
+	}()
+
+	assert.Eventually(t, func() bool {
+		job, err := u.UpdateJob(1)
+		return err == nil && job.State != domain.UpdateJobRunning
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
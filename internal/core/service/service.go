@@ -6,5 +6,9 @@ var (
 	ErrWrongCredentials = errors.New("wrong credentials")
 	ErrBadToken         = errors.New("bad token")
 	ErrUpdateInProgress = errors.New("update already in progress")
+	ErrJobNotFound      = errors.New("update job not found")
+	ErrNoScheduler      = errors.New("scheduler is not running")
+	ErrLeaseLost        = errors.New("update lease lost")
+	ErrBadQuery         = errors.New("bad query")
 	ErrInternal         = errors.New("internal error")
 )
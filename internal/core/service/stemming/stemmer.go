@@ -14,14 +14,55 @@ func New() *Stemmer {
 	return &Stemmer{}
 }
 
-func (s *Stemmer) StemComic(comic *domain.Comic) []string {
-	return s.StemString(comic.Title + " " + comic.Alt + " " + comic.Transcript)
+// StemComic returns every stem of the comic text in order of appearance,
+// duplicates included, so callers can count term frequencies and document length.
+// Each field is tokenized separately, positions start from zero in every field.
+func (s *Stemmer) StemComic(comic *domain.Comic) []domain.Token {
+	fields := []struct {
+		field domain.Field
+		text  string
+	}{
+		{domain.FieldTitle, comic.Title},
+		{domain.FieldAlt, comic.Alt},
+		{domain.FieldTranscript, comic.Transcript},
+	}
+
+	tokens := make([]domain.Token, 0)
+	for _, f := range fields {
+		for _, token := range s.Tokenize(f.text) {
+			token.Field = f.field
+			tokens = append(tokens, token)
+		}
+	}
+
+	return tokens
+}
+
+// Tokenize stems every word of the string. Token position is the word index in the string,
+// ignored words are skipped but still counted, so phrase gaps are preserved.
+func (s *Stemmer) Tokenize(str string) []domain.Token {
+	tokens := make([]domain.Token, 0)
+	for i, span := range wordSpans(str) {
+		v := str[span.start:span.end]
+		stemmed := english.Stem(v, false)
+		if shouldIgnore(stemmed) {
+			continue
+		}
+
+		tokens = append(tokens, domain.Token{
+			Stem:  stemmed,
+			Word:  strings.ToLower(v),
+			Pos:   i,
+			Start: span.start,
+			End:   span.end,
+		})
+	}
+
+	return tokens
 }
 
 func (s *Stemmer) StemString(str string) []string {
-	words := strings.FieldsFunc(str, func(r rune) bool {
-		return !unicode.IsLetter(r)
-	})
+	words := splitWords(str)
 
 	stemmedWordsSet := make(map[string]struct{})
 	for _, v := range words {
@@ -42,6 +83,39 @@ func (s *Stemmer) StemString(str string) []string {
 
 }
 
+func splitWords(str string) []string {
+	return strings.FieldsFunc(str, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+}
+
+type wordSpan struct {
+	start int
+	end   int
+}
+
+// wordSpans returns byte offsets of the same words splitWords finds.
+func wordSpans(str string) []wordSpan {
+	spans := make([]wordSpan, 0)
+	start := -1
+	for i, r := range str {
+		if unicode.IsLetter(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			spans = append(spans, wordSpan{start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, wordSpan{start: start, end: len(str)})
+	}
+	return spans
+}
+
 func shouldIgnore(s string) bool {
 	return len(s) <= 2 || english.IsStopWord(s)
 }
@@ -3,6 +3,7 @@ package stemming
 import (
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"yadro-go/internal/core/domain"
 )
 
 var stemmer *Stemmer
@@ -29,3 +30,59 @@ func TestStemmer_StemString(t *testing.T) {
 		assert.ElementsMatch(t, stemmer.StemString(testCase.input), testCase.expected)
 	}
 }
+
+func TestStemmer_StemComic(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		input    *domain.Comic
+		expected []domain.Token
+	}{
+		{&domain.Comic{}, []domain.Token{}},
+		{&domain.Comic{Title: "Python"}, []domain.Token{{Stem: "python", Word: "python", Pos: 0, Field: domain.FieldTitle, Start: 0, End: 6}}},
+		{
+			&domain.Comic{Title: "Following", Alt: "the followers", Transcript: "follow questions"},
+			[]domain.Token{
+				{Stem: "follow", Word: "following", Pos: 0, Field: domain.FieldTitle, Start: 0, End: 9},
+				{Stem: "follow", Word: "followers", Pos: 1, Field: domain.FieldAlt, Start: 4, End: 13},
+				{Stem: "follow", Word: "follow", Pos: 0, Field: domain.FieldTranscript, Start: 0, End: 6},
+				{Stem: "question", Word: "questions", Pos: 1, Field: domain.FieldTranscript, Start: 7, End: 16},
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		assert.Equal(t, testCase.expected, stemmer.StemComic(testCase.input))
+	}
+}
+
+func TestStemmer_Tokenize(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		input    string
+		expected []domain.Token
+	}{
+		{"", []domain.Token{}},
+		{"bobby tables", []domain.Token{
+			{Stem: "bobbi", Word: "bobby", Pos: 0, Start: 0, End: 5},
+			{Stem: "tabl", Word: "tables", Pos: 1, Start: 6, End: 12},
+		}},
+		{"drop the table", []domain.Token{
+			{Stem: "drop", Word: "drop", Pos: 0, Start: 0, End: 4},
+			{Stem: "tabl", Word: "table", Pos: 2, Start: 9, End: 14},
+		}},
+		{"sword-fight", []domain.Token{
+			{Stem: "sword", Word: "sword", Pos: 0, Start: 0, End: 5},
+			{Stem: "fight", Word: "fight", Pos: 1, Start: 6, End: 11},
+		}},
+		{"Ёлки, running!", []domain.Token{
+			{Stem: "ёлки", Word: "ёлки", Pos: 0, Start: 0, End: 8},
+			{Stem: "run", Word: "running", Pos: 1, Start: 10, End: 17},
+		}},
+	}
+
+	for _, testCase := range testTable {
+		assert.Equal(t, testCase.expected, stemmer.Tokenize(testCase.input))
+	}
+}
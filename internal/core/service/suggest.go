@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"yadro-go/internal/core/service/query"
+)
+
+const maxSuggestions = 3
+
+type misspelling struct {
+	span        query.Span
+	corrections []string
+}
+
+// suggest returns corrected queries, where words unknown to the index are replaced by similar indexed ones.
+// Nil is returned if every query word is known or has nothing similar.
+func (s *Scanner) suggest(ctx context.Context, search string) ([]string, error) {
+	vocab, err := s.vocabulary(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	misspellings := make([]misspelling, 0)
+	for _, span := range query.Words(search) {
+		tokens := s.stemmer.Tokenize(span.Text)
+		if len(tokens) != 1 || vocab.freqs[tokens[0].Stem] > 0 {
+			continue
+		}
+
+		corrections := vocab.corrections(tokens[0].Stem, s.fuzzyDist, maxSuggestions)
+		if len(corrections) == 0 {
+			continue
+		}
+		for i, correction := range corrections {
+			corrections[i] = vocab.surface(correction)
+		}
+
+		misspellings = append(misspellings, misspelling{span: span, corrections: corrections})
+	}
+
+	if len(misspellings) == 0 {
+		return nil, nil
+	}
+
+	// i-th suggestion takes i-th correction of every word, the best one is first
+	suggestions := make([]string, 0, maxSuggestions)
+	for i := 0; i < maxSuggestions; i++ {
+		suggestion := replaceMisspellings(search, misspellings, i)
+		if !slices.Contains(suggestions, suggestion) {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+
+	return suggestions, nil
+}
+
+func replaceMisspellings(search string, misspellings []misspelling, i int) string {
+	var sb strings.Builder
+	last := 0
+	for _, m := range misspellings {
+		sb.WriteString(search[last:m.span.Start])
+		sb.WriteString(m.corrections[min(i, len(m.corrections)-1)])
+		last = m.span.End
+	}
+	sb.WriteString(search[last:])
+	return sb.String()
+}
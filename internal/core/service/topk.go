@@ -0,0 +1,139 @@
+package service
+
+import (
+	"container/heap"
+	"slices"
+	"yadro-go/internal/core/domain"
+)
+
+// ranksBefore reports whether a is ranked higher than b: greater score first, then lower number.
+func ranksBefore(a *NumMatch, b *NumMatch) bool {
+	if a.score != b.score {
+		return a.score > b.score
+	}
+	return a.num < b.num
+}
+
+func compareMatches(a *NumMatch, b *NumMatch) int {
+	switch {
+	case ranksBefore(a, b):
+		return -1
+	case ranksBefore(b, a):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// matchHeap keeps the lowest ranked match on top.
+type matchHeap []*NumMatch
+
+func (h matchHeap) Len() int           { return len(h) }
+func (h matchHeap) Less(i, j int) bool { return ranksBefore(h[j], h[i]) }
+func (h matchHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *matchHeap) Push(x any)        { *h = append(*h, x.(*NumMatch)) }
+func (h *matchHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// rankBatch is the number of comics, which lengths are loaded at once while ranking a page.
+const rankBatch = 64
+
+// lengthsFunc returns lengths of the comics.
+type lengthsFunc func(nums []int) (map[int]int, error)
+
+// rank returns the page of matched comics following the cursor, best first, and a cursor of the next page.
+// Comics are visited in order of their score upper bound, lengths are loaded and exact scores are computed
+// only while the bound may get into the page (MaxScore-style early exit). Bounds are dominated by rare terms,
+// so comics matching only common terms are usually never scored.
+func rank(
+	e *evaluator,
+	nums map[int]struct{},
+	stats *domain.IndexStats,
+	opts domain.ScanOptions,
+	lengths lengthsFunc,
+) ([]*NumMatch, *domain.PageCursor, error) {
+	candidates := make([]*NumMatch, 0, len(nums))
+	for num := range nums {
+		candidates = append(candidates, &NumMatch{num: num, score: e.bound(num, stats)})
+	}
+	slices.SortFunc(candidates, compareMatches)
+
+	var after *NumMatch
+	if opts.Cursor != nil {
+		after = &NumMatch{num: opts.Cursor.Num, score: opts.Cursor.Score}
+	}
+
+	// one extra match tells whether there is a next page
+	k := opts.Limit + 1
+	batchSize := max(rankBatch, k)
+	if opts.Limit <= 0 {
+		k, batchSize = len(candidates), len(candidates)
+	}
+
+	h := make(matchHeap, 0, k)
+	full := func(candidate *NumMatch) bool {
+		return len(h) == k && !ranksBefore(candidate, h[0])
+	}
+
+	for len(candidates) > 0 {
+		batch := candidates[:min(batchSize, len(candidates))]
+		candidates = candidates[len(batch):]
+
+		if i := slices.IndexFunc(batch, full); i >= 0 {
+			batch, candidates = batch[:i], nil
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		batchNums := make([]int, len(batch))
+		for i, candidate := range batch {
+			batchNums[i] = candidate.num
+		}
+		batchLengths, err := lengths(batchNums)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, candidate := range batch {
+			if full(candidate) {
+				candidates = nil
+				break
+			}
+
+			match := e.match(candidate.num, batchLengths[candidate.num], stats)
+			if after != nil && !ranksBefore(after, match) {
+				continue
+			}
+
+			if len(h) < k {
+				heap.Push(&h, match)
+			} else if ranksBefore(match, h[0]) {
+				h[0] = match
+				heap.Fix(&h, 0)
+			}
+		}
+	}
+
+	page := []*NumMatch(h)
+	slices.SortFunc(page, compareMatches)
+
+	if opts.Limit <= 0 || len(page) <= opts.Limit {
+		return page, nil, nil
+	}
+
+	page = page[:opts.Limit]
+	last := page[len(page)-1]
+	return page, &domain.PageCursor{
+		Docs:       stats.Docs,
+		AvgLength:  stats.AvgLength,
+		Generation: stats.Generation,
+		Score:      last.score,
+		Num:        last.num,
+	}, nil
+}
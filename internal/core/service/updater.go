@@ -2,12 +2,13 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"golang.org/x/exp/maps"
 	"log/slog"
+	"slices"
 	"sync"
-	"time"
 	"yadro-go/internal/adapter/secondary"
 	"yadro-go/internal/core/domain"
 	"yadro-go/pkg/logger"
@@ -18,83 +19,121 @@ type Updater struct {
 	stemmer     Stemmer
 	comicRepo   ComicRepository
 	keywordRepo KeywordRepository
+	runRepo     UpdateRunRepository
 	cp          ComicProvider
 	limit       int
 	parallel    int
-	mu          *sync.Mutex
+	batchSize   int
+	// refreshWindow is the number of latest stored comics fetched again by every update
+	refreshWindow int
+	mu            *sync.Mutex
+
+	jobsMu sync.Mutex
+	jobs   map[int64]*updateJob
+	jobIds []int64
+	events *eventBus
+	// jobsCtx is the parent of every job context, Shutdown cancels it
+	jobsCtx  context.Context
+	stopJobs context.CancelFunc
+
+	schedulerMu sync.Mutex
+	scheduler   *scheduler
+
+	// leases are nil when the instance doesn't share the database
+	updateLease    *lease
+	schedulerLease *lease
+	// index is reloaded after writes of other instances, nil when it isn't shared
+	index IndexReloader
 }
 
+const defaultBatchSize = 100
+
 func NewUpdater(
 	log *slog.Logger,
 	stemmer Stemmer,
 	comicRepo ComicRepository,
 	keywordRepo KeywordRepository,
+	runRepo UpdateRunRepository,
 	c ComicProvider,
 	limit int,
 	parallel int,
+	opts ...UpdaterOption,
 ) *Updater {
-	return &Updater{
+	u := &Updater{
 		log:         log,
 		stemmer:     stemmer,
 		comicRepo:   comicRepo,
 		keywordRepo: keywordRepo,
+		runRepo:     runRepo,
 		cp:          c,
 		limit:       limit,
 		parallel:    parallel,
+		batchSize:   defaultBatchSize,
 		mu:          &sync.Mutex{},
+		jobs:        make(map[int64]*updateJob),
+		events:      newEventBus(),
 	}
-}
 
-func (u *Updater) StartScheduler(ctx context.Context, hour int, minute int) {
-	const op = "updater.StartScheduler"
-	log := u.log.With(slog.String("op", op))
+	u.jobsCtx, u.stopJobs = context.WithCancel(context.Background())
 
-	now := time.Now()
-	next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
-	if now.After(next) {
-		next = next.Add(24 * time.Hour)
+	for _, opt := range opts {
+		opt(u)
 	}
 
-	duration := next.Sub(now)
-	timer := time.NewTimer(duration)
-
-	log.Debug(fmt.Sprintf("scheduler started: next schedule time %v", next))
+	return u
+}
 
-	for {
-		select {
-		case <-timer.C:
-			log.Debug("update by scheduler")
-			if _, err := u.Update(ctx); err != nil {
-				log.Error("scheduled update error", logger.Err(err))
-			}
+// Update fetches comics missing from the repository and indexes them. Comics which could not be fetched
+// are reported in the result, while the fetched ones are saved anyway. The latest stored comics within
+// the refresh window are fetched again, the edited ones are saved and indexed again. A refresh fetches
+// all stored comics and the ones recorded as missing again.
+// It runs as an update job and waits for it to finish, the job is cancelled with ctx.
+func (u *Updater) Update(ctx context.Context) (*domain.UpdateResult, error) {
+	const op = "updater.Update"
 
-			next = next.Add(24 * time.Hour)
-			timer.Reset(next.Sub(time.Now()))
-			log.Debug(fmt.Sprintf("next schedule time %v", next))
-		case <-ctx.Done():
-			log.Debug("scheduler stopped")
-			timer.Stop()
-			return
+	job, err := u.startJob(ctx, domain.UpdateTriggerManual, domain.UpdateModeNew, "")
+	if err != nil {
+		if errors.Is(err, ErrUpdateInProgress) {
+			u.log.With(slog.String("op", op)).Warn("update already in progress")
 		}
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-}
-
-func (u *Updater) Update(ctx context.Context) (int, error) {
-	const op = "updater.Update"
-	log := u.log.With(slog.String("op", op))
 
-	if !u.mu.TryLock() {
-		log.Warn("update already in progress")
-		return 0, fmt.Errorf("%s: %w", op, ErrUpdateInProgress)
+	select {
+	case <-job.done:
+	case <-ctx.Done():
+		job.cancel()
+		<-job.done
 	}
-	defer u.mu.Unlock()
+	if job.err != nil {
+		return nil, fmt.Errorf("%s: %w", op, job.err)
+	}
+	return job.job.Result, nil
+}
+
+// update runs the job, the update lock has to be held.
+func (u *Updater) update(ctx context.Context, job *updateJob) (*domain.UpdateResult, error) {
+	const op = "updater.update"
+	log := u.log.With(slog.String("op", op), slog.Int64("job", job.job.Id))
 
 	log.Debug("updating")
 
 	comics, err := u.comicRepo.All(ctx)
 	if err != nil {
 		log.Error("failed to get all comics")
-		return 0, fmt.Errorf("%s: %w", op, ErrInternal)
+		return nil, fmt.Errorf("%s: %w", op, ErrInternal)
+	}
+
+	missing, err := u.comicRepo.Missing(ctx)
+	if err != nil {
+		log.Error("failed to get missing comics", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, ErrInternal)
+	}
+
+	lastNum, err := u.cp.LastNum(ctx)
+	if err != nil {
+		log.Error("failed to get last comic number", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, ErrInternal)
 	}
 
 	comicsMap := make(map[int]*domain.Comic, len(comics))
@@ -102,143 +141,440 @@ func (u *Updater) Update(ctx context.Context) (int, error) {
 		comicsMap[comic.Num] = comic
 	}
 
-	log.Debug(fmt.Sprintf("start fetching with initial comics size %d", len(comicsMap)))
+	ids := missingIds(comicsMap, missing, job.job.Mode, min(lastNum, u.limit))
+	refresh := refreshIds(comicsMap, job.job.Mode, u.refreshWindow)
+	if len(ids)+len(refresh) == 0 {
+		log.Debug("fetch finished: nothing to fetch")
+		if err = u.reindexIfIncomplete(ctx, comics); err != nil {
+			log.Error("failed to reindex keywords", logger.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, ErrInternal)
+		}
+		return &domain.UpdateResult{Total: len(comicsMap)}, nil
+	}
 
-	jobCtx, cancel := context.WithCancel(ctx)
-	defer cancel()
+	job.setTotal(len(ids) + len(refresh))
+	log.Debug(fmt.Sprintf("start fetching %d comics up to %d and %d stored ones with initial comics size %d",
+		len(ids), lastNum, len(refresh), len(comicsMap)))
+
+	// batches are saved on cancellation too, so that an interrupted update keeps its progress
+	saveCtx := context.WithoutCancel(ctx)
+	saved, updated := 0, 0
+	failures, err := u.fetch(ctx, job, append(ids, refresh...), func(comics []*domain.Comic, notFound []int) error {
+		fresh, changed := changedComics(comicsMap, comics)
+		if err := u.saveBatch(saveCtx, job, append(fresh, changed...), unknownIds(comicsMap, notFound)); err != nil {
+			return err
+		}
+		saved += len(fresh)
+		updated += len(changed)
+		return nil
+	})
+	if err != nil {
+		log.Error(fmt.Sprintf("update stopped after saving %d comics and updating %d", saved, updated), logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, ErrInternal)
+	}
 
-	var wg sync.WaitGroup
-	ids := make(chan int, u.parallel)
-	res := make(chan *domain.Comic, u.parallel)
-	errs := make(chan error, u.parallel)
-
-	fetchId := 1
-	pushId := func() bool {
-		for ; fetchId <= u.limit; fetchId++ {
-			_, ok := comicsMap[fetchId]
-			if !ok {
-				ids <- fetchId
-				fetchId++
-				return true
-			}
+	if len(failures) > 0 {
+		for _, failure := range failures {
+			log.Warn(fmt.Sprintf("failed to fetch comic %d: %s", failure.Num, failure.Error))
 		}
+		log.Error(fmt.Sprintf("failed to fetch %d comics", len(failures)))
+	}
 
-		return false
+	if saved+updated == 0 {
+		if err = u.reindexIfIncomplete(ctx, comics); err != nil {
+			log.Error("failed to reindex keywords", logger.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, ErrInternal)
+		}
+		log.Debug("update finished, no new records")
+		return &domain.UpdateResult{Total: len(comicsMap), Failures: failures}, nil
 	}
 
-	for i := 0; i < u.parallel; i++ {
-		if !pushId() {
-			if i == 0 {
-				log.Debug("fetch finished: nothing to fetch")
-				cancel()
-				return len(comicsMap), nil
+	log.Debug(fmt.Sprintf("update finished: %d new comics, %d updated, %d failed", saved, updated, len(failures)))
+	return &domain.UpdateResult{Total: len(comicsMap) + saved, New: saved, Updated: updated, Failures: failures}, nil
+}
+
+// saveBatch stores fetched comics with their keywords and records numbers without a comic as missing.
+// Only fetched comics are indexed, postings of the stored ones stay as they are.
+func (u *Updater) saveBatch(ctx context.Context, job *updateJob, comics []*domain.Comic, notFound []int) error {
+	const op = "updater.saveBatch"
+	log := u.log.With(slog.String("op", op))
+
+	if len(notFound) > 0 {
+		log.Info(fmt.Sprintf("comics %v do not exist, recording them as missing", notFound))
+		if err := u.comicRepo.SaveMissing(ctx, notFound); err != nil {
+			log.Error("failed to save missing comics", logger.Err(err))
+			return err
+		}
+	}
+
+	if len(comics) == 0 {
+		return nil
+	}
+
+	if err := u.comicRepo.Save(ctx, comics); err != nil {
+		log.Error("failed to save comics", logger.Err(err))
+		return err
+	}
+
+	u.notify(job, domain.UpdateEvent{Type: domain.UpdateEventBatchSaved, Count: len(comics)})
+
+	// comics saved without keywords are indexed by ReindexIfIncomplete on the next start
+	u.notify(job, domain.UpdateEvent{Type: domain.UpdateEventIndexingStarted, Count: len(comics)})
+	keywords, lengths := u.buildKeywords(comics)
+	if err := u.keywordRepo.Save(ctx, keywords, lengths); err != nil {
+		log.Error("failed to save keywords", logger.Err(err))
+		return err
+	}
+	u.notify(job, domain.UpdateEvent{Type: domain.UpdateEventIndexingFinished, Count: len(comics)})
+
+	log.Debug(fmt.Sprintf("saved batch of %d comics", len(comics)))
+	return nil
+}
+
+// missingIds returns numbers up to lastNum, which are neither stored nor known to be missing.
+// The refresh mode requests the known missing ones again, xkcd may have published them since.
+func missingIds(comics map[int]*domain.Comic, missing []int, mode domain.UpdateMode, lastNum int) []int {
+	known := make(map[int]struct{}, len(missing))
+	if mode != domain.UpdateModeRefresh {
+		for _, num := range missing {
+			known[num] = struct{}{}
+		}
+	}
+
+	ids := make([]int, 0)
+	for id := 1; id <= lastNum; id++ {
+		if _, ok := comics[id]; ok {
+			continue
+		}
+		if _, ok := known[id]; ok {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// refreshIds returns numbers of stored comics to fetch again: all of them in the refresh mode,
+// otherwise the latest ones within the window.
+func refreshIds(comics map[int]*domain.Comic, mode domain.UpdateMode, window int) []int {
+	ids := maps.Keys(comics)
+	slices.Sort(ids)
+
+	if mode != domain.UpdateModeRefresh {
+		ids = ids[len(ids)-min(window, len(ids)):]
+	}
+	return ids
+}
+
+// changedComics splits fetched comics into the new ones and stored ones whose content has changed,
+// stored comics which are the same are dropped.
+func changedComics(stored map[int]*domain.Comic, comics []*domain.Comic) ([]*domain.Comic, []*domain.Comic) {
+	fresh := make([]*domain.Comic, 0, len(comics))
+	changed := make([]*domain.Comic, 0)
+	for _, comic := range comics {
+		old, ok := stored[comic.Num]
+		switch {
+		case !ok:
+			fresh = append(fresh, comic)
+		case contentHash(old) != contentHash(comic):
+			changed = append(changed, comic)
+		}
+	}
+	return fresh, changed
+}
+
+// contentHash is a hash of the comic fields fetched from xkcd, it tells if a stored comic has been edited.
+func contentHash(comic *domain.Comic) [sha256.Size]byte {
+	h := sha256.New()
+	for _, field := range []string{comic.Title, comic.Alt, comic.Transcript, comic.Img} {
+		h.Write([]byte(field))
+		// fields are separated, so that moving text between them changes the hash
+		h.Write([]byte{0})
+	}
+
+	var res [sha256.Size]byte
+	h.Sum(res[:0])
+	return res
+}
+
+// unknownIds drops stored comics from numbers the provider has no comic for, they aren't recorded as missing.
+func unknownIds(comics map[int]*domain.Comic, nums []int) []int {
+	return slices.DeleteFunc(nums, func(num int) bool {
+		_, ok := comics[num]
+		return ok
+	})
+}
+
+type fetchResult struct {
+	id    int
+	comic *domain.Comic
+	err   error
+}
+
+// fetch gets comics in parallel and passes them to flush in batches, numbers the provider has no comic for
+// are flushed as not found. A failed comic doesn't stop fetching the others, a flush error does.
+// Comics fetched before context cancellation are flushed as well.
+func (u *Updater) fetch(
+	ctx context.Context,
+	job *updateJob,
+	ids []int,
+	flush func(comics []*domain.Comic, notFound []int) error,
+) ([]*domain.FetchFailure, error) {
+	const op = "updater.fetch"
+	log := u.log.With(slog.String("op", op))
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	idsChan := make(chan int)
+	go func() {
+		defer close(idsChan)
+		for _, id := range ids {
+			select {
+			case idsChan <- id:
+			case <-jobCtx.Done():
+				return
 			}
-			break
 		}
+	}()
+
+	results := make(chan fetchResult, u.parallel)
+	var wg sync.WaitGroup
+	for i := 0; i < min(u.parallel, len(ids)); i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			u.fetchJob(jobCtx, ids, res, errs)
+			u.fetchJob(jobCtx, idsChan, results)
 		}()
 	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	comics := make([]*domain.Comic, 0, u.batchSize)
+	notFound := make([]int, 0)
+	failures := make([]*domain.FetchFailure, 0)
+	var err error
+	for result := range results {
+		switch {
+		case err != nil:
+			// results left after a flush error are dropped, the comics are fetched next time
+			continue
+		case result.err == nil:
+			comics = append(comics, result.comic)
+			u.notify(job, domain.UpdateEvent{Type: domain.UpdateEventComicFetched, Num: result.id})
+		case errors.Is(result.err, secondary.ErrComicNotFound):
+			notFound = append(notFound, result.id)
+		case jobCtx.Err() != nil:
+			// requests interrupted by the cancellation are not failures, the comics are fetched next time
+		default:
+			failure := &domain.FetchFailure{Num: result.id, Error: result.err.Error()}
+			failures = append(failures, failure)
+			u.notify(job, domain.UpdateEvent{Type: domain.UpdateEventComicFailed, Num: result.id, Error: failure.Error})
+		}
 
-	newCount := 0
-	loop := true
-	for loop {
-		select {
-		case comic := <-res:
-			newCount++
-			comicsMap[comic.Num] = comic
-			if !pushId() {
-				loop = false
-			}
-		case workerErr := <-errs:
-			if !errors.Is(workerErr, secondary.ErrComicNotFound) {
-				log.Error("finishing update due to worker error", logger.Err(workerErr))
-				err = workerErr
+		if len(comics)+len(notFound) >= u.batchSize {
+			slices.Sort(notFound)
+			if err = flush(comics, notFound); err != nil {
+				cancel()
 			}
-			loop = false
-		case <-ctx.Done():
-			log.Debug("finishing update due to context closure")
-			loop = false
+			comics, notFound = make([]*domain.Comic, 0, u.batchSize), make([]int, 0)
 		}
 	}
 
-	cancel()
-	wg.Wait()
-	close(res)
-
 	if err != nil {
-		return 0, fmt.Errorf("%s: %w", op, ErrInternal)
+		return nil, err
 	}
 
-	for comic := range res {
-		newCount++
-		comicsMap[comic.Num] = comic
+	if ctx.Err() != nil {
+		log.Debug("finishing update due to context closure")
 	}
 
-	if newCount == 0 {
-		log.Debug("update finished, no new records")
-		return len(comicsMap), err
+	if len(comics)+len(notFound) > 0 {
+		slices.Sort(notFound)
+		if err = flush(comics, notFound); err != nil {
+			return nil, err
+		}
 	}
 
-	comics = maps.Values(comicsMap)
-	if err = u.comicRepo.Save(ctx, comics); err != nil {
-		log.Error("failed to save comics", logger.Err(err))
-		return 0, fmt.Errorf("%s: %w", op, ErrInternal)
+	slices.SortFunc(failures, func(a, b *domain.FetchFailure) int {
+		return a.Num - b.Num
+	})
+	return failures, nil
+}
+
+// Reindex rebuilds keywords of all stored comics, e.g. after stemmer settings have changed.
+// It returns the number of indexed comics.
+func (u *Updater) Reindex(ctx context.Context) (int, error) {
+	const op = "updater.Reindex"
+	log := u.log.With(slog.String("op", op))
+
+	if !u.mu.TryLock() {
+		log.Warn("update already in progress")
+		return 0, fmt.Errorf("%s: %w", op, ErrUpdateInProgress)
 	}
+	defer u.mu.Unlock()
 
-	if err = u.updateKeywords(ctx, comics); err != nil {
-		log.Error("failed to update keywords", logger.Err(err))
-		return 0, fmt.Errorf("%s: %w", op, ErrInternal)
+	count := 0
+	err := u.withUpdateLease(ctx, func(ctx context.Context) error {
+		comics, err := u.comicRepo.All(ctx)
+		if err != nil {
+			log.Error("failed to get all comics", logger.Err(err))
+			return ErrInternal
+		}
+
+		if err = u.reindex(ctx, comics); err != nil {
+			log.Error("failed to reindex keywords", logger.Err(err))
+			return ErrInternal
+		}
+
+		count = len(comics)
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrUpdateInProgress) {
+			log.Warn("update already in progress on another instance")
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	log.Debug(fmt.Sprintf("update finished: %d new comics", newCount))
-	return len(comics), nil
+	return count, nil
 }
 
-func (u *Updater) updateKeywords(ctx context.Context, comics []*domain.Comic) error {
-	const op = "updater.updateIndex"
+// ReindexIfIncomplete rebuilds keywords from the stored comics when the index is missing some of them.
+// It is skipped while another instance is updating, the index is checked again on the next start.
+func (u *Updater) ReindexIfIncomplete(ctx context.Context) error {
+	const op = "updater.ReindexIfIncomplete"
 	log := u.log.With(slog.String("op", op))
 
-	log.Debug("updating keywords")
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	err := u.withUpdateLease(ctx, func(ctx context.Context) error {
+		comics, err := u.comicRepo.All(ctx)
+		if err != nil {
+			log.Error("failed to get all comics", logger.Err(err))
+			return ErrInternal
+		}
+
+		if err = u.reindexIfIncomplete(ctx, comics); err != nil {
+			log.Error("failed to reindex keywords", logger.Err(err))
+			return ErrInternal
+		}
+
+		return nil
+	})
+	if errors.Is(err, ErrUpdateInProgress) {
+		log.Info("update in progress on another instance, index check skipped")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// reindexIfIncomplete rebuilds keywords when some of the stored comics are missing from the index,
+// e.g. after a migration has cleared it.
+func (u *Updater) reindexIfIncomplete(ctx context.Context, comics []*domain.Comic) error {
+	const op = "updater.reindexIfIncomplete"
+	log := u.log.With(slog.String("op", op))
+
+	stats, err := u.keywordRepo.Stats(ctx)
+	if err != nil {
+		log.Error("failed to get index stats", logger.Err(err))
+		return err
+	}
+
+	if stats.Docs >= len(comics) {
+		return nil
+	}
+
+	log.Info(fmt.Sprintf("keyword index is incomplete: %d of %d comics indexed, reindexing", stats.Docs, len(comics)))
+	return u.reindex(ctx, comics)
+}
+
+func (u *Updater) reindex(ctx context.Context, comics []*domain.Comic) error {
+	const op = "updater.reindex"
+	log := u.log.With(slog.String("op", op))
+
+	log.Debug(fmt.Sprintf("reindexing %d comics", len(comics)))
+
+	keywords, lengths := u.buildKeywords(comics)
+	if err := u.keywordRepo.Reindex(ctx, keywords, lengths); err != nil {
+		log.Error("failed to reindex keywords", logger.Err(err))
+		return err
+	}
+
+	log.Debug("reindex finished")
+	return nil
+}
+
+// buildKeywords stems comics into keywords and comic lengths.
+func (u *Updater) buildKeywords(comics []*domain.Comic) ([]*domain.ComicKeyword, map[int]int) {
 	keywordsMap := make(map[string]*domain.ComicKeyword)
+	lengths := make(map[int]int, len(comics))
 
 	for _, comic := range comics {
-		stemmed := u.stemmer.StemComic(comic)
-		for _, word := range stemmed {
-			keyword, ok := keywordsMap[word]
-			if ok {
-				keyword.Nums = append(keyword.Nums, comic.Num)
-			} else {
-				keywordsMap[word] = &domain.ComicKeyword{Word: word, Nums: []int{comic.Num}}
+		tokens := u.stemmer.StemComic(comic)
+		lengths[comic.Num] = len(tokens)
+
+		for _, posting := range countSurfaces(postingsFromTokens(comic.Num, tokens), tokens) {
+			keyword, ok := keywordsMap[posting.word]
+			if !ok {
+				keyword = &domain.ComicKeyword{Word: posting.word, Surfaces: make(map[string]int)}
+				keywordsMap[posting.word] = keyword
+			}
+			keyword.Postings = append(keyword.Postings, posting.Posting)
+			for surface, count := range posting.Surfaces {
+				keyword.Surfaces[surface] += count
 			}
 		}
 	}
 
-	if err := u.keywordRepo.Save(ctx, maps.Values(keywordsMap)); err != nil {
-		log.Error("failed to save keywords", logger.Err(err))
-		return err
+	return maps.Values(keywordsMap), lengths
+}
+
+// countSurfaces counts original words of the tokens in postings of their stem and field.
+func countSurfaces(postings []wordPosting, tokens []domain.Token) []wordPosting {
+	type key struct {
+		word  string
+		field domain.Field
 	}
 
-	log.Debug("update keywords finished")
-	return nil
+	indexes := make(map[key]int, len(postings))
+	for i, posting := range postings {
+		indexes[key{word: posting.word, field: posting.Field}] = i
+	}
+
+	for _, token := range tokens {
+		if len(token.Word) == 0 {
+			continue
+		}
+
+		posting := &postings[indexes[key{word: token.Stem, field: token.Field}]]
+		if posting.Surfaces == nil {
+			posting.Surfaces = make(map[string]int)
+		}
+		posting.Surfaces[token.Word]++
+	}
+
+	return postings
 }
 
-func (u *Updater) fetchJob(ctx context.Context, ids <-chan int, comics chan<- *domain.Comic, errs chan<- error) {
+func (u *Updater) fetchJob(ctx context.Context, ids <-chan int, results chan<- fetchResult) {
 	for {
 		select {
-		case id := <-ids:
-			if id == 404 {
-				comics <- &domain.Comic{Num: 404}
-				continue
-			}
-			comic, err := u.cp.GetById(id)
-			if err != nil {
-				errs <- err
+		case id, ok := <-ids:
+			if !ok {
 				return
 			}
-			comics <- comic
+			comic, err := u.cp.GetById(ctx, id)
+			// the collector reads results until every worker exits, so a comic fetched
+			// right before cancellation is still flushed
+			results <- fetchResult{id: id, comic: comic, err: err}
 		case <-ctx.Done():
 			return
 		}
@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"log/slog"
 	"testing"
+	"time"
 	"yadro-go/internal/adapter/secondary"
 	"yadro-go/internal/core/domain"
 	mock_service "yadro-go/internal/core/service/mocks"
@@ -20,10 +21,24 @@ func TestUpdater_Update(t *testing.T) {
 	var comic1 = &domain.Comic{Num: 1, Title: "test"}
 	var comic2 = &domain.Comic{Num: 2, Title: "test", Alt: "test_alt"}
 	var comic3 = &domain.Comic{Num: 3, Title: "test", Transcript: "test_transcript"}
-	var keyword1 = &domain.ComicKeyword{Word: "test", Nums: []int{1, 2, 3}}
-	var keyword1Limited = &domain.ComicKeyword{Word: "test", Nums: []int{1, 2}}
-	var keyword2 = &domain.ComicKeyword{Word: "test_alt", Nums: []int{2}}
-	var keyword3 = &domain.ComicKeyword{Word: "test_transcript", Nums: []int{3}}
+	var keyword1 = &domain.ComicKeyword{Word: "test", Postings: []domain.Posting{
+		{Num: 1, Freq: 1, Positions: []int{0}},
+		{Num: 2, Freq: 1, Positions: []int{0}},
+		{Num: 3, Freq: 2, Positions: []int{0, 2}},
+	}}
+	var keyword1Limited = &domain.ComicKeyword{Word: "test", Postings: []domain.Posting{
+		{Num: 1, Freq: 1, Positions: []int{0}},
+		{Num: 2, Freq: 1, Positions: []int{0}},
+	}}
+	var keyword1New = &domain.ComicKeyword{Word: "test", Postings: []domain.Posting{
+		{Num: 2, Freq: 1, Positions: []int{0}},
+		{Num: 3, Freq: 2, Positions: []int{0, 2}},
+	}}
+	var keyword2 = &domain.ComicKeyword{Word: "test_alt", Postings: []domain.Posting{{Num: 2, Freq: 1, Positions: []int{1}}}}
+	var keyword3 = &domain.ComicKeyword{Word: "test_transcript", Postings: []domain.Posting{{Num: 3, Freq: 1, Positions: []int{1}}}}
+	var lengths = map[int]int{1: 1, 2: 2, 3: 3}
+	var lengthsLimited = map[int]int{1: 1, 2: 2}
+	var lengthsNew = map[int]int{2: 2, 3: 3}
 
 	testTable := []struct {
 		name                       string
@@ -33,7 +48,7 @@ func TestUpdater_Update(t *testing.T) {
 		comicRepositoryBehaviour   func(repo *mock_service.MockComicRepository)
 		keywordRepositoryBehaviour func(repo *mock_service.MockKeywordRepository)
 		stemmerBehaviour           func(stemmer *mock_service.MockStemmer)
-		expectedCount              int
+		expectedResult             *domain.UpdateResult
 		expectedError              error
 	}{
 		{
@@ -41,136 +56,204 @@ func TestUpdater_Update(t *testing.T) {
 			parallel: 1,
 			limit:    1000,
 			comicProviderBehaviour: func(provider *mock_service.MockComicProvider) {
+				provider.EXPECT().LastNum(gomock.Any()).Return(3, nil)
 				gomock.InOrder(
-					provider.EXPECT().GetById(1).Return(comic1, nil),
-					provider.EXPECT().GetById(2).Return(comic2, nil),
-					provider.EXPECT().GetById(3).Return(comic3, nil),
-					provider.EXPECT().GetById(4).Return(nil, secondary.ErrComicNotFound),
+					provider.EXPECT().GetById(gomock.Any(), 1).Return(comic1, nil),
+					provider.EXPECT().GetById(gomock.Any(), 2).Return(comic2, nil),
+					provider.EXPECT().GetById(gomock.Any(), 3).Return(comic3, nil),
 				)
 			},
 			comicRepositoryBehaviour: func(repo *mock_service.MockComicRepository) {
 				repo.EXPECT().All(gomock.Any()).Return([]*domain.Comic{}, nil)
+				repo.EXPECT().Missing(gomock.Any()).Return([]int{}, nil)
 				repo.EXPECT().Save(gomock.Any(), gomock.InAnyOrder([]*domain.Comic{comic1, comic2, comic3})).
 					Return(nil)
 			},
 			keywordRepositoryBehaviour: func(repo *mock_service.MockKeywordRepository) {
 				repo.EXPECT().Save(gomock.Any(), matcher.KeywordPtrSliceEqual([]*domain.ComicKeyword{
 					keyword1, keyword2, keyword3,
-				})).Return(nil)
+				}), lengths).Return(nil)
 			},
 			stemmerBehaviour: func(stemmer *mock_service.MockStemmer) {
-				stemmer.EXPECT().StemComic(comic1).Return([]string{"test"})
-				stemmer.EXPECT().StemComic(comic2).Return([]string{"test", "test_alt"})
-				stemmer.EXPECT().StemComic(comic3).Return([]string{"test", "test_transcript"})
+				stemmer.EXPECT().StemComic(comic1).Return(tokens("test"))
+				stemmer.EXPECT().StemComic(comic2).Return(tokens("test", "test_alt"))
+				stemmer.EXPECT().StemComic(comic3).Return(tokens("test", "test_transcript", "test"))
 			},
-			expectedCount: 3,
-			expectedError: nil,
+			expectedResult: &domain.UpdateResult{Total: 3, New: 3},
+			expectedError:  nil,
 		},
 		{
 			name:     "SuccessParallel",
 			parallel: 2,
 			limit:    1000,
 			comicProviderBehaviour: func(provider *mock_service.MockComicProvider) {
-				provider.EXPECT().GetById(1).Return(comic1, nil)
-				provider.EXPECT().GetById(2).Return(comic2, nil)
-				provider.EXPECT().GetById(3).Return(comic3, nil)
-				provider.EXPECT().GetById(gomock.Any()).AnyTimes().Return(nil, secondary.ErrComicNotFound)
+				provider.EXPECT().LastNum(gomock.Any()).Return(3, nil)
+				provider.EXPECT().GetById(gomock.Any(), 1).Return(comic1, nil)
+				provider.EXPECT().GetById(gomock.Any(), 2).Return(comic2, nil)
+				provider.EXPECT().GetById(gomock.Any(), 3).Return(comic3, nil)
 			},
 			comicRepositoryBehaviour: func(repo *mock_service.MockComicRepository) {
 				repo.EXPECT().All(gomock.Any()).Return([]*domain.Comic{}, nil)
+				repo.EXPECT().Missing(gomock.Any()).Return([]int{}, nil)
 				repo.EXPECT().Save(gomock.Any(), gomock.InAnyOrder([]*domain.Comic{comic1, comic2, comic3})).
 					Return(nil)
 			},
 			keywordRepositoryBehaviour: func(repo *mock_service.MockKeywordRepository) {
 				repo.EXPECT().Save(gomock.Any(), matcher.KeywordPtrSliceEqual([]*domain.ComicKeyword{
 					keyword1, keyword2, keyword3,
-				})).Return(nil)
+				}), lengths).Return(nil)
 			},
 			stemmerBehaviour: func(stemmer *mock_service.MockStemmer) {
-				stemmer.EXPECT().StemComic(comic1).Return([]string{"test"})
-				stemmer.EXPECT().StemComic(comic2).Return([]string{"test", "test_alt"})
-				stemmer.EXPECT().StemComic(comic3).Return([]string{"test", "test_transcript"})
+				stemmer.EXPECT().StemComic(comic1).Return(tokens("test"))
+				stemmer.EXPECT().StemComic(comic2).Return(tokens("test", "test_alt"))
+				stemmer.EXPECT().StemComic(comic3).Return(tokens("test", "test_transcript", "test"))
 			},
-			expectedCount: 3,
-			expectedError: nil,
+			expectedResult: &domain.UpdateResult{Total: 3, New: 3},
+			expectedError:  nil,
 		},
 		{
 			name:     "SuccessOnlyNewComicsFetched",
 			parallel: 1,
 			limit:    1000,
 			comicProviderBehaviour: func(provider *mock_service.MockComicProvider) {
+				provider.EXPECT().LastNum(gomock.Any()).Return(3, nil)
 				gomock.InOrder(
-					provider.EXPECT().GetById(2).Return(comic2, nil),
-					provider.EXPECT().GetById(3).Return(comic3, nil),
-					provider.EXPECT().GetById(4).Return(nil, secondary.ErrComicNotFound),
+					provider.EXPECT().GetById(gomock.Any(), 2).Return(comic2, nil),
+					provider.EXPECT().GetById(gomock.Any(), 3).Return(comic3, nil),
 				)
 			},
 			comicRepositoryBehaviour: func(repo *mock_service.MockComicRepository) {
 				repo.EXPECT().All(gomock.Any()).Return([]*domain.Comic{comic1}, nil)
-				repo.EXPECT().Save(gomock.Any(), gomock.InAnyOrder([]*domain.Comic{comic1, comic2, comic3})).
+				repo.EXPECT().Missing(gomock.Any()).Return([]int{}, nil)
+				repo.EXPECT().Save(gomock.Any(), gomock.InAnyOrder([]*domain.Comic{comic2, comic3})).
 					Return(nil)
 			},
 			keywordRepositoryBehaviour: func(repo *mock_service.MockKeywordRepository) {
 				repo.EXPECT().Save(gomock.Any(), matcher.KeywordPtrSliceEqual([]*domain.ComicKeyword{
-					keyword1, keyword2, keyword3,
-				})).Return(nil)
+					keyword1New, keyword2, keyword3,
+				}), lengthsNew).Return(nil)
+			},
+			stemmerBehaviour: func(stemmer *mock_service.MockStemmer) {
+				stemmer.EXPECT().StemComic(comic2).Return(tokens("test", "test_alt"))
+				stemmer.EXPECT().StemComic(comic3).Return(tokens("test", "test_transcript", "test"))
+			},
+			expectedResult: &domain.UpdateResult{Total: 3, New: 2},
+			expectedError:  nil,
+		},
+		{
+			name:     "GapRecordedAsMissing",
+			parallel: 2,
+			limit:    1000,
+			comicProviderBehaviour: func(provider *mock_service.MockComicProvider) {
+				provider.EXPECT().LastNum(gomock.Any()).Return(5, nil)
+				provider.EXPECT().GetById(gomock.Any(), 2).Return(comic2, nil)
+				provider.EXPECT().GetById(gomock.Any(), 3).Return(nil, secondary.ErrComicNotFound)
+				provider.EXPECT().GetById(gomock.Any(), 5).Return(comic3, nil)
+			},
+			comicRepositoryBehaviour: func(repo *mock_service.MockComicRepository) {
+				repo.EXPECT().All(gomock.Any()).Return([]*domain.Comic{comic1}, nil)
+				repo.EXPECT().Missing(gomock.Any()).Return([]int{4}, nil)
+				repo.EXPECT().SaveMissing(gomock.Any(), []int{3}).Return(nil)
+				repo.EXPECT().Save(gomock.Any(), gomock.InAnyOrder([]*domain.Comic{comic2, comic3})).
+					Return(nil)
+			},
+			keywordRepositoryBehaviour: func(repo *mock_service.MockKeywordRepository) {
+				repo.EXPECT().Save(gomock.Any(), matcher.KeywordPtrSliceEqual([]*domain.ComicKeyword{
+					keyword1New, keyword2, keyword3,
+				}), lengthsNew).Return(nil)
 			},
 			stemmerBehaviour: func(stemmer *mock_service.MockStemmer) {
-				stemmer.EXPECT().StemComic(comic1).Return([]string{"test"})
-				stemmer.EXPECT().StemComic(comic2).Return([]string{"test", "test_alt"})
-				stemmer.EXPECT().StemComic(comic3).Return([]string{"test", "test_transcript"})
+				stemmer.EXPECT().StemComic(comic2).Return(tokens("test", "test_alt"))
+				stemmer.EXPECT().StemComic(comic3).Return(tokens("test", "test_transcript", "test"))
 			},
-			expectedCount: 3,
-			expectedError: nil,
+			expectedResult: &domain.UpdateResult{Total: 3, New: 2},
+			expectedError:  nil,
 		},
 		{
 			name:     "LimitReached",
 			parallel: 1,
 			limit:    2,
 			comicProviderBehaviour: func(provider *mock_service.MockComicProvider) {
-				provider.EXPECT().GetById(1).Return(comic1, nil)
-				provider.EXPECT().GetById(2).Return(comic2, nil)
-				provider.EXPECT().GetById(gomock.Any()).AnyTimes().Return(comic3, nil)
+				provider.EXPECT().LastNum(gomock.Any()).Return(3, nil)
+				provider.EXPECT().GetById(gomock.Any(), 1).Return(comic1, nil)
+				provider.EXPECT().GetById(gomock.Any(), 2).Return(comic2, nil)
 			},
 			comicRepositoryBehaviour: func(repo *mock_service.MockComicRepository) {
 				repo.EXPECT().All(gomock.Any()).Return([]*domain.Comic{}, nil)
+				repo.EXPECT().Missing(gomock.Any()).Return([]int{}, nil)
 				repo.EXPECT().Save(gomock.Any(), gomock.InAnyOrder([]*domain.Comic{comic1, comic2})).
 					Return(nil)
 			},
 			keywordRepositoryBehaviour: func(repo *mock_service.MockKeywordRepository) {
 				repo.EXPECT().Save(gomock.Any(), matcher.KeywordPtrSliceEqual([]*domain.ComicKeyword{
 					keyword1Limited, keyword2,
-				})).Return(nil)
+				}), lengthsLimited).Return(nil)
 			},
 			stemmerBehaviour: func(stemmer *mock_service.MockStemmer) {
-				stemmer.EXPECT().StemComic(comic1).Return([]string{"test"})
-				stemmer.EXPECT().StemComic(comic2).Return([]string{"test", "test_alt"})
+				stemmer.EXPECT().StemComic(comic1).Return(tokens("test"))
+				stemmer.EXPECT().StemComic(comic2).Return(tokens("test", "test_alt"))
 			},
-			expectedCount: 2,
-			expectedError: nil,
+			expectedResult: &domain.UpdateResult{Total: 2, New: 2},
+			expectedError:  nil,
 		},
 		{
 			name:     "NothingToUpdate",
 			parallel: 1,
 			limit:    1000,
 			comicProviderBehaviour: func(provider *mock_service.MockComicProvider) {
-				provider.EXPECT().GetById(4).Return(nil, secondary.ErrComicNotFound)
+				provider.EXPECT().LastNum(gomock.Any()).Return(4, nil)
 			},
 			comicRepositoryBehaviour: func(repo *mock_service.MockComicRepository) {
 				repo.EXPECT().All(gomock.Any()).Return([]*domain.Comic{comic1, comic2, comic3}, nil)
+				repo.EXPECT().Missing(gomock.Any()).Return([]int{4}, nil)
 			},
-			expectedCount: 3,
-			expectedError: nil,
+			keywordRepositoryBehaviour: func(repo *mock_service.MockKeywordRepository) {
+				repo.EXPECT().Stats(gomock.Any()).Return(&domain.IndexStats{Docs: 3}, nil)
+			},
+			expectedResult: &domain.UpdateResult{Total: 3},
+			expectedError:  nil,
 		},
 		{
 			name:     "NothingToUpdateLimitReached",
 			parallel: 1,
 			limit:    3,
+			comicProviderBehaviour: func(provider *mock_service.MockComicProvider) {
+				provider.EXPECT().LastNum(gomock.Any()).Return(10, nil)
+			},
+			comicRepositoryBehaviour: func(repo *mock_service.MockComicRepository) {
+				repo.EXPECT().All(gomock.Any()).Return([]*domain.Comic{comic1, comic2, comic3}, nil)
+				repo.EXPECT().Missing(gomock.Any()).Return([]int{}, nil)
+			},
+			keywordRepositoryBehaviour: func(repo *mock_service.MockKeywordRepository) {
+				repo.EXPECT().Stats(gomock.Any()).Return(&domain.IndexStats{Docs: 3}, nil)
+			},
+			expectedResult: &domain.UpdateResult{Total: 3},
+			expectedError:  nil,
+		},
+		{
+			name:     "NothingToUpdateIndexIncomplete",
+			parallel: 1,
+			limit:    3,
+			comicProviderBehaviour: func(provider *mock_service.MockComicProvider) {
+				provider.EXPECT().LastNum(gomock.Any()).Return(3, nil)
+			},
 			comicRepositoryBehaviour: func(repo *mock_service.MockComicRepository) {
 				repo.EXPECT().All(gomock.Any()).Return([]*domain.Comic{comic1, comic2, comic3}, nil)
+				repo.EXPECT().Missing(gomock.Any()).Return([]int{}, nil)
+			},
+			keywordRepositoryBehaviour: func(repo *mock_service.MockKeywordRepository) {
+				repo.EXPECT().Stats(gomock.Any()).Return(&domain.IndexStats{Docs: 0}, nil)
+				repo.EXPECT().Reindex(gomock.Any(), matcher.KeywordPtrSliceEqual([]*domain.ComicKeyword{
+					keyword1, keyword2, keyword3,
+				}), lengths).Return(nil)
 			},
-			expectedCount: 3,
-			expectedError: nil,
+			stemmerBehaviour: func(stemmer *mock_service.MockStemmer) {
+				stemmer.EXPECT().StemComic(comic1).Return(tokens("test"))
+				stemmer.EXPECT().StemComic(comic2).Return(tokens("test", "test_alt"))
+				stemmer.EXPECT().StemComic(comic3).Return(tokens("test", "test_transcript", "test"))
+			},
+			expectedResult: &domain.UpdateResult{Total: 3},
+			expectedError:  nil,
 		},
 		{
 			name:     "ComicRepositoryAllError",
@@ -181,20 +264,34 @@ func TestUpdater_Update(t *testing.T) {
 			},
 			expectedError: ErrInternal,
 		},
+		{
+			name:     "ComicProviderLastNumError",
+			parallel: 1,
+			limit:    1000,
+			comicProviderBehaviour: func(provider *mock_service.MockComicProvider) {
+				provider.EXPECT().LastNum(gomock.Any()).Return(0, secondary.ErrInternal)
+			},
+			comicRepositoryBehaviour: func(repo *mock_service.MockComicRepository) {
+				repo.EXPECT().All(gomock.Any()).Return([]*domain.Comic{comic1}, nil)
+				repo.EXPECT().Missing(gomock.Any()).Return([]int{}, nil)
+			},
+			expectedError: ErrInternal,
+		},
 		{
 			name:     "ComicRepositorySaveError",
 			parallel: 1,
 			limit:    1000,
 			comicProviderBehaviour: func(provider *mock_service.MockComicProvider) {
+				provider.EXPECT().LastNum(gomock.Any()).Return(3, nil)
 				gomock.InOrder(
-					provider.EXPECT().GetById(2).Return(comic2, nil),
-					provider.EXPECT().GetById(3).Return(comic3, nil),
-					provider.EXPECT().GetById(4).Return(nil, secondary.ErrComicNotFound),
+					provider.EXPECT().GetById(gomock.Any(), 2).Return(comic2, nil),
+					provider.EXPECT().GetById(gomock.Any(), 3).Return(comic3, nil),
 				)
 			},
 			comicRepositoryBehaviour: func(repo *mock_service.MockComicRepository) {
 				repo.EXPECT().All(gomock.Any()).Return([]*domain.Comic{comic1}, nil)
-				repo.EXPECT().Save(gomock.Any(), gomock.InAnyOrder([]*domain.Comic{comic1, comic2, comic3})).
+				repo.EXPECT().Missing(gomock.Any()).Return([]int{}, nil)
+				repo.EXPECT().Save(gomock.Any(), gomock.InAnyOrder([]*domain.Comic{comic2, comic3})).
 					Return(secondary.ErrInternal)
 			},
 			expectedError: ErrInternal,
@@ -204,42 +301,69 @@ func TestUpdater_Update(t *testing.T) {
 			parallel: 1,
 			limit:    1000,
 			comicProviderBehaviour: func(provider *mock_service.MockComicProvider) {
+				provider.EXPECT().LastNum(gomock.Any()).Return(3, nil)
 				gomock.InOrder(
-					provider.EXPECT().GetById(2).Return(comic2, nil),
-					provider.EXPECT().GetById(3).Return(comic3, nil),
-					provider.EXPECT().GetById(4).Return(nil, secondary.ErrComicNotFound),
+					provider.EXPECT().GetById(gomock.Any(), 2).Return(comic2, nil),
+					provider.EXPECT().GetById(gomock.Any(), 3).Return(comic3, nil),
 				)
 			},
 			comicRepositoryBehaviour: func(repo *mock_service.MockComicRepository) {
 				repo.EXPECT().All(gomock.Any()).Return([]*domain.Comic{comic1}, nil)
-				repo.EXPECT().Save(gomock.Any(), gomock.InAnyOrder([]*domain.Comic{comic1, comic2, comic3})).
+				repo.EXPECT().Missing(gomock.Any()).Return([]int{}, nil)
+				repo.EXPECT().Save(gomock.Any(), gomock.InAnyOrder([]*domain.Comic{comic2, comic3})).
 					Return(nil)
 			},
 			keywordRepositoryBehaviour: func(repo *mock_service.MockKeywordRepository) {
 				repo.EXPECT().Save(gomock.Any(), matcher.KeywordPtrSliceEqual([]*domain.ComicKeyword{
-					keyword1, keyword2, keyword3,
-				})).Return(secondary.ErrInternal)
+					keyword1New, keyword2, keyword3,
+				}), lengthsNew).Return(secondary.ErrInternal)
 			},
 			stemmerBehaviour: func(stemmer *mock_service.MockStemmer) {
-				stemmer.EXPECT().StemComic(comic1).Return([]string{"test"})
-				stemmer.EXPECT().StemComic(comic2).Return([]string{"test", "test_alt"})
-				stemmer.EXPECT().StemComic(comic3).Return([]string{"test", "test_transcript"})
+				stemmer.EXPECT().StemComic(comic2).Return(tokens("test", "test_alt"))
+				stemmer.EXPECT().StemComic(comic3).Return(tokens("test", "test_transcript", "test"))
 			},
 			expectedError: ErrInternal,
 		},
 		{
-			name:     "ComicProviderUnexpectedError",
+			name:     "FetchFailureReported",
 			parallel: 1,
 			limit:    1000,
 			comicProviderBehaviour: func(provider *mock_service.MockComicProvider) {
-				gomock.InOrder(
-					provider.EXPECT().GetById(2).Return(nil, secondary.ErrInternal),
-				)
+				provider.EXPECT().LastNum(gomock.Any()).Return(2, nil)
+				provider.EXPECT().GetById(gomock.Any(), 2).Return(nil, secondary.ErrInternal)
 			},
 			comicRepositoryBehaviour: func(repo *mock_service.MockComicRepository) {
 				repo.EXPECT().All(gomock.Any()).Return([]*domain.Comic{comic1}, nil)
+				repo.EXPECT().Missing(gomock.Any()).Return([]int{}, nil)
+			},
+			keywordRepositoryBehaviour: func(repo *mock_service.MockKeywordRepository) {
+				repo.EXPECT().Stats(gomock.Any()).Return(&domain.IndexStats{Docs: 1}, nil)
+			},
+			expectedResult: &domain.UpdateResult{
+				Total:    1,
+				Failures: []*domain.FetchFailure{{Num: 2, Error: secondary.ErrInternal.Error()}},
+			},
+		},
+		// a comic not cached by the offline provider may exist, it isn't recorded as missing
+		{
+			name:     "OfflineNotMissing",
+			parallel: 1,
+			limit:    1000,
+			comicProviderBehaviour: func(provider *mock_service.MockComicProvider) {
+				provider.EXPECT().LastNum(gomock.Any()).Return(2, nil)
+				provider.EXPECT().GetById(gomock.Any(), 2).Return(nil, secondary.ErrOffline)
+			},
+			comicRepositoryBehaviour: func(repo *mock_service.MockComicRepository) {
+				repo.EXPECT().All(gomock.Any()).Return([]*domain.Comic{comic1}, nil)
+				repo.EXPECT().Missing(gomock.Any()).Return([]int{}, nil)
+			},
+			keywordRepositoryBehaviour: func(repo *mock_service.MockKeywordRepository) {
+				repo.EXPECT().Stats(gomock.Any()).Return(&domain.IndexStats{Docs: 1}, nil)
+			},
+			expectedResult: &domain.UpdateResult{
+				Total:    1,
+				Failures: []*domain.FetchFailure{{Num: 2, Error: secondary.ErrOffline.Error()}},
 			},
-			expectedError: ErrInternal,
 		},
 	}
 
@@ -252,6 +376,20 @@ func TestUpdater_Update(t *testing.T) {
 			comicRepo := mock_service.NewMockComicRepository(c)
 			keywordRepo := mock_service.NewMockKeywordRepository(c)
 			stemmer := mock_service.NewMockStemmer(c)
+			runRepo := mock_service.NewMockUpdateRunRepository(c)
+
+			expectedState := domain.UpdateJobDone
+			if testCase.expectedError != nil {
+				expectedState = domain.UpdateJobFailed
+			}
+			runRepo.EXPECT().Start(gomock.Any(), gomock.Any()).Return(int64(1), nil)
+			runRepo.EXPECT().Finish(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, run *domain.UpdateRun) error {
+					assert.Equal(t, int64(1), run.Id)
+					assert.Equal(t, domain.UpdateTriggerManual, run.Trigger)
+					assert.Equal(t, expectedState, run.State)
+					return nil
+				})
 
 			if testCase.comicProviderBehaviour != nil {
 				testCase.comicProviderBehaviour(comicProvider)
@@ -266,17 +404,171 @@ func TestUpdater_Update(t *testing.T) {
 				testCase.keywordRepositoryBehaviour(keywordRepo)
 			}
 
-			u := NewUpdater(slog.New(logger.EmptyHandler{}), stemmer, comicRepo, keywordRepo, comicProvider,
+			u := NewUpdater(slog.New(logger.EmptyHandler{}), stemmer, comicRepo, keywordRepo, runRepo, comicProvider,
 				testCase.limit, testCase.parallel)
-			count, err := u.Update(context.Background())
+			result, err := u.Update(context.Background())
 			require.ErrorIs(t, err, testCase.expectedError)
 			if testCase.expectedError == nil {
-				assert.Equal(t, testCase.expectedCount, count)
+				assert.Equal(t, testCase.expectedResult.Total, result.Total)
+				assert.Equal(t, testCase.expectedResult.New, result.New)
+				assert.ElementsMatch(t, testCase.expectedResult.Failures, result.Failures)
+			}
+		})
+	}
+}
+
+func TestUpdater_UpdateBatches(t *testing.T) {
+	t.Parallel()
+
+	c := gomock.NewController(t)
+	comicProvider := mock_service.NewMockComicProvider(c)
+	comicRepo := mock_service.NewMockComicRepository(c)
+	keywordRepo := mock_service.NewMockKeywordRepository(c)
+	stemmer := mock_service.NewMockStemmer(c)
+
+	comic1 := &domain.Comic{Num: 1, Title: "test"}
+	comic2 := &domain.Comic{Num: 2, Title: "test"}
+	comic3 := &domain.Comic{Num: 3, Title: "test"}
+
+	comicProvider.EXPECT().LastNum(gomock.Any()).Return(3, nil)
+	comicProvider.EXPECT().GetById(gomock.Any(), 1).Return(comic1, nil)
+	comicProvider.EXPECT().GetById(gomock.Any(), 2).Return(comic2, nil)
+	comicProvider.EXPECT().GetById(gomock.Any(), 3).Return(comic3, nil).MaxTimes(1)
+	stemmer.EXPECT().StemComic(gomock.Any()).Return(tokens("test")).AnyTimes()
+
+	comicRepo.EXPECT().All(gomock.Any()).Return([]*domain.Comic{}, nil)
+	comicRepo.EXPECT().Missing(gomock.Any()).Return([]int{}, nil)
+	gomock.InOrder(
+		comicRepo.EXPECT().Save(gomock.Any(), []*domain.Comic{comic1}).Return(nil),
+		comicRepo.EXPECT().Save(gomock.Any(), []*domain.Comic{comic2}).Return(secondary.ErrInternal),
+	)
+	// the first batch is indexed before the second one fails
+	keywordRepo.EXPECT().Save(gomock.Any(), gomock.Any(), map[int]int{1: 1}).Return(nil)
+	runRepo := mock_service.NewMockUpdateRunRepository(c)
+	runRepo.EXPECT().Start(gomock.Any(), gomock.Any()).Return(int64(1), nil)
+	runRepo.EXPECT().Finish(gomock.Any(), gomock.Any()).Return(nil)
+
+	u := NewUpdater(slog.New(logger.EmptyHandler{}), stemmer, comicRepo, keywordRepo, runRepo, comicProvider, 1000, 1,
+		BatchSize(1))
+	_, err := u.Update(context.Background())
+	require.ErrorIs(t, err, ErrInternal)
+}
+
+func TestUpdater_UpdateRefresh(t *testing.T) {
+	t.Parallel()
+
+	comic1 := &domain.Comic{Num: 1, Title: "one"}
+	comic2 := &domain.Comic{Num: 2, Title: "two", Alt: "typo"}
+	comic2Edited := &domain.Comic{Num: 2, Title: "two", Alt: "fixed"}
+	comic3 := &domain.Comic{Num: 3, Title: "three"}
+
+	testTable := []struct {
+		name     string
+		mode     domain.UpdateMode
+		window   int
+		fetched  []*domain.Comic
+		missing  []int
+		saved    []*domain.Comic
+		lengths  map[int]int
+		expected *domain.UpdateResult
+	}{
+		{name: "Window", mode: domain.UpdateModeNew, window: 1,
+			fetched:  []*domain.Comic{comic2Edited, comic3},
+			saved:    []*domain.Comic{comic3, comic2Edited},
+			lengths:  map[int]int{2: 2, 3: 1},
+			expected: &domain.UpdateResult{Total: 3, New: 1, Updated: 1, Failures: []*domain.FetchFailure{}}},
+		{name: "WindowLargerThanStored", mode: domain.UpdateModeNew, window: 10,
+			fetched:  []*domain.Comic{comic1, comic2Edited, comic3},
+			saved:    []*domain.Comic{comic3, comic2Edited},
+			lengths:  map[int]int{2: 2, 3: 1},
+			expected: &domain.UpdateResult{Total: 3, New: 1, Updated: 1, Failures: []*domain.FetchFailure{}}},
+		{name: "Refresh", mode: domain.UpdateModeRefresh,
+			fetched:  []*domain.Comic{comic1, comic2Edited, comic3},
+			saved:    []*domain.Comic{comic3, comic2Edited},
+			lengths:  map[int]int{2: 2, 3: 1},
+			expected: &domain.UpdateResult{Total: 3, New: 1, Updated: 1, Failures: []*domain.FetchFailure{}}},
+		// comics recorded as missing are requested again
+		{name: "RefreshMissing", mode: domain.UpdateModeRefresh, missing: []int{3},
+			fetched:  []*domain.Comic{comic1, comic2Edited, comic3},
+			saved:    []*domain.Comic{comic3, comic2Edited},
+			lengths:  map[int]int{2: 2, 3: 1},
+			expected: &domain.UpdateResult{Total: 3, New: 1, Updated: 1, Failures: []*domain.FetchFailure{}}},
+		// nothing is saved when stored comics haven't changed
+		{name: "Unchanged", mode: domain.UpdateModeRefresh,
+			fetched:  []*domain.Comic{comic1, comic2},
+			expected: &domain.UpdateResult{Total: 2, Failures: []*domain.FetchFailure{}}},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := gomock.NewController(t)
+			comicProvider := mock_service.NewMockComicProvider(c)
+			comicRepo := mock_service.NewMockComicRepository(c)
+			keywordRepo := mock_service.NewMockKeywordRepository(c)
+			runRepo := mock_service.NewMockUpdateRunRepository(c)
+			stemmer := mock_service.NewMockStemmer(c)
+
+			comicProvider.EXPECT().LastNum(gomock.Any()).Return(testCase.fetched[len(testCase.fetched)-1].Num, nil)
+			for _, comic := range testCase.fetched {
+				comicProvider.EXPECT().GetById(gomock.Any(), comic.Num).Return(comic, nil)
 			}
+			comicRepo.EXPECT().All(gomock.Any()).Return([]*domain.Comic{comic1, comic2}, nil)
+			comicRepo.EXPECT().Missing(gomock.Any()).Return(testCase.missing, nil)
+			if testCase.saved != nil {
+				comicRepo.EXPECT().Save(gomock.Any(), gomock.InAnyOrder(testCase.saved)).Return(nil)
+				// postings of the edited comic are replaced
+				keywordRepo.EXPECT().Save(gomock.Any(), gomock.Any(), testCase.lengths).Return(nil)
+				stemmer.EXPECT().StemComic(comic3).Return(tokens("three"))
+				stemmer.EXPECT().StemComic(comic2Edited).Return(tokens("two", "fixed"))
+			} else {
+				keywordRepo.EXPECT().Stats(gomock.Any()).Return(&domain.IndexStats{Docs: 2}, nil)
+			}
+			runRepo.EXPECT().Start(gomock.Any(), gomock.Any()).Return(int64(1), nil)
+			runRepo.EXPECT().Finish(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run *domain.UpdateRun) error {
+				assert.Equal(t, testCase.mode, run.Mode)
+				assert.Equal(t, testCase.expected.Updated, run.Updated)
+				return nil
+			})
+
+			u := NewUpdater(slog.New(logger.EmptyHandler{}), stemmer, comicRepo, keywordRepo, runRepo, comicProvider,
+				1000, 1, RefreshWindow(testCase.window))
+			job, err := u.StartUpdate(context.Background(), domain.UpdateTriggerManual, testCase.mode, "admin")
+			require.NoError(t, err)
+
+			require.Eventually(t, func() bool {
+				job, err = u.UpdateJob(job.Id)
+				return err == nil && job.State != domain.UpdateJobRunning
+			}, time.Second, time.Millisecond)
+			require.Equal(t, domain.UpdateJobDone, job.State)
+			assert.Equal(t, testCase.expected, job.Result)
 		})
 	}
 }
 
+func TestRefreshIds(t *testing.T) {
+	t.Parallel()
+
+	comics := map[int]*domain.Comic{1: {Num: 1}, 3: {Num: 3}, 4: {Num: 4}}
+	assert.Equal(t, []int{}, refreshIds(comics, domain.UpdateModeNew, 0))
+	assert.Equal(t, []int{3, 4}, refreshIds(comics, domain.UpdateModeNew, 2))
+	assert.Equal(t, []int{1, 3, 4}, refreshIds(comics, domain.UpdateModeNew, 5))
+	assert.Equal(t, []int{1, 3, 4}, refreshIds(comics, domain.UpdateModeRefresh, 0))
+}
+
+func TestContentHash(t *testing.T) {
+	t.Parallel()
+
+	comic := &domain.Comic{Num: 1, Title: "title", Alt: "alt", Transcript: "transcript", Img: "img"}
+	assert.Equal(t, contentHash(comic), contentHash(&domain.Comic{Num: 1, Title: "title", Alt: "alt",
+		Transcript: "transcript", Img: "img"}))
+	assert.NotEqual(t, contentHash(comic), contentHash(&domain.Comic{Num: 1, Title: "title", Alt: "alt",
+		Transcript: "transcript", Img: "img2"}))
+	// text moved between fields changes the hash
+	assert.NotEqual(t, contentHash(&domain.Comic{Title: "ab"}), contentHash(&domain.Comic{Title: "a", Alt: "b"}))
+}
+
 func TestUpdater_UpdateInProgress(t *testing.T) {
 	t.Parallel()
 
@@ -299,8 +591,11 @@ func TestUpdater_UpdateInProgress(t *testing.T) {
 			}
 		}
 	})
+	runRepo := mock_service.NewMockUpdateRunRepository(c)
+	runRepo.EXPECT().Start(gomock.Any(), gomock.Any()).Return(int64(1), nil)
+	runRepo.EXPECT().Finish(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 
-	u := NewUpdater(slog.New(logger.EmptyHandler{}), stemmer, comicRepo, keywordRepo, comicProvider, 1000, 1)
+	u := NewUpdater(slog.New(logger.EmptyHandler{}), stemmer, comicRepo, keywordRepo, runRepo, comicProvider, 1000, 1)
 	go func() {
 		_, _ = u.Update(ctx)
 	}()
@@ -311,6 +606,85 @@ func TestUpdater_UpdateInProgress(t *testing.T) {
 	cancel()
 }
 
+func TestUpdater_FetchCancelled(t *testing.T) {
+	t.Parallel()
+
+	c := gomock.NewController(t)
+	comicProvider := mock_service.NewMockComicProvider(c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the update is cancelled while comic 1 is fetched, the fetched comic is still flushed
+	comic := &domain.Comic{Num: 1, Title: "title"}
+	comicProvider.EXPECT().GetById(gomock.Any(), 1).DoAndReturn(func(context.Context, int) (*domain.Comic, error) {
+		cancel()
+		return comic, nil
+	})
+
+	u := NewUpdater(slog.New(logger.EmptyHandler{}), nil, nil, nil, nil, comicProvider, 1000, 1)
+
+	flushed := make([]*domain.Comic, 0)
+	failures, err := u.fetch(ctx, &updateJob{}, []int{1}, func(comics []*domain.Comic, _ []int) error {
+		flushed = append(flushed, comics...)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Empty(t, failures)
+	assert.Equal(t, []*domain.Comic{comic}, flushed)
+}
+
+func TestUpdater_Reindex(t *testing.T) {
+	t.Parallel()
+
+	c := gomock.NewController(t)
+	comicRepo := mock_service.NewMockComicRepository(c)
+	keywordRepo := mock_service.NewMockKeywordRepository(c)
+	stemmer := mock_service.NewMockStemmer(c)
+
+	comic1 := &domain.Comic{Num: 1, Title: "test"}
+	comic2 := &domain.Comic{Num: 2, Title: "test"}
+	comicRepo.EXPECT().All(gomock.Any()).Return([]*domain.Comic{comic1, comic2}, nil)
+	keywordRepo.EXPECT().Reindex(gomock.Any(), matcher.KeywordPtrSliceEqual([]*domain.ComicKeyword{{
+		Word:     "test",
+		Postings: []domain.Posting{{Num: 1, Freq: 1, Positions: []int{0}}, {Num: 2, Freq: 1, Positions: []int{0}}},
+	}}), map[int]int{1: 1, 2: 1}).Return(nil)
+	stemmer.EXPECT().StemComic(comic1).Return(tokens("test"))
+	stemmer.EXPECT().StemComic(comic2).Return(tokens("test"))
+
+	u := NewUpdater(slog.New(logger.EmptyHandler{}), stemmer, comicRepo, keywordRepo, nil, nil, 1000, 1)
+	count, err := u.Reindex(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestUpdater_ReindexIfIncomplete(t *testing.T) {
+	t.Parallel()
+
+	c := gomock.NewController(t)
+	comicRepo := mock_service.NewMockComicRepository(c)
+	keywordRepo := mock_service.NewMockKeywordRepository(c)
+	stemmer := mock_service.NewMockStemmer(c)
+
+	comic := &domain.Comic{Num: 1, Title: "test"}
+	comicRepo.EXPECT().All(gomock.Any()).Return([]*domain.Comic{comic}, nil).Times(2)
+	gomock.InOrder(
+		keywordRepo.EXPECT().Stats(gomock.Any()).Return(&domain.IndexStats{}, nil),
+		keywordRepo.EXPECT().Reindex(gomock.Any(), []*domain.ComicKeyword{{
+			Word:     "test",
+			Postings: []domain.Posting{{Num: 1, Freq: 1, Positions: []int{0}, Surfaces: map[string]int{"test": 1}}},
+			Surfaces: map[string]int{"test": 1},
+		}}, map[int]int{1: 1}).Return(nil),
+		keywordRepo.EXPECT().Stats(gomock.Any()).Return(&domain.IndexStats{Docs: 1, AvgLength: 1, MaxNum: 1}, nil),
+	)
+	stemmer.EXPECT().StemComic(comic).Return([]domain.Token{{Stem: "test", Word: "test"}})
+
+	u := NewUpdater(slog.New(logger.EmptyHandler{}), stemmer, comicRepo, keywordRepo, nil, nil, 1000, 1)
+	require.NoError(t, u.ReindexIfIncomplete(context.Background()))
+	// index is complete now, nothing is saved again
+	require.NoError(t, u.ReindexIfIncomplete(context.Background()))
+}
+
 func TestUpdater_StartSchedulerNotPanic(t *testing.T) {
 	t.Parallel()
 
@@ -322,6 +696,227 @@ func TestUpdater_StartSchedulerNotPanic(t *testing.T) {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
-	u := NewUpdater(slog.New(logger.EmptyHandler{}), stemmer, comicRepo, keywordRepo, comicProvider, 1000, 1)
-	assert.NotPanics(t, func() { u.StartScheduler(ctx, 0, 0) })
+	u := NewUpdater(slog.New(logger.EmptyHandler{}), stemmer, comicRepo, keywordRepo, nil, comicProvider, 1000, 1)
+	assert.NotPanics(t, func() { u.StartScheduler(ctx, Schedule{Location: time.UTC}) })
+}
+
+func tokens(stems ...string) []domain.Token {
+	res := make([]domain.Token, len(stems))
+	for i, stem := range stems {
+		res[i] = domain.Token{Stem: stem, Pos: i}
+	}
+	return res
+}
+
+func TestUpdater_StartUpdate(t *testing.T) {
+	t.Parallel()
+
+	c := gomock.NewController(t)
+	comicProvider := mock_service.NewMockComicProvider(c)
+	comicRepo := mock_service.NewMockComicRepository(c)
+	keywordRepo := mock_service.NewMockKeywordRepository(c)
+	stemmer := mock_service.NewMockStemmer(c)
+
+	comic1 := &domain.Comic{Num: 1, Title: "test"}
+
+	comicProvider.EXPECT().LastNum(gomock.Any()).Return(2, nil)
+	comicProvider.EXPECT().GetById(gomock.Any(), 1).Return(comic1, nil)
+	comicProvider.EXPECT().GetById(gomock.Any(), 2).Return(nil, secondary.ErrInternal)
+	stemmer.EXPECT().StemComic(comic1).Return(tokens("test"))
+	comicRepo.EXPECT().All(gomock.Any()).Return([]*domain.Comic{}, nil)
+	comicRepo.EXPECT().Missing(gomock.Any()).Return([]int{}, nil)
+	comicRepo.EXPECT().Save(gomock.Any(), []*domain.Comic{comic1}).Return(nil)
+	keywordRepo.EXPECT().Save(gomock.Any(), gomock.Any(), map[int]int{1: 1}).Return(nil)
+	runRepo := mock_service.NewMockUpdateRunRepository(c)
+	runRepo.EXPECT().Start(gomock.Any(), gomock.Any()).Return(int64(3), nil)
+	runRepo.EXPECT().Finish(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run *domain.UpdateRun) error {
+		assert.False(t, run.Finished.Before(run.Started))
+		run.Started, run.Finished = time.Time{}, time.Time{}
+		assert.Equal(t, &domain.UpdateRun{
+			Id:       3,
+			Trigger:  domain.UpdateTriggerManual,
+			Mode:     domain.UpdateModeNew,
+			Username: "admin",
+			State:    domain.UpdateJobDone,
+			New:      1,
+			Failed:   1,
+		}, run)
+		return nil
+	})
+
+	u := NewUpdater(slog.New(logger.EmptyHandler{}), stemmer, comicRepo, keywordRepo, runRepo, comicProvider, 1000, 1)
+	_, events, unsubscribe := u.SubscribeEvents(0)
+	defer unsubscribe()
+
+	job, err := u.StartUpdate(context.Background(), domain.UpdateTriggerManual, domain.UpdateModeNew, "admin")
+	require.NoError(t, err)
+	assert.Equal(t, domain.UpdateJobRunning, job.State)
+	assert.Equal(t, int64(3), job.Id)
+
+	require.Eventually(t, func() bool {
+		job, err = u.UpdateJob(job.Id)
+		return err == nil && job.State != domain.UpdateJobRunning
+	}, time.Second, time.Millisecond)
+
+	assert.Equal(t, domain.UpdateJobDone, job.State)
+	assert.Equal(t, 2, job.Total)
+	assert.Equal(t, 1, job.Fetched)
+	assert.Equal(t, 1, job.Failed)
+	assert.Equal(t, []string{"comic 2: internal error"}, job.Errors)
+	assert.False(t, job.Finished.IsZero())
+	assert.Equal(t, 1, job.Result.New)
+
+	_, err = u.UpdateJob(job.Id + 1)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	// fetch events come in the order of results, the rest follow them
+	received := make([]*domain.UpdateEvent, 0)
+	for event := range events {
+		received = append(received, event)
+		if event.Type == domain.UpdateEventCompleted {
+			break
+		}
+	}
+	require.Len(t, received, 6)
+	assert.ElementsMatch(t, []domain.UpdateEventType{domain.UpdateEventComicFetched, domain.UpdateEventComicFailed},
+		[]domain.UpdateEventType{received[0].Type, received[1].Type})
+	assert.Equal(t, domain.UpdateEventBatchSaved, received[2].Type)
+	assert.Equal(t, domain.UpdateEventIndexingStarted, received[3].Type)
+	assert.Equal(t, domain.UpdateEventIndexingFinished, received[4].Type)
+	assert.Equal(t, &domain.UpdateEvent{
+		Id:    6,
+		Type:  domain.UpdateEventCompleted,
+		JobId: job.Id,
+		Time:  received[5].Time,
+		Count: 1,
+		State: domain.UpdateJobDone,
+	}, received[5])
+}
+
+func TestUpdater_CancelUpdate(t *testing.T) {
+	t.Parallel()
+
+	c := gomock.NewController(t)
+	comicProvider := mock_service.NewMockComicProvider(c)
+	comicRepo := mock_service.NewMockComicRepository(c)
+	keywordRepo := mock_service.NewMockKeywordRepository(c)
+	stemmer := mock_service.NewMockStemmer(c)
+
+	started := make(chan struct{})
+	comicProvider.EXPECT().LastNum(gomock.Any()).Return(1, nil)
+	comicProvider.EXPECT().GetById(gomock.Any(), 1).DoAndReturn(func(ctx context.Context, _ int) (*domain.Comic, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, secondary.ErrInternal
+	})
+	comicRepo.EXPECT().All(gomock.Any()).Return([]*domain.Comic{}, nil).Times(2)
+	comicRepo.EXPECT().Missing(gomock.Any()).Return([]int{}, nil)
+	keywordRepo.EXPECT().Stats(gomock.Any()).Return(&domain.IndexStats{}, nil)
+	keywordRepo.EXPECT().Reindex(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	runRepo := mock_service.NewMockUpdateRunRepository(c)
+	runRepo.EXPECT().Start(gomock.Any(), gomock.Any()).Return(int64(1), nil)
+	runRepo.EXPECT().Finish(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run *domain.UpdateRun) error {
+		assert.Equal(t, domain.UpdateJobCancelled, run.State)
+		return nil
+	})
+
+	u := NewUpdater(slog.New(logger.EmptyHandler{}), stemmer, comicRepo, keywordRepo, runRepo, comicProvider, 1000, 1)
+	job, err := u.StartUpdate(context.Background(), domain.UpdateTriggerScheduler, domain.UpdateModeNew, "")
+	require.NoError(t, err)
+	<-started
+
+	_, err = u.StartUpdate(context.Background(), domain.UpdateTriggerManual, domain.UpdateModeNew, "admin")
+	require.ErrorIs(t, err, ErrUpdateInProgress)
+
+	job, err = u.CancelUpdate(job.Id)
+	require.NoError(t, err)
+	assert.Equal(t, domain.UpdateJobCancelled, job.State)
+	assert.Equal(t, 0, job.Failed)
+
+	// the lock is released with the cancelled job
+	_, err = u.Reindex(context.Background())
+	require.NoError(t, err)
+}
+
+func TestUpdater_Shutdown(t *testing.T) {
+	t.Parallel()
+
+	c := gomock.NewController(t)
+	comicProvider := mock_service.NewMockComicProvider(c)
+	comicRepo := mock_service.NewMockComicRepository(c)
+
+	started := make(chan struct{})
+	comicProvider.EXPECT().LastNum(gomock.Any()).Return(1, nil)
+	comicProvider.EXPECT().GetById(gomock.Any(), 1).DoAndReturn(func(ctx context.Context, _ int) (*domain.Comic, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, secondary.ErrInternal
+	})
+	comicRepo.EXPECT().All(gomock.Any()).Return([]*domain.Comic{}, nil)
+	comicRepo.EXPECT().Missing(gomock.Any()).Return([]int{}, nil)
+	keywordRepo := mock_service.NewMockKeywordRepository(c)
+	keywordRepo.EXPECT().Stats(gomock.Any()).Return(&domain.IndexStats{}, nil)
+	runRepo := mock_service.NewMockUpdateRunRepository(c)
+	runRepo.EXPECT().Start(gomock.Any(), gomock.Any()).Return(int64(1), nil)
+	runRepo.EXPECT().Finish(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run *domain.UpdateRun) error {
+		assert.Equal(t, domain.UpdateJobCancelled, run.State)
+		return nil
+	})
+
+	u := NewUpdater(slog.New(logger.EmptyHandler{}), nil, comicRepo, keywordRepo, runRepo, comicProvider, 1000, 1)
+
+	// the job outlives the context it has been started with
+	ctx, cancel := context.WithCancel(context.Background())
+	job, err := u.StartUpdate(ctx, domain.UpdateTriggerManual, domain.UpdateModeNew, "admin")
+	require.NoError(t, err)
+	cancel()
+	<-started
+
+	require.NoError(t, u.Shutdown(context.Background()))
+	job, err = u.UpdateJob(job.Id)
+	require.NoError(t, err)
+	assert.Equal(t, domain.UpdateJobCancelled, job.State)
+
+	_, err = u.StartUpdate(context.Background(), domain.UpdateTriggerManual, domain.UpdateModeNew, "admin")
+	require.ErrorIs(t, err, ErrUpdateInProgress)
+}
+
+func TestUpdater_UpdateRuns(t *testing.T) {
+	t.Parallel()
+
+	c := gomock.NewController(t)
+	runRepo := mock_service.NewMockUpdateRunRepository(c)
+
+	runs := []*domain.UpdateRun{{Id: 9}, {Id: 8}, {Id: 7}}
+	runRepo.EXPECT().Runs(gomock.Any(), 3, int64(10)).Return(runs, nil)
+	runRepo.EXPECT().Runs(gomock.Any(), 3, int64(8)).Return(runs[2:], nil)
+
+	u := NewUpdater(slog.New(logger.EmptyHandler{}), nil, nil, nil, runRepo, nil, 1000, 1)
+
+	page, next, err := u.UpdateRuns(context.Background(), 2, 10)
+	require.NoError(t, err)
+	assert.Equal(t, runs[:2], page)
+	assert.Equal(t, int64(8), next)
+
+	page, next, err = u.UpdateRuns(context.Background(), 2, next)
+	require.NoError(t, err)
+	assert.Equal(t, runs[2:], page)
+	assert.Zero(t, next)
+}
+
+func TestUpdater_StartUpdateRunError(t *testing.T) {
+	t.Parallel()
+
+	c := gomock.NewController(t)
+	runRepo := mock_service.NewMockUpdateRunRepository(c)
+	runRepo.EXPECT().Start(gomock.Any(), gomock.Any()).Return(int64(0), secondary.ErrInternal).Times(2)
+
+	u := NewUpdater(slog.New(logger.EmptyHandler{}), nil, nil, nil, runRepo, nil, 1000, 1)
+
+	_, err := u.StartUpdate(context.Background(), domain.UpdateTriggerManual, domain.UpdateModeNew, "admin")
+	require.ErrorIs(t, err, ErrInternal)
+
+	// the lock is released
+	_, err = u.StartUpdate(context.Background(), domain.UpdateTriggerManual, domain.UpdateModeNew, "admin")
+	require.ErrorIs(t, err, ErrInternal)
 }
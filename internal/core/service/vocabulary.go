@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"yadro-go/internal/core/domain"
+	"yadro-go/pkg/bktree"
+	"yadro-go/pkg/logger"
+)
+
+const (
+	// fuzzyPenalty is a score multiplier applied for every edit of a fuzzy match
+	fuzzyPenalty     = 0.5
+	maxFuzzyVariants = 16
+)
+
+// vocabulary holds distinct indexed words, built at a specific index generation.
+type vocabulary struct {
+	generation int64
+	tree       *bktree.Tree
+	freqs      map[string]int
+	// surfaces maps a stem to its most common original word
+	surfaces map[string]string
+}
+
+func newVocabulary(generation int64, words []*domain.VocabularyWord) *vocabulary {
+	v := &vocabulary{
+		generation: generation,
+		tree:       bktree.New(),
+		freqs:      make(map[string]int, len(words)),
+		surfaces:   make(map[string]string, len(words)),
+	}
+
+	for _, word := range words {
+		v.tree.Add(word.Word)
+		v.freqs[word.Word] = word.DocFreq
+		v.surfaces[word.Word] = word.Surface
+	}
+
+	return v
+}
+
+// similar returns indexed words within allowed edit distance of the word, closest and most frequent first.
+func (v *vocabulary) similar(word string, maxDistance int) []bktree.Match {
+	matches := v.tree.Search(word, fuzzyDistance(word, maxDistance))
+	slices.SortFunc(matches, func(a, b bktree.Match) int {
+		if a.Distance != b.Distance {
+			return a.Distance - b.Distance
+		}
+		return v.freqs[b.Word] - v.freqs[a.Word]
+	})
+
+	if len(matches) > maxFuzzyVariants {
+		matches = matches[:maxFuzzyVariants]
+	}
+	return matches
+}
+
+// corrections returns up to n indexed words that could be meant instead of the word,
+// ranked by document frequency penalized for every edit.
+func (v *vocabulary) corrections(word string, maxDistance int, n int) []string {
+	matches := v.tree.Search(word, fuzzyDistance(word, maxDistance))
+	weight := func(m bktree.Match) float64 {
+		return float64(v.freqs[m.Word]) * math.Pow(fuzzyPenalty, float64(m.Distance))
+	}
+	slices.SortFunc(matches, func(a, b bktree.Match) int {
+		if wa, wb := weight(a), weight(b); wa != wb {
+			if wb > wa {
+				return 1
+			}
+			return -1
+		}
+		return strings.Compare(a.Word, b.Word)
+	})
+
+	res := make([]string, 0, n)
+	for _, match := range matches {
+		if len(res) == n {
+			break
+		}
+		if match.Distance > 0 {
+			res = append(res, match.Word)
+		}
+	}
+	return res
+}
+
+// surface returns a word to show instead of the stem.
+func (v *vocabulary) surface(stem string) string {
+	if surface := v.surfaces[stem]; len(surface) > 0 {
+		return surface
+	}
+	return stem
+}
+
+// fuzzyDistance limits edit distance for short words, so that they are not expanded to unrelated ones.
+func fuzzyDistance(word string, maxDistance int) int {
+	return min(maxDistance, (utf8.RuneCountInString(word)-1)/3)
+}
+
+// vocabulary returns vocabulary of the current index, it is rebuilt when number of indexed comics changes.
+func (s *Scanner) vocabulary(ctx context.Context) (*vocabulary, error) {
+	const op = "scanner.vocabulary"
+	log := s.log.With(slog.String("op", op))
+
+	stats, err := s.keywordRepo.Stats(ctx)
+	if err != nil {
+		log.Error("failed to get index stats", logger.Err(err))
+		return nil, err
+	}
+
+	s.vocabMu.Lock()
+	defer s.vocabMu.Unlock()
+
+	// any write changes the generation, also the one which changes words of already indexed comics
+	if s.vocab != nil && s.vocab.generation == stats.Generation {
+		return s.vocab, nil
+	}
+
+	start := time.Now()
+	words, err := s.keywordRepo.Vocabulary(ctx)
+	if err != nil {
+		log.Error("failed to get vocabulary", logger.Err(err))
+		return nil, err
+	}
+
+	s.vocab = newVocabulary(stats.Generation, words)
+	log.Debug(fmt.Sprintf("vocabulary of %d words built in %v", len(words), time.Since(start)))
+
+	return s.vocab, nil
+}
+
+// expandFuzzy adds similar indexed words as weighted variants of single-stem terms. Phrases are matched exactly.
+func expandFuzzy(node queryNode, vocab *vocabulary, maxDistance int) {
+	switch n := node.(type) {
+	case *queryPhrase:
+		if len(n.tokens) != 1 {
+			return
+		}
+
+		for _, match := range vocab.similar(n.tokens[0].Stem, maxDistance) {
+			if match.Distance == 0 {
+				continue
+			}
+			if n.variants == nil {
+				n.variants = make(map[string]float64)
+			}
+			n.variants[match.Word] = math.Pow(fuzzyPenalty, float64(match.Distance))
+		}
+	case *queryBool:
+		for _, nodes := range [][]queryNode{n.must, n.should, n.mustNot} {
+			for _, child := range nodes {
+				expandFuzzy(child, vocab, maxDistance)
+			}
+		}
+	}
+}
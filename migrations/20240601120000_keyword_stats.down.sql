@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS comic_stats;
+ALTER TABLE keywords DROP COLUMN freq;
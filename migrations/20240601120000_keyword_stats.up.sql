@@ -0,0 +1,6 @@
+ALTER TABLE keywords ADD COLUMN freq INTEGER NOT NULL DEFAULT 1;
+CREATE TABLE IF NOT EXISTS comic_stats(
+    num    INTEGER PRIMARY KEY,
+    length INTEGER,
+    FOREIGN KEY (num) REFERENCES comics(num)
+);
@@ -0,0 +1 @@
+ALTER TABLE keywords ADD COLUMN positions TEXT NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE keywords DROP COLUMN field;
@@ -0,0 +1,3 @@
+-- postings indexed before fields are kept as transcript ones, so that search works until the comics are indexed again
+ALTER TABLE keywords ADD COLUMN field TEXT NOT NULL DEFAULT '';
+UPDATE keywords SET field = 'transcript';
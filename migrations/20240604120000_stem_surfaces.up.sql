@@ -0,0 +1,12 @@
+-- surface words are counted per comic, so that counts of a changed comic are replaced and not added up
+CREATE TABLE IF NOT EXISTS stem_surfaces(
+    word    TEXT    NOT NULL,
+    num     INTEGER NOT NULL,
+    surface TEXT    NOT NULL,
+    count   INTEGER NOT NULL,
+    PRIMARY KEY (word, num, surface)
+) WITHOUT ROWID;
+CREATE INDEX IF NOT EXISTS stem_surfaces_num ON stem_surfaces(num);
+-- counts of the stored comics are unknown, the incomplete index is rebuilt from them
+DELETE FROM keywords;
+DELETE FROM comic_stats;
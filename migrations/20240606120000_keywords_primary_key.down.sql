@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS keywords_num;
+CREATE TABLE keywords_old(
+    word      TEXT,
+    num       INTEGER,
+    freq      INTEGER NOT NULL DEFAULT 1,
+    positions TEXT    NOT NULL DEFAULT '',
+    field     TEXT    NOT NULL DEFAULT '',
+    FOREIGN KEY (num) REFERENCES comics(num)
+);
+INSERT INTO keywords_old(word, num, freq, positions, field) SELECT word, num, tf, positions, field FROM keywords;
+DROP TABLE keywords;
+ALTER TABLE keywords_old RENAME TO keywords;
@@ -0,0 +1,19 @@
+-- one row per posting, the latest of repeated rows is kept; the table is clustered by the primary key,
+-- which serves lookups by word, and keywords_num serves replacing postings of a comic
+CREATE TABLE keywords_new(
+    word      TEXT    NOT NULL,
+    num       INTEGER NOT NULL,
+    field     TEXT    NOT NULL DEFAULT '',
+    tf        INTEGER NOT NULL DEFAULT 1,
+    positions TEXT    NOT NULL DEFAULT '',
+    PRIMARY KEY (word, num, field),
+    FOREIGN KEY (num) REFERENCES comics(num)
+) WITHOUT ROWID;
+INSERT INTO keywords_new(word, num, field, tf, positions)
+SELECT word, num, field, freq, positions FROM keywords
+WHERE rowid IN (
+    SELECT MAX(rowid) FROM keywords WHERE word IS NOT NULL AND num IS NOT NULL GROUP BY word, num, field
+);
+DROP TABLE keywords;
+ALTER TABLE keywords_new RENAME TO keywords;
+CREATE INDEX IF NOT EXISTS keywords_num ON keywords(num);
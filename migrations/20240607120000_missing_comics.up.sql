@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS missing_comics(
+    num INTEGER PRIMARY KEY
+);
+-- comic 404 used to be stored as an empty placeholder
+INSERT OR IGNORE INTO missing_comics(num) SELECT num FROM comics WHERE num = 404 AND COALESCE(img, '') = '';
+DELETE FROM keywords WHERE num IN (SELECT num FROM missing_comics);
+DELETE FROM comic_stats WHERE num IN (SELECT num FROM missing_comics);
+DELETE FROM comics WHERE num IN (SELECT num FROM missing_comics);
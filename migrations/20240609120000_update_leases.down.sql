@@ -0,0 +1,2 @@
+ALTER TABLE update_runs DROP COLUMN holder;
+DROP TABLE IF EXISTS update_leases;
@@ -0,0 +1,9 @@
+-- times are unix milliseconds, so that instances compare them as numbers
+CREATE TABLE IF NOT EXISTS update_leases(
+    name TEXT PRIMARY KEY,
+    holder TEXT NOT NULL,
+    expires_at INTEGER NOT NULL,
+    heartbeat_at INTEGER NOT NULL
+);
+-- runs are recorded with the instance running them, so that only runs of a stopped one are interrupted
+ALTER TABLE update_runs ADD COLUMN holder TEXT NOT NULL DEFAULT '';
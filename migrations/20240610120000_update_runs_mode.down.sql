@@ -0,0 +1 @@
+ALTER TABLE update_runs DROP COLUMN mode;
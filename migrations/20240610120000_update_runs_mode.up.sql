@@ -0,0 +1 @@
+ALTER TABLE update_runs ADD COLUMN mode TEXT NOT NULL DEFAULT 'new';
@@ -0,0 +1,7 @@
+-- the generation is bumped by every write to the index, so that a copy of the index,
+-- like a snapshot file or the memory of another instance, can tell it is outdated
+CREATE TABLE IF NOT EXISTS index_generation(
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    generation INTEGER NOT NULL
+);
+INSERT OR IGNORE INTO index_generation(id, generation) VALUES (1, 0);
@@ -0,0 +1 @@
+ALTER TABLE comic_stats DROP COLUMN generation;
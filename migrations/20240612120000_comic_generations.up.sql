@@ -0,0 +1,2 @@
+-- index generation a comic was first indexed at, search pages skip comics indexed after their first page
+ALTER TABLE comic_stats ADD COLUMN generation INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS comics_fts_update;
+DROP TRIGGER IF EXISTS comics_fts_delete;
+DROP TRIGGER IF EXISTS comics_fts_insert;
+DROP TABLE IF EXISTS comics_fts;
@@ -0,0 +1,21 @@
+CREATE VIRTUAL TABLE IF NOT EXISTS comics_fts USING fts5(
+    title,
+    alt,
+    transcript,
+    content = 'comics',
+    content_rowid = 'num',
+    tokenize = 'porter unicode61'
+);
+INSERT INTO comics_fts(comics_fts) VALUES ('rebuild');
+CREATE TRIGGER IF NOT EXISTS comics_fts_insert AFTER INSERT ON comics BEGIN
+    INSERT INTO comics_fts(rowid, title, alt, transcript) VALUES (new.num, new.title, new.alt, new.transcript);
+END;
+CREATE TRIGGER IF NOT EXISTS comics_fts_delete AFTER DELETE ON comics BEGIN
+    INSERT INTO comics_fts(comics_fts, rowid, title, alt, transcript)
+    VALUES ('delete', old.num, old.title, old.alt, old.transcript);
+END;
+CREATE TRIGGER IF NOT EXISTS comics_fts_update AFTER UPDATE ON comics BEGIN
+    INSERT INTO comics_fts(comics_fts, rowid, title, alt, transcript)
+    VALUES ('delete', old.num, old.title, old.alt, old.transcript);
+    INSERT INTO comics_fts(rowid, title, alt, transcript) VALUES (new.num, new.title, new.alt, new.transcript);
+END;
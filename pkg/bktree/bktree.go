@@ -0,0 +1,108 @@
+package bktree
+
+// Tree is a Burkhard-Keller tree of words for lookups by Levenshtein distance.
+// Tree is not safe for concurrent modification, concurrent Search calls are allowed.
+type Tree struct {
+	root *node
+	size int
+}
+
+type node struct {
+	word     string
+	children map[int]*node
+}
+
+type Match struct {
+	Word     string
+	Distance int
+}
+
+func New() *Tree {
+	return &Tree{}
+}
+
+func (t *Tree) Len() int {
+	return t.size
+}
+
+func (t *Tree) Add(word string) {
+	if t.root == nil {
+		t.root = &node{word: word}
+		t.size++
+		return
+	}
+
+	cur := t.root
+	for {
+		d := Distance(cur.word, word)
+		if d == 0 {
+			return
+		}
+
+		child, ok := cur.children[d]
+		if !ok {
+			if cur.children == nil {
+				cur.children = make(map[int]*node)
+			}
+			cur.children[d] = &node{word: word}
+			t.size++
+			return
+		}
+		cur = child
+	}
+}
+
+// Search returns all words within maxDistance edits of the word.
+func (t *Tree) Search(word string, maxDistance int) []Match {
+	res := make([]Match, 0)
+	if t.root == nil {
+		return res
+	}
+
+	stack := []*node{t.root}
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		d := Distance(cur.word, word)
+		if d <= maxDistance {
+			res = append(res, Match{Word: cur.word, Distance: d})
+		}
+
+		for childDistance, child := range cur.children {
+			if childDistance >= d-maxDistance && childDistance <= d+maxDistance {
+				stack = append(stack, child)
+			}
+		}
+	}
+
+	return res
+}
+
+// Distance calculates Levenshtein distance between two strings.
+func Distance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) < len(rb) {
+		ra, rb = rb, ra
+	}
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)]
+}
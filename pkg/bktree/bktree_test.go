@@ -0,0 +1,45 @@
+package bktree
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		a        string
+		b        string
+		expected int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "abc", 0},
+		{"recus", "recurs", 1},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"привет", "превед", 2},
+	}
+
+	for _, testCase := range testTable {
+		assert.Equal(t, testCase.expected, Distance(testCase.a, testCase.b))
+		assert.Equal(t, testCase.expected, Distance(testCase.b, testCase.a))
+	}
+}
+
+func TestTree_Search(t *testing.T) {
+	t.Parallel()
+
+	tree := New()
+	for _, word := range []string{"recurs", "recus", "rescu", "python", "pythons", "java", "recurs"} {
+		tree.Add(word)
+	}
+	assert.Equal(t, 6, tree.Len())
+
+	assert.ElementsMatch(t, []Match{{"recurs", 1}, {"recus", 0}}, tree.Search("recus", 1))
+	assert.ElementsMatch(t, []Match{{"recurs", 1}, {"recus", 0}, {"rescu", 2}}, tree.Search("recus", 2))
+	assert.ElementsMatch(t, []Match{{"python", 1}, {"pythons", 2}}, tree.Search("pyton", 2))
+	assert.Empty(t, tree.Search("haskell", 2))
+	assert.Empty(t, New().Search("haskell", 2))
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"github.com/spf13/viper"
 	"math"
 	"time"
@@ -15,6 +16,7 @@ const (
 	optParallel         = "parallel"
 	optScanTimeout      = "scan_timeout"
 	optScanLimit        = "scan_limit"
+	optScanMaxLimit     = "scan_max_limit"
 	optPort             = "port"
 	optSchedulerHour    = "scheduler_hour"
 	optSchedulerMinute  = "scheduler_minute"
@@ -22,24 +24,88 @@ const (
 	optTokenTTL         = "token_max_time"
 	optRateLimit        = "rate_limit"
 	optConcurrencyLimit = "concurrency_limit"
+	optBoostTitle       = "boost_title"
+	optBoostAlt         = "boost_alt"
+	optBoostTranscript  = "boost_transcript"
+	optFuzzyDistance    = "fuzzy_distance"
+	optSnippetPre       = "snippet_pre"
+	optSnippetPost      = "snippet_post"
+	optSnippetLength    = "snippet_length"
+	optSnippetEscape    = "snippet_escape_html"
+	optIndexSnapshot    = "index_snapshot"
+	optSearchBackend    = "search_backend"
+	optFetchRetries     = "fetch_retries"
+	optBackoffBase      = "fetch_backoff_base"
+	optBackoffMax       = "fetch_backoff_max"
+	optFetchBatch       = "fetch_batch_size"
+	optSchedulerCron    = "scheduler_cron"
+	optSchedulerZone    = "scheduler_timezone"
+	optSchedulerJitter  = "scheduler_jitter"
+	optSchedulerCatchUp = "scheduler_catch_up"
+	optInstanceId       = "instance_id"
+	optRefreshCron      = "scheduler_refresh_cron"
+	optRefreshWindow    = "refresh_window"
+	optLeaseTTL         = "lease_ttl"
+	optFetchCacheDir    = "fetch_cache_dir"
+	optFetchOffline     = "fetch_offline"
+	optFetchRPS         = "fetch_rps"
+	optFetchInFlight    = "fetch_max_in_flight"
+	optBreakerThreshold = "fetch_breaker_threshold"
+	optBreakerCooldown  = "fetch_breaker_cooldown"
+)
+
+const (
+	// SearchBackendIndex is the keyword index built with the snowball stemmer
+	SearchBackendIndex = "index"
+	// SearchBackendFTS5 is the SQLite FTS5 table with porter tokenizer, requires the sqlite_fts5 build tag
+	SearchBackendFTS5 = "fts5"
 )
 
 type Config struct {
 	Dsn              string
 	Url              string
 	Migrations       string
+	IndexSnapshot    string
+	SearchBackend    string
 	TokenSecret      string
+	InstanceId       string
+	FetchCacheDir    string
+	FetchOffline     bool
 	FetchLimit       int
 	Parallel         int
+	FetchRetries     int
+	FetchBatch       int
+	FetchInFlight    int
+	BreakerThreshold int
 	ScanLimit        int
+	ScanMaxLimit     int
 	Port             int
 	SchedulerHour    int
 	SchedulerMinute  int
+	SchedulerCron    []string
+	RefreshCron      []string
+	RefreshWindow    int
+	SchedulerZone    string
 	RateLimit        int
 	ConcurrencyLimit int
+	FuzzyDistance    int
+	SnippetLength    int
+	SnippetPre       string
+	SnippetPost      string
+	SnippetEscape    bool
 	ReqTimeout       time.Duration
 	ScanTimeout      time.Duration
 	TokenTTL         time.Duration
+	BackoffBase      time.Duration
+	BackoffMax       time.Duration
+	SchedulerJitter  time.Duration
+	SchedulerCatchUp time.Duration
+	LeaseTTL         time.Duration
+	BreakerCooldown  time.Duration
+	FetchRPS         float64
+	BoostTitle       float64
+	BoostAlt         float64
+	BoostTranscript  float64
 }
 
 func ReadConfig(path string) (*Config, error) {
@@ -55,6 +121,7 @@ func ReadConfig(path string) (*Config, error) {
 	viper.SetDefault(optParallel, 200)
 	viper.SetDefault(optScanTimeout, math.MaxInt)
 	viper.SetDefault(optScanLimit, 10)
+	viper.SetDefault(optScanMaxLimit, 100)
 	viper.SetDefault(optPort, 20202)
 	viper.SetDefault(optDsn, "database.db")
 	viper.SetDefault(optMigrations, "migrations")
@@ -62,26 +129,87 @@ func ReadConfig(path string) (*Config, error) {
 	viper.SetDefault(optTokenTTL, 1*time.Hour)
 	viper.SetDefault(optRateLimit, math.MaxInt)
 	viper.SetDefault(optConcurrencyLimit, math.MaxInt)
+	viper.SetDefault(optBoostTitle, 3.0)
+	viper.SetDefault(optBoostAlt, 1.5)
+	viper.SetDefault(optBoostTranscript, 1.0)
+	viper.SetDefault(optFuzzyDistance, 2)
+	viper.SetDefault(optSnippetPre, "<em>")
+	viper.SetDefault(optSnippetPost, "</em>")
+	viper.SetDefault(optSnippetLength, 160)
+	viper.SetDefault(optSnippetEscape, true)
+	viper.SetDefault(optIndexSnapshot, "index.snapshot")
+	viper.SetDefault(optSearchBackend, SearchBackendIndex)
+	viper.SetDefault(optFetchRetries, 3)
+	viper.SetDefault(optBackoffBase, 500*time.Millisecond)
+	viper.SetDefault(optBackoffMax, 30*time.Second)
+	viper.SetDefault(optFetchBatch, 100)
+	viper.SetDefault(optSchedulerZone, "Local")
+	viper.SetDefault(optSchedulerJitter, 0)
+	viper.SetDefault(optSchedulerCatchUp, 0)
+	viper.SetDefault(optInstanceId, "")
+	viper.SetDefault(optLeaseTTL, 30*time.Second)
+	viper.SetDefault(optRefreshWindow, 10)
+	viper.SetDefault(optFetchCacheDir, "")
+	viper.SetDefault(optFetchOffline, false)
+	viper.SetDefault(optFetchRPS, 0)
+	viper.SetDefault(optFetchInFlight, 0)
+	viper.SetDefault(optBreakerThreshold, 5)
+	viper.SetDefault(optBreakerCooldown, 30*time.Second)
 
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, err
 	}
 
+	// scheduler_hour and scheduler_minute make the daily schedule unless cron expressions are given
+	cron := viper.GetStringSlice(optSchedulerCron)
+	if len(cron) == 0 {
+		cron = []string{fmt.Sprintf("%d %d * * *", viper.GetInt(optSchedulerMinute), viper.GetInt(optSchedulerHour))}
+	}
+
 	return &Config{
 		Dsn:              viper.GetString(optDsn),
 		Url:              viper.GetString(optSourceUrl),
 		Migrations:       viper.GetString(optMigrations),
+		IndexSnapshot:    viper.GetString(optIndexSnapshot),
+		SearchBackend:    viper.GetString(optSearchBackend),
 		TokenSecret:      viper.GetString(optTokenSecret),
+		InstanceId:       viper.GetString(optInstanceId),
+		FetchCacheDir:    viper.GetString(optFetchCacheDir),
+		FetchOffline:     viper.GetBool(optFetchOffline),
 		FetchLimit:       viper.GetInt(optFetchLimit),
 		ScanLimit:        viper.GetInt(optScanLimit),
+		ScanMaxLimit:     viper.GetInt(optScanMaxLimit),
 		Parallel:         viper.GetInt(optParallel),
+		FetchRetries:     viper.GetInt(optFetchRetries),
+		FetchBatch:       viper.GetInt(optFetchBatch),
+		FetchInFlight:    viper.GetInt(optFetchInFlight),
+		BreakerThreshold: viper.GetInt(optBreakerThreshold),
 		Port:             viper.GetInt(optPort),
 		SchedulerHour:    viper.GetInt(optSchedulerHour),
 		SchedulerMinute:  viper.GetInt(optSchedulerMinute),
+		SchedulerCron:    cron,
+		RefreshCron:      viper.GetStringSlice(optRefreshCron),
+		RefreshWindow:    viper.GetInt(optRefreshWindow),
+		SchedulerZone:    viper.GetString(optSchedulerZone),
 		RateLimit:        viper.GetInt(optRateLimit),
 		ConcurrencyLimit: viper.GetInt(optConcurrencyLimit),
+		FuzzyDistance:    viper.GetInt(optFuzzyDistance),
+		SnippetLength:    viper.GetInt(optSnippetLength),
+		SnippetPre:       viper.GetString(optSnippetPre),
+		SnippetPost:      viper.GetString(optSnippetPost),
+		SnippetEscape:    viper.GetBool(optSnippetEscape),
 		ReqTimeout:       viper.GetDuration(optReqTimeout),
 		ScanTimeout:      viper.GetDuration(optScanTimeout),
 		TokenTTL:         viper.GetDuration(optTokenTTL),
+		BackoffBase:      viper.GetDuration(optBackoffBase),
+		BackoffMax:       viper.GetDuration(optBackoffMax),
+		SchedulerJitter:  viper.GetDuration(optSchedulerJitter),
+		SchedulerCatchUp: viper.GetDuration(optSchedulerCatchUp),
+		LeaseTTL:         viper.GetDuration(optLeaseTTL),
+		BreakerCooldown:  viper.GetDuration(optBreakerCooldown),
+		FetchRPS:         viper.GetFloat64(optFetchRPS),
+		BoostTitle:       viper.GetFloat64(optBoostTitle),
+		BoostAlt:         viper.GetFloat64(optBoostAlt),
+		BoostTranscript:  viper.GetFloat64(optBoostTranscript),
 	}, nil
 }
@@ -0,0 +1,255 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a standard 5-field cron expression: minute, hour, day of month, month and day of week.
+// Fields take values, ranges, lists and steps, e.g. "*/15 9-18 * * mon-fri". Months and days of week
+// may be given by their English three-letter names, Sunday is 0 or 7.
+// As in Vixie cron, a day matches either restricted day field when both of them are restricted.
+type Schedule struct {
+	expr       string
+	minute     uint64
+	hour       uint64
+	dom        uint64
+	month      uint64
+	dow        uint64
+	minuteStar bool
+	hourStar   bool
+	domStar    bool
+	dowStar    bool
+}
+
+type field struct {
+	name  string
+	min   int
+	max   int
+	names []string
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12,
+		names: []string{"", "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	dowField = field{name: "day of week", min: 0, max: 7,
+		names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+// searchYears bounds the search of the next time, so that impossible dates like Feb 30 don't loop forever
+const searchYears = 5
+
+func Parse(expr string) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron %q: expected 5 fields, got %d", expr, len(fields))
+	}
+
+	s := &Schedule{expr: strings.Join(fields, " ")}
+	var err error
+	if s.minute, err = minuteField.parse(fields[0]); err != nil {
+		return nil, fmt.Errorf("cron %q: %w", expr, err)
+	}
+	if s.hour, err = hourField.parse(fields[1]); err != nil {
+		return nil, fmt.Errorf("cron %q: %w", expr, err)
+	}
+	if s.dom, err = domField.parse(fields[2]); err != nil {
+		return nil, fmt.Errorf("cron %q: %w", expr, err)
+	}
+	if s.month, err = monthField.parse(fields[3]); err != nil {
+		return nil, fmt.Errorf("cron %q: %w", expr, err)
+	}
+	if s.dow, err = dowField.parse(fields[4]); err != nil {
+		return nil, fmt.Errorf("cron %q: %w", expr, err)
+	}
+
+	// Sunday may be written as 7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	// as in Vixie cron, a field starting with a star like */2 doesn't restrict the day for the either-field rule,
+	// nor makes the time fixed for DST transitions
+	s.minuteStar = strings.HasPrefix(fields[0], "*")
+	s.hourStar = strings.HasPrefix(fields[1], "*")
+	s.domStar = strings.HasPrefix(fields[2], "*")
+	s.dowStar = strings.HasPrefix(fields[4], "*")
+
+	return s, nil
+}
+
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// Next returns the first matching time after the given one in its location, zero time if there is none.
+// Times are matched by the wall clock: a time repeated by a DST transition matches once. As in Vixie cron,
+// a fixed time skipped by a transition matches at its end, while skipped times of a schedule with a star
+// in the minute or hour field don't match.
+func (s *Schedule) Next(after time.Time) time.Time {
+	next := s.next(after)
+	if s.minuteStar || s.hourStar {
+		return next
+	}
+	if skipped := s.skipped(after, next); !skipped.IsZero() {
+		return skipped
+	}
+	return next
+}
+
+// skipped returns the end of the first DST transition after the given time and before until,
+// which has skipped a matching wall clock time, zero time if there is none. Zero until means no bound.
+func (s *Schedule) skipped(after time.Time, until time.Time) time.Time {
+	limit := after.Year() + searchYears
+	for t := after; ; {
+		_, end := t.ZoneBounds()
+		if !end.IsZero() && !end.After(t) {
+			// bounds of zones extended by a rule may end where they start at the end of a leap year
+			t = t.Add(time.Hour)
+			continue
+		}
+		if end.IsZero() || end.Year() > limit || (!until.IsZero() && end.After(until)) {
+			return time.Time{}
+		}
+
+		_, before := end.Add(-time.Nanosecond).Zone()
+		_, offset := end.Zone()
+		if offset > before {
+			// wall clock readings from gapStart to gapEnd don't exist, they are matched in UTC which has no gaps
+			gapEnd := wallClock(end)
+			gapStart := gapEnd.Add(-time.Duration(offset-before) * time.Second)
+			if m := s.next(gapStart.Add(-time.Minute)); !m.IsZero() && m.Before(gapEnd) {
+				return end
+			}
+		}
+		t = end
+	}
+}
+
+// next returns the first matching wall clock time after the given one in its location, zero time
+// if there is none. Times skipped by a DST transition don't match.
+func (s *Schedule) next(after time.Time) time.Time {
+	loc := after.Location()
+	t := time.Date(after.Year(), after.Month(), after.Day(), after.Hour(), after.Minute()+1, 0, 0, loc)
+	limit := after.Year() + searchYears
+
+	for t.Year() <= limit {
+		switch {
+		case !has(s.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case !has(s.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case !has(s.minute, t.Minute()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+		case !wallAfter(t, after) || !t.After(after):
+			// a time of the hour repeated when clocks go back, which has already passed in the other offset
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := has(s.dom, t.Day())
+	dow := has(s.dow, int(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// wallAfter compares wall clock readings of times ignoring their offsets.
+func wallAfter(t time.Time, u time.Time) bool {
+	return wallClock(t).After(wallClock(u))
+}
+
+// wallClock returns the UTC time with the same wall clock reading.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<uint(v)) != 0
+}
+
+// parse makes a bit set of values of a comma separated list of values, ranges and steps.
+func (f field) parse(s string) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(s, ",") {
+		bits, err := f.parsePart(part)
+		if err != nil {
+			return 0, err
+		}
+		set |= bits
+	}
+	return set, nil
+}
+
+func (f field) parsePart(part string) (uint64, error) {
+	rng, stepStr, hasStep := strings.Cut(part, "/")
+
+	step := 1
+	if hasStep {
+		var err error
+		if step, err = strconv.Atoi(stepStr); err != nil || step <= 0 {
+			return 0, fmt.Errorf("bad %s step %q", f.name, stepStr)
+		}
+	}
+
+	var from, to int
+	switch {
+	case rng == "*":
+		from, to = f.min, f.max
+	case strings.Contains(rng, "-"):
+		fromStr, toStr, _ := strings.Cut(rng, "-")
+		var err error
+		if from, err = f.value(fromStr); err != nil {
+			return 0, err
+		}
+		if to, err = f.value(toStr); err != nil {
+			return 0, err
+		}
+		if from > to {
+			return 0, fmt.Errorf("bad %s range %q", f.name, rng)
+		}
+	default:
+		var err error
+		if from, err = f.value(rng); err != nil {
+			return 0, err
+		}
+		// "5/15" means from 5 to the end with step 15
+		to = from
+		if hasStep {
+			to = f.max
+		}
+	}
+
+	var set uint64
+	for v := from; v <= to; v += step {
+		set |= 1 << uint(v)
+	}
+	return set, nil
+}
+
+func (f field) value(s string) (int, error) {
+	for i, name := range f.names {
+		if name != "" && strings.EqualFold(s, name) {
+			return i, nil
+		}
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("bad %s %q", f.name, s)
+	}
+	return v, nil
+}
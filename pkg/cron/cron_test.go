@@ -0,0 +1,157 @@
+package cron
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	for _, expr := range []string{
+		"* * * * *",
+		"0 3 * * *",
+		"*/15 9-18 * * mon-fri",
+		"0,30 0-23/2 1,15 jan-jun 7",
+		"5/20 * * * *",
+	} {
+		_, err := Parse(expr)
+		assert.NoError(t, err, expr)
+	}
+
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"* * * foo *",
+	} {
+		_, err := Parse(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	t.Parallel()
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	testTable := []struct {
+		name     string
+		expr     string
+		after    time.Time
+		expected time.Time
+	}{
+		{name: "Daily", expr: "0 3 * * *",
+			after:    time.Date(2024, 6, 7, 12, 0, 0, 0, time.UTC),
+			expected: time.Date(2024, 6, 8, 3, 0, 0, 0, time.UTC)},
+		{name: "StrictlyAfter", expr: "0 3 * * *",
+			after:    time.Date(2024, 6, 7, 3, 0, 0, 0, time.UTC),
+			expected: time.Date(2024, 6, 8, 3, 0, 0, 0, time.UTC)},
+		{name: "Seconds", expr: "* * * * *",
+			after:    time.Date(2024, 6, 7, 3, 0, 30, 0, time.UTC),
+			expected: time.Date(2024, 6, 7, 3, 1, 0, 0, time.UTC)},
+		{name: "Step", expr: "*/15 * * * *",
+			after:    time.Date(2024, 6, 7, 3, 16, 0, 0, time.UTC),
+			expected: time.Date(2024, 6, 7, 3, 30, 0, 0, time.UTC)},
+		{name: "Weekdays", expr: "0 9 * * mon-fri",
+			after:    time.Date(2024, 6, 7, 10, 0, 0, 0, time.UTC), // Friday
+			expected: time.Date(2024, 6, 10, 9, 0, 0, 0, time.UTC)},
+		{name: "SundaySeven", expr: "0 0 * * 7",
+			after:    time.Date(2024, 6, 7, 10, 0, 0, 0, time.UTC),
+			expected: time.Date(2024, 6, 9, 0, 0, 0, 0, time.UTC)},
+		{name: "DayOfMonthOrWeek", expr: "0 0 13 * fri",
+			after:    time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+			expected: time.Date(2024, 6, 7, 0, 0, 0, 0, time.UTC)},
+		// a step over a star is a star for the rule, both day fields have to match
+		{name: "DayOfMonthStepAndWeek", expr: "0 0 */2 * mon",
+			after:    time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC),
+			expected: time.Date(2024, 6, 17, 0, 0, 0, 0, time.UTC)},
+		{name: "MonthEnd", expr: "0 0 31 * *",
+			after:    time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+			expected: time.Date(2024, 7, 31, 0, 0, 0, 0, time.UTC)},
+		{name: "LeapDay", expr: "0 0 29 feb *",
+			after:    time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			expected: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{name: "Impossible", expr: "0 0 30 feb *",
+			after:    time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			expected: time.Time{}},
+		{name: "TimeZone", expr: "0 3 * * *",
+			after:    time.Date(2024, 6, 7, 12, 0, 0, 0, berlin),
+			expected: time.Date(2024, 6, 8, 1, 0, 0, 0, time.UTC)},
+		// 02:00-03:00 doesn't exist on 2024-03-31 in Berlin, a fixed time in it runs at 03:00
+		{name: "SkippedByDST", expr: "30 2 * * *",
+			after:    time.Date(2024, 3, 30, 12, 0, 0, 0, berlin),
+			expected: time.Date(2024, 3, 31, 1, 0, 0, 0, time.UTC)},
+		{name: "AfterSkippedByDST", expr: "30 2 * * *",
+			after:    time.Date(2024, 3, 31, 1, 0, 0, 0, time.UTC).In(berlin),
+			expected: time.Date(2024, 4, 1, 0, 30, 0, 0, time.UTC)},
+		{name: "NotSkippedByDST", expr: "30 3 * * *",
+			after:    time.Date(2024, 3, 30, 12, 0, 0, 0, berlin),
+			expected: time.Date(2024, 3, 31, 1, 30, 0, 0, time.UTC)},
+		// a schedule with a star in the hour field isn't fixed, its skipped times don't run
+		{name: "HourlySkippedByDST", expr: "30 * * * *",
+			after:    time.Date(2024, 3, 31, 0, 45, 0, 0, time.UTC).In(berlin),
+			expected: time.Date(2024, 3, 31, 1, 30, 0, 0, time.UTC)},
+		{name: "AcrossSpringDST", expr: "0 4 * * *",
+			after:    time.Date(2024, 3, 30, 12, 0, 0, 0, berlin),
+			expected: time.Date(2024, 3, 31, 2, 0, 0, 0, time.UTC)},
+		// 02:00-03:00 happens twice on 2024-10-27 in Berlin, first at +02:00, then at +01:00
+		{name: "RepeatedByDST", expr: "30 2 * * *",
+			after:    time.Date(2024, 10, 27, 0, 0, 0, 0, time.UTC).In(berlin),
+			expected: time.Date(2024, 10, 27, 1, 30, 0, 0, time.UTC)},
+		{name: "RepeatedByDSTOnce", expr: "30 2 * * *",
+			after:    time.Date(2024, 10, 27, 0, 30, 0, 0, time.UTC).In(berlin),
+			expected: time.Date(2024, 10, 28, 1, 30, 0, 0, time.UTC)},
+		{name: "HourlyAcrossFallDST", expr: "0 * * * *",
+			after:    time.Date(2024, 10, 27, 0, 30, 0, 0, time.UTC).In(berlin),
+			expected: time.Date(2024, 10, 27, 2, 0, 0, 0, time.UTC)},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			s, err := Parse(testCase.expr)
+			require.NoError(t, err)
+
+			next := s.Next(testCase.after)
+			assert.True(t, testCase.expected.Equal(next), "expected %v, got %v", testCase.expected, next)
+		})
+	}
+}
+
+func TestSchedule_NextFiresOnce(t *testing.T) {
+	t.Parallel()
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	// every matching wall clock time fires once through both DST transitions of a year
+	for _, expr := range []string{"30 2 * * *", "*/20 * * * *"} {
+		s, err := Parse(expr)
+		require.NoError(t, err)
+
+		seen := make(map[string]bool)
+		after := time.Date(2024, 1, 1, 0, 0, 0, 0, berlin)
+		for i := 0; i < 30000; i++ {
+			next := s.Next(after)
+			require.True(t, next.After(after))
+
+			wall := next.Format("2006-01-02 15:04")
+			assert.False(t, seen[wall], "%s fired twice at %s", expr, wall)
+			seen[wall] = true
+			after = next
+		}
+	}
+}
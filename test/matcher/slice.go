@@ -3,6 +3,8 @@ package matcher
 import (
 	"fmt"
 	"github.com/golang/mock/gomock"
+	"reflect"
+	"slices"
 	"yadro-go/internal/core/domain"
 )
 
@@ -27,7 +29,7 @@ func (p keywordPtrSliceMatcher) Matches(x interface{}) bool {
 
 	for _, v := range actual {
 		expected := expectedMap[v.Word]
-		if expected == nil || len(expected.Nums) != len(v.Nums) {
+		if expected == nil || !postingsMatch(expected.Postings, v.Postings) {
 			return false
 		}
 		delete(expectedMap, v.Word)
@@ -36,6 +38,25 @@ func (p keywordPtrSliceMatcher) Matches(x interface{}) bool {
 	return len(expectedMap) == 0
 }
 
+func postingsMatch(expected []domain.Posting, actual []domain.Posting) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+
+	left := slices.Clone(expected)
+	for _, v := range actual {
+		i := slices.IndexFunc(left, func(e domain.Posting) bool {
+			return reflect.DeepEqual(e, v)
+		})
+		if i < 0 {
+			return false
+		}
+		left = slices.Delete(left, i, i+1)
+	}
+
+	return true
+}
+
 func (p keywordPtrSliceMatcher) String() string {
 	return fmt.Sprintf("is equal to %v", p.expected)
 }
@@ -0,0 +1,165 @@
+//go:build sqlite_fts5
+
+// Package parity checks that the keyword index and the SQLite FTS5 backends answer queries the same way.
+// Run with: go test -tags sqlite_fts5 ./test/parity
+package parity
+
+import (
+	"context"
+	"database/sql"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"yadro-go/internal/adapter/secondary"
+	"yadro-go/internal/adapter/secondary/index"
+	"yadro-go/internal/adapter/secondary/repository"
+	"yadro-go/internal/core/domain"
+	"yadro-go/internal/core/service"
+	"yadro-go/internal/core/service/stemming"
+	"yadro-go/pkg/sqlite"
+	"yadro-go/test/logger"
+)
+
+var comics = []*domain.Comic{
+	{Num: 1, Title: "Python", Alt: "I wrote 20 short programs in Python yesterday.", Transcript: "Flying with python code"},
+	{Num: 2, Title: "Regular Expressions", Alt: "Wait, forgot to escape a space.", Transcript: "Everybody stand back, I know regular expressions"},
+	{Num: 3, Title: "Exploits of a Mom", Alt: "Her daughter is named Help I'm trapped in a driver's license factory.", Transcript: "Did you really name your son Robert'); DROP TABLE Students;--"},
+	{Num: 4, Title: "Compiling", Alt: "Are you stealing those LCDs?", Transcript: "The code's compiling, so we are running around"},
+	{Num: 5, Title: "Snakes", Alt: "A python is not a venomous snake.", Transcript: "Snakes everywhere in the garden"},
+	{Num: 6, Title: "Running", Alt: "Runners run faster when chased by code reviewers.", Transcript: "He was running a marathon"},
+}
+
+type comicProvider map[int]*domain.Comic
+
+func (p comicProvider) GetById(_ context.Context, id int) (*domain.Comic, error) {
+	if comic, ok := p[id]; ok {
+		return comic, nil
+	}
+	return nil, secondary.ErrComicNotFound
+}
+
+func (p comicProvider) LastNum(_ context.Context) (int, error) {
+	return len(p), nil
+}
+
+func TestBackendsParity(t *testing.T) {
+	log := slog.New(logger.EmptyHandler{})
+	db := openDb(t)
+
+	comicsRepo := repository.NewComicRepository(log, db)
+	searchIndex := index.New(log, repository.NewKeywordRepository(log, db))
+	stemmer := stemming.New()
+
+	provider := make(comicProvider, len(comics))
+	for _, comic := range comics {
+		provider[comic.Num] = comic
+	}
+	runsRepo := repository.NewUpdateRunRepository(log, db)
+	updater := service.NewUpdater(log, stemmer, comicsRepo, searchIndex, runsRepo, provider, len(comics), 1)
+	_, err := updater.Update(context.Background())
+	require.NoError(t, err)
+
+	boosts := map[domain.Field]float64{domain.FieldTitle: 3, domain.FieldAlt: 1.5, domain.FieldTranscript: 1}
+	indexScanner := service.NewScanner(log, stemmer, comicsRepo, searchIndex, nil, boosts, 0)
+	fullTextScanner := service.NewFullTextScanner(log, stemmer, comicsRepo,
+		repository.NewFullTextRepository(log, db, boosts), nil)
+
+	testTable := []struct {
+		search   string
+		fields   []domain.Field
+		expected []int
+	}{
+		{search: "python", expected: []int{1, 5}},
+		{search: "python snakes", expected: []int{1, 5}},
+		{search: "+python -snake", expected: []int{1}},
+		{search: "code AND running", expected: []int{4, 6}},
+		{search: `"regular expressions"`, expected: []int{2}},
+		{search: `"everywhere in the garden"`, expected: []int{5}},
+		{search: "title:python", expected: []int{1}},
+		{search: "python", fields: []domain.Field{domain.FieldAlt}, expected: []int{1, 5}},
+		{search: "run", fields: []domain.Field{domain.FieldTitle, domain.FieldTranscript}, expected: []int{4, 6}},
+		{search: "students OR trapped", expected: []int{3}},
+		{search: "unknown", expected: []int{}},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.search, func(t *testing.T) {
+			opts := domain.ScanOptions{UseIndex: true, Fields: testCase.fields}
+
+			indexResult, err := indexScanner.Scan(context.Background(), testCase.search, opts)
+			require.NoError(t, err)
+			fullTextResult, err := fullTextScanner.Scan(context.Background(), testCase.search, opts)
+			require.NoError(t, err)
+
+			assert.ElementsMatch(t, testCase.expected, nums(indexResult))
+			assert.ElementsMatch(t, testCase.expected, nums(fullTextResult))
+			if len(testCase.expected) > 0 {
+				assert.Equal(t, indexResult.Comics[0].Terms, fullTextResult.Comics[0].Terms)
+			}
+		})
+	}
+
+	t.Run("Pages", func(t *testing.T) {
+		opts := domain.ScanOptions{Limit: 1}
+		found := make([]int, 0)
+		for {
+			result, err := fullTextScanner.Scan(context.Background(), "code OR python", opts)
+			require.NoError(t, err)
+			require.Len(t, result.Comics, 1)
+			found = append(found, nums(result)...)
+			if result.Next == nil {
+				break
+			}
+			opts.Cursor = result.Next
+		}
+		assert.ElementsMatch(t, []int{1, 4, 5, 6}, found)
+	})
+
+	t.Run("SyncOnSave", func(t *testing.T) {
+		changed := *comics[4]
+		changed.Alt = "Boas are not venomous either."
+		require.NoError(t, comicsRepo.Save(context.Background(), []*domain.Comic{&changed}))
+
+		result, err := fullTextScanner.Scan(context.Background(), "venomous", domain.ScanOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []int{5}, nums(result))
+
+		result, err = fullTextScanner.Scan(context.Background(), "alt:python", domain.ScanOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []int{1}, nums(result))
+	})
+}
+
+func openDb(t *testing.T) *sql.DB {
+	sqliteDb := sqlite.SQLite{}
+	db, err := sqliteDb.Connect(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(sqliteDb.Close)
+
+	// FTS5 migrations have their own versions table and go after the main ones
+	for _, migrations := range []struct{ dir, table string }{
+		{dir: "../../migrations"},
+		{dir: "../../migrations/fts5", table: "schema_migrations_fts5"},
+	} {
+		driver, err := sqlite3.WithInstance(db, &sqlite3.Config{MigrationsTable: migrations.table})
+		require.NoError(t, err)
+		m, err := migrate.NewWithDatabaseInstance("file://"+migrations.dir, "sqlite3", driver)
+		require.NoError(t, err)
+		require.NoError(t, m.Up())
+	}
+
+	return db
+}
+
+func nums(result *domain.ScanResult) []int {
+	res := make([]int, len(result.Comics))
+	for i, scored := range result.Comics {
+		res[i] = scored.Comic.Num
+	}
+	return res
+}